@@ -6,7 +6,6 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
-	"log"
 	"math"
 	"os"
 	"os/exec"
@@ -16,151 +15,46 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 	"unicode"
 	"wget/ctx"
 	"wget/downloader"
 	"wget/fileio"
 	"wget/help"
 	"wget/info"
+	"wget/jobs"
 	"wget/xerr"
 	"wget/xurl"
 )
 
 // DownloadContext builds and returns the download context,
-// as defined by (parsing and evaluating) the commandline arguments.
+// as defined by (parsing and evaluating) the commandline arguments, see Parse.
+// The program exits on the invalid arguments, or after printing the help text, or the version, or after
+// listing, or cancelling, the background jobs; or once the background process is started, with -B.
 func DownloadContext(arguments []string) (Arguments ctx.Context) {
-
-	length := len(arguments)
-
-	// This closure function aids in preventing recursive launching of processes,
-	// we only need one background option,
-	// if you pass more than one, the function will consider the first background option
-	withoutDuplicateBackground := func(args []string) []string {
-		seen := make(map[string]bool)
-		unique := []string{}
-
-		for _, arg := range args {
-			if arg == "-B" || arg == "--background" {
-				if seen["background"] {
-					continue // Skip duplicate -B or --background
-				}
-				seen["background"] = true
-			}
-			unique = append(unique, arg)
-		}
-		return unique
+	Arguments, foreground, err := parse(arguments)
+	if err != nil {
+		xerr.WriteError(err, xerr.ExitStatus(err), true)
 	}
-	arguments = withoutDuplicateBackground(arguments)
-	var backgroundLocation int
-
-	for i, arg := range arguments {
-
-		switch {
-		case arg == "--help" || arg == "-h":
-			xerr.WriteError(help.PrintManPage(), 0, true)
-
-		case arg == "-B" || arg == "--background":
-			Arguments.BackgroundMode = true
-			if len(arguments) == 1 {
-				xerr.WriteError(help.UsageMessage, 1, true)
-			}
-			backgroundLocation = i
-
-		case strings.HasPrefix(arg, "-P="):
-			isParsed, path := IsPathFlag(arg)
-			if length == 1 {
-				xerr.WriteError(help.UsageMessage, 1, true)
-			}
-			if isParsed {
-				Arguments.SavePath = CreateDirFromPath(path)
-			}
-
-		case strings.HasPrefix(arg, "-i="):
-			isParsed, path, err := InputFile(arg)
-			if isParsed && err == nil {
-				Arguments.InputFile = path
-				slice, err := ReadUrlFromFile(path)
-
-				if err != nil {
-					xerr.WriteError(err, 2, false)
-				}
-
-				// if we read the file and find no urls(empty file)
-				if len(slice) == 0 {
-					xerr.WriteError(fmt.Sprintf("No URLs found in %v", path), 2, false)
-				}
-				Arguments.Links = append(Arguments.Links, slice...)
-			}
-
-		case arg == "--mirror":
-			Arguments.Mirror = true
-
-		case arg == "--convert-links":
-			Arguments.ConvertLinks = true
-
-		case strings.HasPrefix(arg, "-O="):
-			if length == 1 {
-				xerr.WriteError(help.UsageMessage, 1, true)
-			}
-			if ok, file := IsOutputFlag(arg); ok && file != "" {
-				Arguments.OutputFile = file
-			}
-
-		case strings.HasPrefix(arg, "--rate-limit="):
-			if length == 1 {
-				xerr.WriteError(help.UsageMessage, 1, true)
-			}
-			Arguments.RateLimit = strings.TrimPrefix(arg, "--rate-limit=")
-			Arguments.RateLimitValue = ToBytes(Arguments.RateLimit)
-
-		case strings.HasPrefix(arg, "-R="):
-			if length == 1 {
-				xerr.WriteError(help.UsageMessage, 1, true)
-			}
-			rejects := strings.Split(strings.TrimPrefix(arg, "-R="), ",")
-			Arguments.Rejects = append(Arguments.Rejects, rejects...)
-
-		case strings.HasPrefix(arg, "--reject="):
-			if length == 1 {
-				xerr.WriteError(help.UsageMessage, 1, true)
-			}
-			rejects := strings.Split(strings.TrimPrefix(arg, "--reject="), ",")
-			Arguments.Rejects = append(Arguments.Rejects, rejects...)
-
-		case strings.HasPrefix(arg, "-X="):
-			if length == 1 {
-				xerr.WriteError(help.UsageMessage, 1, true)
-			}
-			excludes := strings.Split(strings.TrimPrefix(arg, "-X="), ",")
-			Arguments.Exclude = append(Arguments.Exclude, excludes...)
-
-		case strings.HasPrefix(arg, "--exclude="):
-			if length == 1 {
-				xerr.WriteError(help.UsageMessage, 1, true)
-			}
-			excludes := strings.Split(strings.TrimPrefix(arg, "--exclude="), ",")
-			Arguments.Exclude = append(Arguments.Exclude, excludes...)
-
-		case arg == "--version" || arg == "-v":
-			xerr.WriteError(info.VersionText(), 0, true)
-
-		default:
-			// if arg gets here and fails it wont be added to the urls flag
-			url, isValid, err := xurl.IsValidURL(arg)
-			if !isValid {
-				xerr.WriteError(err, 1, false)
-			}
-			if isValid {
-				Arguments.Links = append(Arguments.Links, url)
-			}
+	switch {
+	case Arguments.IsHelp:
+		xerr.WriteError(help.PrintManPage(HelpOptions()), 0, true)
+	case Arguments.IsVersion:
+		xerr.WriteError(info.VersionText(), 0, true)
+	case Arguments.Jobs:
+		listJobs()
+	case Arguments.Cancel != "":
+		cancelJob(Arguments.Cancel)
+	}
+	// without any arguments, the usage is printed by the caller
+	if len(arguments) > 0 {
+		if err = Validate(Arguments); err != nil {
+			xerr.WriteError(err, xerr.ExitStatus(err), true)
 		}
 	}
 
-	launchInBackground := func(args []string, index int, fd *os.File) {
-		// remove the background flag to prevent recursion
-		copy(args[index:], args[index+1:])
-		args = args[:len(args)-1] // remove the last element since its will be a duplicate (same as len(args)-2)
-
+	// the background process is started without the background flag, to prevent recursion
+	launchInBackground := func(args []string, fd *os.File, logFile string) {
 		// detach the current process from its parent
 		// run in the background
 		executable, err := os.Executable()
@@ -171,6 +65,16 @@ func DownloadContext(arguments []string) (Arguments ctx.Context) {
 		// if stdin or stdout or stderr is nill, the process will read from os.DevNull
 		cmd := exec.Command(executable, args...)
 
+		// the background process records its progress to the state file of its job, for --jobs
+		job, err := jobs.New(Arguments.Links, Arguments.InputFile, logFile)
+		if err != nil {
+			xerr.WriteError(fmt.Errorf("failed to create the background job: %v", err), 1, false)
+		} else {
+			cmd.Env = append(os.Environ(), jobs.Env+"="+job.ID)
+			fmt.Printf("Continuing in background, job %s: see ‘wget --jobs’, or stop it with ‘wget --cancel=%s’.\n",
+				job.ID, job.ID)
+		}
+
 		// send the output of the file to specified file descriptor
 		cmd.Stdout = fd
 		os.Stdout = fd
@@ -183,14 +87,27 @@ func DownloadContext(arguments []string) (Arguments ctx.Context) {
 		}
 
 		// start the command and dont wait for it to finish
-		// this allows us to get the terminal prompt back
+		// this allows us to get the terminal prompt back.
+		// only the background process writes the state file of its job from then on, see jobs.Current
 		if err = cmd.Start(); err != nil {
 			xerr.WriteError(fmt.Errorf("failed to start background process: %v Defaulting to normal", err), 1, false)
+			if job != nil {
+				_ = job.Finish(err)
+			}
 		}
 
 		// This is critical, dont remove it
 		os.Exit(0)
 	}
+	if Arguments.BackgroundMode && Arguments.LogFile != "" {
+		// the background process writes its own log file, as given to -o, or -a
+		fd, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			xerr.WriteError(fmt.Errorf("failed to open %q: %v", os.DevNull, err), 2, false)
+		}
+		fmt.Printf("Output will be written to \"%s\".\n", Arguments.LogFile)
+		launchInBackground(foreground, fd, Arguments.LogFile)
+	}
 	if Arguments.BackgroundMode {
 
 		logFile := downloader.CheckIfFileExists("wget-log")
@@ -199,12 +116,34 @@ func DownloadContext(arguments []string) (Arguments ctx.Context) {
 			xerr.WriteError(fmt.Errorf("failed to create %q defaulting to stdout", logFile), 2, false)
 		}
 		fmt.Printf("Output will be written to \"%s\".\n", logFile)
-		launchInBackground(arguments, backgroundLocation, fd)
+		launchInBackground(foreground, fd, logFile)
 	}
 
 	return
 }
 
+// listJobs prints the background jobs, running and finished, for --jobs, then exits
+func listJobs() {
+	list, err := jobs.List()
+	if err == nil {
+		err = jobs.Print(os.Stdout, list)
+	}
+	if err != nil {
+		xerr.WriteError(fmt.Errorf("failed to list the background jobs: %v", err), xerr.IOStatus, true)
+	}
+	os.Exit(0)
+}
+
+// cancelJob stops the background job of the given ID, for --cancel, then exits
+func cancelJob(id string) {
+	job, err := jobs.Cancel(id)
+	if err != nil {
+		xerr.WriteError(err, xerr.GenericStatus, true)
+	}
+	fmt.Printf("Cancelling job %s, pid %d: its partial files are kept for ‘--continue’.\n", job.ID, job.PID)
+	os.Exit(0)
+}
+
 // CreateDirFromPath creates a directory from given dirPath and returns a clean path
 // if dirPath does not exist it is created
 func CreateDirFromPath(dirPath string) string {
@@ -232,47 +171,93 @@ func CreateDirFromPath(dirPath string) string {
 }
 
 // ToBytes converts a rateLimit in (decimal or float) to bytes, if no suffix is supplied then the value is assumed to be bytes
-// the only suffixes allowed are (k == kilobytes) and (M == megabytes)
+// the only suffixes allowed are (k == kilobytes), (M == megabytes) and (G == gigabytes)
 // example when user passes: 20k ToBytes returns 20000
 // example when user passes: 20M ToBytes returns 20000000
+// example when user passes: 5G ToBytes returns 5000000000
 // example when user passes: 12.2 ToBytes returns 12
-func ToBytes(rateLimit string) (rateLimitBytes int64) {
+// an invalid amount, e.g. abc or 5g, returns an error
+func ToBytes(rateLimit string) (int64, error) {
 	// 1k == 1000 bytes
 	// 1M == 1_000_000 bytes
-
-	index := func(rateLimit []rune) int {
-		for i := len(rateLimit) - 1; i >= 0; i-- {
-			ch := rateLimit[i]
-			if unicode.IsDigit(ch) {
-				return i
-			}
-		}
-		return -1
+	// 1G == 1_000_000_000 bytes
+	units := map[string]float64{"": 1, "k": 1000, "M": 1000000, "G": 1000000000}
+
+	rateLimit = strings.TrimSpace(rateLimit)
+	size := strings.TrimRightFunc(rateLimit, unicode.IsLetter)
+	unit, ok := units[strings.TrimPrefix(rateLimit, size)]
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q: unrecognized suffix, expected k, M or G", rateLimit)
 	}
-	indx := index([]rune(rateLimit))
-	size, suffix := rateLimit[:indx+1], rateLimit[indx+1:]
 
 	sizeFloat, err := strconv.ParseFloat(size, 64)
+	if err != nil || sizeFloat < 0 {
+		return 0, fmt.Errorf("invalid amount %q", rateLimit)
+	}
 
 	// Round of to nearest int less than sizeFloat
-	sizeN := math.Floor(sizeFloat)
-	if err != nil {
-		log.Printf("Failed to convert size rate limit %s defaulting to 0\n", rateLimit)
-		return 0
+	return int64(math.Floor(sizeFloat) * unit), nil
+}
+
+// ToDuration converts a wait period in seconds (decimal or float) to a time.Duration.
+// The suffixes m, h and d may be used to specify the period in minutes, hours or days respectively
+// example when user passes: 2 ToDuration returns 2s
+// example when user passes: 1.5m ToDuration returns 1m30s
+func ToDuration(wait string) (time.Duration, error) {
+	units := map[string]time.Duration{"": time.Second, "s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour}
+
+	wait = strings.TrimSpace(wait)
+	number := strings.TrimRightFunc(wait, unicode.IsLetter)
+	unit, ok := units[strings.TrimPrefix(wait, number)]
+	if !ok {
+		return 0, fmt.Errorf("invalid wait period %q: unrecognized suffix", wait)
 	}
 
-	if strings.TrimSpace(suffix) == "" {
-		return int64(sizeN)
+	seconds, err := strconv.ParseFloat(number, 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid wait period %q", wait)
 	}
-	if suffix == "k" {
-		return int64(sizeN * 1000)
-	} else if suffix == "M" {
-		return int64(sizeN * 1000000)
-	} else if suffix != "" {
-		log.Printf("Unrecognized rate limit suffix %q defaulting to 0\n", rateLimit)
-		return 0
+
+	return time.Duration(seconds * float64(unit)), nil
+}
+
+// ParseRateSchedule parses a comma separated list of time windows, and the rate limits within the windows.
+// The rate limits follow the same format as ToBytes; with 0 meaning no rate limiting.
+// example when user passes: "08:00-18:00=500k,18:00-08:00=0" ParseRateSchedule returns a schedule
+// that limits downloads to 500000 bytes/second from 8am to 6pm, and doesn't limit the downloads otherwise
+func ParseRateSchedule(schedule string) (ctx.RateSchedule, error) {
+	clock := func(s string) (time.Duration, error) {
+		t, err := time.Parse("15:04", strings.TrimSpace(s))
+		if err != nil {
+			return 0, fmt.Errorf("invalid time of the day %q, expected HH:MM", s)
+		}
+		return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+	}
+
+	var windows ctx.RateSchedule
+	for _, window := range strings.Split(schedule, ",") {
+		period, rate, found := strings.Cut(window, "=")
+		from, to, isRange := strings.Cut(period, "-")
+		if !found || !isRange {
+			return nil, fmt.Errorf("invalid rate schedule window %q, expected HH:MM-HH:MM=RATE", window)
+		}
+
+		var w ctx.RateWindow
+		var err error
+		if w.From, err = clock(from); err != nil {
+			return nil, err
+		}
+		if w.To, err = clock(to); err != nil {
+			return nil, err
+		}
+
+		rate = strings.TrimSpace(rate)
+		if w.Rate, err = ToBytes(rate); err != nil {
+			return nil, fmt.Errorf("invalid rate %q in rate schedule window %q", rate, window)
+		}
+		windows = append(windows, w)
 	}
-	return int64(sizeN)
+	return windows, nil
 }
 
 // ReadUrlFromFile opens fpath to read the contents of the file (urls) and returns a slice of the urls
@@ -298,11 +283,11 @@ func ReadUrlFromFile(fpath string) (links []string, err error) {
 }
 
 // IsOutputFlag checks if -O=<filename> flag has been parsed with a valid filename and returns true
-// and filename if successful else  returns false and empty string
+// and filename if successful else  returns false and empty string. The filename "-" stands for stdout
 func IsOutputFlag(arg string) (bool, string) {
 	if strings.HasPrefix(arg, "-O=") {
 		filename := strings.TrimSpace(strings.TrimPrefix(arg, "-O="))
-		if filename == "" || filename == "-" || filename == ".." || filename == "." || strings.HasPrefix(
+		if filename == "" || filename == ".." || filename == "." || strings.HasPrefix(
 			filename, "/",
 		) {
 			return false, ""
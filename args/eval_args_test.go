@@ -4,6 +4,7 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 	"wget/ctx"
 )
 
@@ -11,28 +12,37 @@ import (
 func TestToBytes(t *testing.T) {
 
 	tests := []struct {
-		name  string
-		input string
-		want  int64
+		name    string
+		input   string
+		want    int64
+		wantErr bool
 	}{
-		{"test1", "20k", 20_000},
-		{"test2", "20M", 20_000_000},
-		{"test3", "2", 2},
-		{"test4", "1KB", 0},
-		{"test5", "k", 0},
-		{"test6", "M", 0},
-		{"test7", "", 0},
-		{"test8", "200000000000M", 200_000_000_000_000_000},
-		{"test9", "1000k", 1_000_000},
-		{"test10", "1.0", 1},
-		{"test11", "14.5", 14},
-		{"test12", "15.5k", 15_000},
-		{"test13", "123.56M", 123_000_000},
+		{"test1", "20k", 20_000, false},
+		{"test2", "20M", 20_000_000, false},
+		{"test3", "2", 2, false},
+		{"test4", "1KB", 0, true},
+		{"test5", "k", 0, true},
+		{"test6", "M", 0, true},
+		{"test7", "", 0, true},
+		{"test8", "200000000000M", 200_000_000_000_000_000, false},
+		{"test9", "1000k", 1_000_000, false},
+		{"test10", "1.0", 1, false},
+		{"test11", "14.5", 14, false},
+		{"test12", "15.5k", 15_000, false},
+		{"test13", "123.56M", 123_000_000, false},
+		{"test14", "5G", 5_000_000_000, false},
+		{"test15", "1.5G", 1_000_000_000, false},
+		{"test16", "5g", 0, true},
+		{"test17", "abc", 0, true},
+		{"test18", "-5k", 0, true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := ToBytes(tt.input)
+			got, err := ToBytes(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToBytes(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
 			if got != tt.want {
 				t.Errorf("ToBytes(%q) got %d want %d", tt.input, got, tt.want)
 			}
@@ -41,6 +51,81 @@ func TestToBytes(t *testing.T) {
 
 }
 
+// TestToDuration is a test function for ToDuration function
+func TestToDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"test1", "2", 2 * time.Second, false},
+		{"test2", "0.5", 500 * time.Millisecond, false},
+		{"test3", "10s", 10 * time.Second, false},
+		{"test4", "1.5m", 90 * time.Second, false},
+		{"test5", "1h", time.Hour, false},
+		{"test6", "1d", 24 * time.Hour, false},
+		{"test7", "", 0, true},
+		{"test8", "2w", 0, true},
+		{"test9", "-1", 0, true},
+		{"test10", "m", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToDuration(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ToDuration(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ToDuration(%q) got %v want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseRateSchedule is a test function for ParseRateSchedule function
+func TestParseRateSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    ctx.RateSchedule
+		wantErr bool
+	}{
+		{
+			"test1", "08:00-18:00=500k,18:00-08:00=0",
+			ctx.RateSchedule{
+				{From: 8 * time.Hour, To: 18 * time.Hour, Rate: 500_000},
+				{From: 18 * time.Hour, To: 8 * time.Hour, Rate: 0},
+			},
+			false,
+		},
+		{
+			"test2", "00:30-01:45=2M",
+			ctx.RateSchedule{{From: 30 * time.Minute, To: time.Hour + 45*time.Minute, Rate: 2_000_000}},
+			false,
+		},
+		{"test3", "", nil, true},
+		{"test4", "08:00=500k", nil, true},
+		{"test5", "08:00-18:00", nil, true},
+		{"test6", "8am-6pm=500k", nil, true},
+		{"test7", "08:00-25:00=500k", nil, true},
+		{"test8", "08:00-18:00=500kb", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRateSchedule(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRateSchedule(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRateSchedule(%q) got %v want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
 // TestIsPathFlag is a test function for IsPathFlag function
 func TestIsPathFlag(t *testing.T) {
 	tests := []struct {
@@ -122,6 +207,7 @@ func TestIsOutputFlag(t *testing.T) {
 		{"test6", "-O=.", false, ""},
 		{"test7", "-O=..", false, ""},
 		{"test8", "-O=...", true, "..."},
+		{"stdout", "-O=-", true, "-"},
 	}
 
 	for _, tt := range tests {
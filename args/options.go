@@ -0,0 +1,620 @@
+package args
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"wget/ctx"
+	"wget/daemon"
+	"wget/fetch"
+	"wget/help"
+	"wget/progress"
+	"wget/restrict"
+	"wget/xerr"
+)
+
+// Option is a command-line option, as declared in the options table, that drives the parsing of the
+// command line, the validation of the values, and the help text
+type Option struct {
+	// Long is the long name of the option, without the dashes, e.g. "output-document"
+	Long string
+	// Short is the short name of the option, without the dash, if any, e.g. "O". Single-letter short names
+	// may be bundled, e.g. "-qc"; the GNU-style short names of many letters, e.g. "nc", can't
+	Short string
+	// Value names the value of the option in the help text, e.g. "FILE"; empty for the flags, that take no value
+	Value string
+	// Optional tells that the Value may be omitted, e.g. --save-headers[=MODE]; it then only follows an '='
+	Optional bool
+	// Help describes the option in the help text
+	Help string
+	// CommandLine tells that the option may only be given on the command line, not as a command of the
+	// startup files, or -e, e.g. --config
+	CommandLine bool
+	// Set sets the option in the download context to the given value; the flags are given an empty value on
+	// the command line, and "on" or "off" by the commands, see Command. Returns an error if the value is invalid
+	Set func(c *ctx.Context, value string) error
+}
+
+// Names returns the names of the option as in the help text, e.g. `-O | --output-document=FILE`
+func (o Option) Names() string {
+	names := "--" + o.Long
+	switch {
+	case o.Value != "" && o.Optional:
+		names += "[=" + o.Value + "]"
+	case o.Value != "":
+		names += "=" + o.Value
+	}
+	if o.Short != "" {
+		names = "-" + o.Short + " | " + names
+	}
+	return names
+}
+
+// options is the options table, in the order of the help text
+var options = []Option{
+	{
+		Long: "help", Short: "h", CommandLine: true,
+		Help: "print this manual and exit",
+		Set:  func(c *ctx.Context, _ string) error { c.IsHelp = true; return nil },
+	},
+	{
+		Long: "version", Short: "v", CommandLine: true,
+		Help: "display the current version of wget and exit",
+		Set:  func(c *ctx.Context, _ string) error { c.IsVersion = true; return nil },
+	},
+	{
+		// the background process reads the startup files again, it mustn't start another one
+		Long: "background", Short: "B", CommandLine: true,
+		Help: "download a file immediately to the background, redirecting the output to the log file (wget-log)",
+		Set:  func(c *ctx.Context, _ string) error { c.BackgroundMode = true; return nil },
+	},
+	{
+		Long: "jobs", CommandLine: true,
+		Help: "list the jobs started by -B, running and finished, with their progress, and exit",
+		Set:  func(c *ctx.Context, _ string) error { c.Jobs = true; return nil },
+	},
+	{
+		Long: "cancel", Value: "ID", CommandLine: true,
+		Help: "stop the background job ID gracefully, keeping its partial files for ‘--continue’, and exit",
+		Set: func(c *ctx.Context, v string) error {
+			if v == "" {
+				return errors.New("missing job ID, see wget --jobs")
+			}
+			c.Cancel = v
+			return nil
+		},
+	},
+	{
+		Long: "daemon", CommandLine: true,
+		Help: "run a daemon downloading a persistent queue of files, and mirrors, controlled by a local HTTP API " +
+			"served on ‘--listen’; the URLs given along are queued",
+		Set: func(c *ctx.Context, _ string) error { c.Daemon = true; return nil },
+	},
+	{
+		Long: "listen", Value: "ADDRESS", CommandLine: true,
+		Help: "serve the API of ‘--daemon’ on ADDRESS, ‘unix:PATH’ of a Unix socket only accessible " +
+			"to the user; a socket of the temporary directory by default",
+		Set: func(c *ctx.Context, v string) error {
+			if _, _, err := daemon.ParseAddress(v); err != nil {
+				return err
+			}
+			c.Listen = v
+			return nil
+		},
+	},
+	{
+		Long: "execute", Short: "e", Value: "COMMAND", CommandLine: true,
+		Help: "run COMMAND as if it were a line of the startup file, e.g. ‘-e limit_rate=20k’, " +
+			"after the startup files",
+		// set by init, the commands refer to the options table
+	},
+	{
+		// the startup files are read before the command line is parsed, see startupFiles
+		Long: "config", Value: "FILE", CommandLine: true,
+		Help: "read the startup file FILE, instead of /etc/wgetrc and ~/.wgetrc, or $WGETRC",
+		Set:  func(*ctx.Context, string) error { return nil },
+	},
+	{
+		Long: "no-config", CommandLine: true,
+		Help: "don't read any startup file",
+		Set:  func(*ctx.Context, string) error { return nil },
+	},
+	{
+		Long: "output-file", Short: "o", Value: "LOGFILE",
+		Help: "write all the messages to LOGFILE instead of the terminal, each line prefixed by the time " +
+			"it was written, and the URL it's about",
+		Set: func(c *ctx.Context, value string) error {
+			c.LogFile, c.AppendOutput = value, false
+			return logFile(value)
+		},
+	},
+	{
+		Long: "append-output", Short: "a", Value: "LOGFILE",
+		Help: "like -o, but append to LOGFILE instead of overwriting it",
+		Set: func(c *ctx.Context, value string) error {
+			c.LogFile, c.AppendOutput = value, true
+			return logFile(value)
+		},
+	},
+	{
+		Long: "debug",
+		Help: "write the debug output to the log file, or stderr if none",
+		Set:  flag(func(c *ctx.Context) *bool { return &c.Debug }),
+	},
+	{
+		Long: "server-response", Short: "S",
+		Help: "print the status line and the headers of each response, including the redirects",
+		Set:  flag(func(c *ctx.Context) *bool { return &c.ServerResponse }),
+	},
+	{
+		Long: "save-headers", Value: "MODE", Optional: true,
+		Help: "save the headers of each file before its contents, or with ‘--save-headers=sidecar’, " +
+			"to a file of their own, e.g. a.txt.headers",
+		Set: func(c *ctx.Context, value string) error {
+			// on and off, as of the other flags, in the startup files
+			switch c.SaveHeaders = strings.ToLower(value); c.SaveHeaders {
+			case "", "on":
+				c.SaveHeaders = fetch.HeadersInline
+			case "off":
+				c.SaveHeaders = ""
+				return nil
+			}
+			if c.SaveHeaders != fetch.HeadersInline && c.SaveHeaders != fetch.HeadersSidecar {
+				return fmt.Errorf("invalid --save-headers %q, want inline or sidecar", value)
+			}
+			return nil
+		},
+	},
+	{
+		Long: "output-document", Short: "O", Value: "FILE",
+		Help: "download a file and save it under a different name\n‘-O -’ writes the files to stdout, " +
+			"e.g. ‘-O - URL | tar xz’; the progress is then written to stderr",
+		Set: func(c *ctx.Context, value string) error {
+			ok, file := IsOutputFlag("-O=" + value)
+			if !ok {
+				return fmt.Errorf("invalid output document %q", value)
+			}
+			c.OutputFile = file
+			return nil
+		},
+	},
+	{
+		Long: "directory-prefix", Short: "P", Value: "PATH",
+		Help: "specify the path where to save downloaded resource",
+		Set: func(c *ctx.Context, value string) error {
+			if value == "." || value == ".." {
+				return fmt.Errorf("%v %s", xerr.ErrWrongPath, value)
+			}
+			// the directory is created by parse, once the command line has won over the startup files
+			if ok, dir := IsPathFlag("-P=" + value); ok {
+				c.SavePath = dir
+			}
+			return nil
+		},
+	},
+	{
+		Long: "rate-limit", Value: "AMOUNT",
+		Help: "Limit the download speed to AMOUNT bytes per second. Amount may be expressed in bytes, " +
+			"kilobytes with the ‘k’ suffix, or megabytes with the ‘M’ suffix. For example, " +
+			"‘--rate-limit=20k’ will limit the retrieval rate to 20KiB/s\n" +
+			"The limit is shared by all the files downloaded at once",
+		Set: func(c *ctx.Context, value string) (err error) {
+			c.RateLimit = value
+			c.RateLimitValue, err = ToBytes(value)
+			return err
+		},
+	},
+	{
+		Long: "rate-schedule", Value: "WINDOWS",
+		Help: "change the rate limit by the time of the day, e.g. " +
+			"‘--rate-schedule=08:00-18:00=500k,18:00-08:00=0’ limits the rate to 500k during business hours, " +
+			"and doesn't limit it at night",
+		Set: func(c *ctx.Context, value string) (err error) {
+			c.RateSchedule = value
+			c.RateScheduleValue, err = ParseRateSchedule(value)
+			return err
+		},
+	},
+	{
+		Long: "rate-burst", Value: "AMOUNT",
+		Help: "allow downloading up to AMOUNT bytes at once, above the rate limit after the downloads " +
+			"have been idle. Defaults to the rate limit",
+		Set: func(c *ctx.Context, value string) (err error) {
+			c.RateBurst = value
+			c.RateBurstValue, err = ToBytes(value)
+			return err
+		},
+	},
+	{
+		Long: "file-rate-limit", Value: "AMOUNT",
+		Help: "limit the download speed of each file to AMOUNT bytes per second, e.g. ‘--file-rate-limit=50k’, " +
+			"while the files downloaded at once still share the bandwidth of --rate-limit",
+		Set: func(c *ctx.Context, value string) (err error) {
+			c.FileRateLimit = value
+			c.FileRateLimitValue, err = ToBytes(value)
+			return err
+		},
+	},
+	{
+		Long: "input-file", Short: "i", Value: "FILE",
+		Help: "Read URLs from the local file",
+		Set: func(c *ctx.Context, value string) error {
+			_, file, err := InputFile("-i=" + value)
+			if err != nil {
+				return fmt.Errorf("invalid input file %q: %v", value, err)
+			}
+			c.InputFile = file
+
+			// the URLs of the other arguments are still downloaded, if the file can't be read
+			links, err := ReadUrlFromFile(file)
+			if err != nil {
+				xerr.WriteError(err, 2, false)
+			}
+			if len(links) == 0 {
+				xerr.WriteError(fmt.Sprintf("No URLs found in %v", file), 2, false)
+			}
+			c.Links = append(c.Links, links...)
+			return nil
+		},
+	},
+	{
+		Long: "mirror",
+		Help: "mirror a website",
+		Set:  flag(func(c *ctx.Context) *bool { return &c.Mirror }),
+	},
+	{
+		Long: "reject", Short: "R", Value: "LIST",
+		Help: "list of file suffixes to avoid downloading during the retrieval",
+		Set: func(c *ctx.Context, value string) error {
+			c.Rejects = append(c.Rejects, strings.Split(value, ",")...)
+			return nil
+		},
+	},
+	{
+		Long: "exclude", Short: "X", Value: "LIST",
+		Help: "list of directories excluded from the download",
+		Set: func(c *ctx.Context, value string) error {
+			c.Exclude = append(c.Exclude, strings.Split(value, ",")...)
+			return nil
+		},
+	},
+	{
+		Long: "convert-links",
+		Help: "convert the links in the document, to make them suitable for local viewing",
+		Set:  flag(func(c *ctx.Context) *bool { return &c.ConvertLinks }),
+	},
+	{
+		Long: "quota", Value: "AMOUNT",
+		Help: "stop starting new downloads once AMOUNT bytes have been downloaded, e.g. ‘--quota=5G’. " +
+			"Exits with status 9 if exceeded",
+		Set: func(c *ctx.Context, value string) (err error) {
+			c.Quota = value
+			c.QuotaValue, err = ToBytes(value)
+			return err
+		},
+	},
+	{
+		Long: "max-filesize", Value: "AMOUNT",
+		Help: "skip files larger than AMOUNT bytes, e.g. ‘--max-filesize=500M’. " +
+			"Exits with status 10 if any file was skipped",
+		Set: func(c *ctx.Context, value string) (err error) {
+			c.MaxFileSize = value
+			c.MaxFileSizeValue, err = ToBytes(value)
+			return err
+		},
+	},
+	{
+		Long: "min-free-space", Value: "AMOUNT",
+		Help: "don't start a download that would leave less than AMOUNT bytes of free disk space; " +
+			"a mirror is stopped once the space runs low",
+		Set: func(c *ctx.Context, value string) (err error) {
+			c.MinFreeSpace = value
+			c.MinFreeSpaceValue, err = ToBytes(value)
+			return err
+		},
+	},
+	{
+		Long: "content-disposition",
+		Help: "name downloaded files after the Content-Disposition header sent by the server, if any, " +
+			"rather than after the URL",
+		Set: flag(func(c *ctx.Context) *bool { return &c.ContentDisposition }),
+	},
+	{
+		Long: "adjust-extension", Short: "E",
+		Help: "append .html or .css to the names of HTML and CSS files whose URL lacks the extension, " +
+			"e.g. ‘page.php’ is saved as ‘page.php.html’",
+		Set: flag(func(c *ctx.Context) *bool { return &c.AdjustExtension }),
+	},
+	{
+		Long: "ignore-query-params", Value: "LIST",
+		Help: "comma separated glob patterns of query parameters to drop from the mirrored URLs, " +
+			"e.g. ‘utm_*,sessionid’",
+		Set: func(c *ctx.Context, value string) error {
+			for _, pattern := range strings.Split(value, ",") {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return fmt.Errorf("invalid --ignore-query-params pattern %q: %v", pattern, err)
+				}
+				c.IgnoreQueryParams = append(c.IgnoreQueryParams, strings.TrimSpace(pattern))
+			}
+			return nil
+		},
+	},
+	{
+		Long: "no-directories", Short: "nd",
+		Help: "save all mirrored files into one directory; files of the same name are numbered, " +
+			"e.g. ‘a(1).txt’",
+		Set: flag(func(c *ctx.Context) *bool { return &c.NoDirectories }),
+	},
+	{
+		Long: "no-host-directories", Short: "nH",
+		Help: "don't create the host directories of mirrored files",
+		Set:  flag(func(c *ctx.Context) *bool { return &c.NoHostDirectories }),
+	},
+	{
+		Long: "protocol-directories",
+		Help: "nest host directories in directories named after the scheme, e.g. ‘https/example.com’",
+		Set:  flag(func(c *ctx.Context) *bool { return &c.ProtocolDirectories }),
+	},
+	{
+		Long: "cut-dirs", Value: "N",
+		Help: "omit the first N directories of the URL paths from the paths of mirrored files, " +
+			"e.g. with 1, ‘/a/b/c.txt’ is saved as ‘b/c.txt’",
+		Set: func(c *ctx.Context, value string) (err error) {
+			c.CutDirs, err = count(value)
+			if err != nil {
+				return fmt.Errorf("invalid number of directories to cut: %q", value)
+			}
+			return nil
+		},
+	},
+	{
+		Long: "restrict-file-names", Value: "MODES",
+		Help: "escape the characters unsafe in filenames as %XX, by a comma separated list of: " +
+			"unix, windows, nocontrol, ascii, lowercase",
+		Set: func(c *ctx.Context, value string) (err error) {
+			c.RestrictFileNames = value
+			c.RestrictFileNamesValue, err = restrict.Parse(value)
+			return err
+		},
+	},
+	{
+		Long: "continue", Short: "c",
+		Help: "continue the partially downloaded files (*.part) of interrupted downloads, " +
+			"rather than downloading them anew",
+		Set: flag(func(c *ctx.Context) *bool { return &c.Continue }),
+	},
+	{
+		Long: "keep-partial",
+		Help: "keep the partial files (*.part) of failed downloads",
+		Set:  flag(func(c *ctx.Context) *bool { return &c.KeepPartial }),
+	},
+	{
+		Long: "no-clobber", Short: "nc",
+		Help: "don't download files that already exist",
+		Set:  clobber("no-clobber"),
+	},
+	{
+		Long: "overwrite",
+		Help: "replace existing files; the default when mirroring",
+		Set:  clobber("overwrite"),
+	},
+	{
+		Long: "backups", Value: "N",
+		Help: "rename existing files to FILE.1, keeping up to N backups",
+		Set: func(c *ctx.Context, value string) (err error) {
+			c.Clobber = "backups"
+			c.Backups, err = count(value)
+			if err != nil {
+				return fmt.Errorf("invalid number of backups: %q", value)
+			}
+			return nil
+		},
+	},
+	{
+		Long: "unique-names",
+		Help: "save downloads as FILE(1), FILE(2), ... if FILE already exists; " +
+			"the default when downloading files",
+		Set: clobber("unique-names"),
+	},
+	{
+		Long: "wait", Value: "SECONDS",
+		Help: "wait SECONDS between requests to the same host. The delay grows when the host responds " +
+			"with 429 or 503, asking us to slow down",
+		Set: func(c *ctx.Context, value string) (err error) {
+			c.Wait, err = ToDuration(value)
+			return err
+		},
+	},
+	{
+		Long: "random-wait",
+		Help: "wait from 0.5*WAIT to 1.5*WAIT seconds between requests",
+		Set:  flag(func(c *ctx.Context) *bool { return &c.RandomWait }),
+	},
+	{
+		Long: "quiet", Short: "q",
+		Help: "turn off the output",
+		Set:  verbosity(progress.Quiet),
+	},
+	{
+		Long: "no-verbose", Short: "nv",
+		Help: "report a single line per download, and errors",
+		Set:  verbosity(progress.NoVerbose),
+	},
+	{
+		Long: "verbose",
+		Help: "report the requests, responses and progress; the default",
+		Set:  verbosity(progress.Verbose),
+	},
+	{
+		Long: "progress", Value: "TYPE",
+		Help: "draw the progress as a ‘bar’, as ‘dot’ or ‘dot:mega’ rows of dots, or ‘none’. " +
+			"Bars fall back to dots when the output isn't a terminal, unless ‘bar:force’",
+		Set: func(c *ctx.Context, value string) error {
+			c.Progress = value
+			_, err := progress.ParseStyle(value)
+			return err
+		},
+	},
+	{
+		Long: "output-format", Value: "FORMAT",
+		Help: "‘text’, the default, or ‘jsonl’: report each event of the downloads, and a final summary, " +
+			"as a JSON object per line",
+		Set: func(c *ctx.Context, value string) error {
+			c.OutputFormat = strings.ToLower(value)
+			if c.OutputFormat != progress.Text && c.OutputFormat != progress.JSONL {
+				return fmt.Errorf("invalid --output-format %q, want text or jsonl", value)
+			}
+			return nil
+		},
+	},
+}
+
+func init() {
+	for i := range options {
+		if options[i].Long == "execute" {
+			options[i].Set = Command
+		}
+	}
+}
+
+// HelpOptions returns the rows of the options table of the help text, see help.PrintManPage
+func HelpOptions() []help.Option {
+	rows := make([]help.Option, len(options))
+	for i, option := range options {
+		rows[i] = help.Option{Names: option.Names(), Help: option.Help}
+	}
+	return rows
+}
+
+// lookupLong returns the option of the given long name, without the dashes, or false if none
+func lookupLong(name string) (Option, bool) {
+	for _, option := range options {
+		if option.Long == name {
+			return option, true
+		}
+	}
+	return Option{}, false
+}
+
+// lookupShort returns the option of the given short name, without the dash, or false if none
+func lookupShort(name string) (Option, bool) {
+	for _, option := range options {
+		if option.Short != "" && option.Short == name {
+			return option, true
+		}
+	}
+	return Option{}, false
+}
+
+// suggest returns the long name, with the dashes, of the option closest to the given mistyped name,
+// e.g. "--mirror" for "mirorr", or an empty string if none is close enough
+func suggest(name string) string {
+	name = strings.ToLower(strings.TrimLeft(name, "-"))
+	best, bestDistance := "", 0
+	for _, option := range options {
+		d := distance(name, option.Long)
+		if strings.HasPrefix(option.Long, name) && len(name) >= 3 {
+			// an abbreviation, e.g. "mirr"
+			d = 1
+		}
+		if d <= max(2, len(option.Long)/4) && (best == "" || d < bestDistance) {
+			best, bestDistance = "--"+option.Long, d
+		}
+	}
+	return best
+}
+
+// distance returns the edit distance of the given strings, i.e. the number of characters to insert, delete,
+// or replace in one to get the other, or of the adjacent characters to swap, e.g. 1 for "quite" and "quiet"
+func distance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			replace := d[i-1][j-1]
+			if a[i-1] != b[j-1] {
+				replace++
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, replace)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+// flag returns the setter of the boolean flag of the given field, see Option.Set
+func flag(field func(c *ctx.Context) *bool) func(c *ctx.Context, value string) error {
+	return func(c *ctx.Context, value string) (err error) {
+		*field(c), err = toggle(value)
+		return err
+	}
+}
+
+// verbosity returns the setter of the given verbosity, e.g. progress.Quiet; turning it off restores
+// the default verbosity, unless another one was set since
+func verbosity(level string) func(c *ctx.Context, value string) error {
+	return func(c *ctx.Context, value string) error {
+		on, err := toggle(value)
+		switch {
+		case on:
+			c.Verbosity = level
+		case c.Verbosity == level:
+			c.Verbosity = ""
+		}
+		return err
+	}
+}
+
+// clobber returns the setter of the given way of handling the existing files, e.g. "no-clobber"; turning it
+// off restores the default, unless another way was set since
+func clobber(mode string) func(c *ctx.Context, value string) error {
+	return func(c *ctx.Context, value string) error {
+		on, err := toggle(value)
+		switch {
+		case on:
+			c.Clobber = mode
+		case c.Clobber == mode:
+			c.Clobber = ""
+		}
+		return err
+	}
+}
+
+// toggle parses the value of a flag: empty, as on the command line, or "on", to turn it on, or "off";
+// "yes", "true" and "1" are on too, and "no", "false" and "0" are off
+func toggle(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "", "on", "yes", "true", "1":
+		return true, nil
+	case "off", "no", "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid value %q, want on or off", value)
+}
+
+// count parses the given number of things, e.g. directories, that can't be negative
+func count(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err == nil && n < 0 {
+		err = errors.New("negative count")
+	}
+	return n, err
+}
+
+// logFile checks the name of the log file given to -o, or -a
+func logFile(name string) error {
+	if name == "" {
+		return errors.New("missing log file")
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package args
+
+import (
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	names := map[string]bool{}
+	for _, option := range options {
+		for _, name := range []string{"--" + option.Long, "-" + option.Short} {
+			if name != "-" && names[name] {
+				t.Errorf("option %s is declared twice", name)
+			}
+			names[name] = true
+		}
+		if option.Long == "" || option.Help == "" || option.Set == nil {
+			t.Errorf("option %q lacks its long name, help, or setter", option.Names())
+		}
+		if option.Short != "" && len(option.Short) > 1 && option.Value != "" {
+			// the short names of many letters are never bundled, so their values would be ambiguous
+			t.Errorf("option %q of many letters takes a value", option.Names())
+		}
+	}
+}
+
+func TestOption_Names(t *testing.T) {
+	tests := []struct {
+		option Option
+		want   string
+	}{
+		{Option{Long: "mirror"}, "--mirror"},
+		{Option{Long: "quiet", Short: "q"}, "-q | --quiet"},
+		{Option{Long: "output-document", Short: "O", Value: "FILE"}, "-O | --output-document=FILE"},
+		{Option{Long: "save-headers", Value: "MODE", Optional: true}, "--save-headers[=MODE]"},
+	}
+	for _, tt := range tests {
+		if got := tt.option.Names(); got != tt.want {
+			t.Errorf("Names() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"mirorr", "--mirror"},
+		{"--quite", "--quiet"},
+		{"contine", "--continue"},
+		{"progres", "--progress"},
+		{"server-resp", "--server-response"},
+		{"xyz", ""},
+		{"q", ""},
+	}
+	for _, tt := range tests {
+		if got := suggest(tt.name); got != tt.want {
+			t.Errorf("suggest(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"mirror", "mirror", 0},
+		{"mirorr", "mirror", 1},
+		{"quite", "quiet", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("distance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
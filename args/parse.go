@@ -0,0 +1,216 @@
+package args
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"wget/ctx"
+	"wget/fetch"
+	"wget/help"
+	"wget/xerr"
+	"wget/xurl"
+)
+
+// Parse parses the command-line arguments into a download context, as declared by the options table,
+// after running the commands of the startup files, see startupFiles; the command line wins over the files.
+// The values of the options follow them after an '=', or as the next argument, e.g. `-O=file`, `-O file`,
+// `-Ofile` or `--output-document file`. The single-letter short options may be bundled, e.g. `-qc`, with
+// the last one taking a value, if any, e.g. `-qO file`. All the arguments after `--` are URLs, even those
+// starting with a dash.
+//
+// The errors, e.g. of unknown options, exit the program with xerr.ParseStatus; the invalid URLs are
+// reported, and skipped
+func Parse(arguments []string) (ctx.Context, error) {
+	c, _, err := parse(arguments)
+	return c, err
+}
+
+// parse parses the command-line arguments, as Parse does, and also returns the arguments without the
+// background option, -B, for the background process to be started with
+func parse(arguments []string) (c ctx.Context, foreground []string, err error) {
+	defer func() {
+		// only the directory of the last -P is created, not those it overrides, e.g. of the startup files
+		if err == nil && c.SavePath != "" {
+			c.SavePath = CreateDirFromPath(c.SavePath)
+		}
+	}()
+	for _, file := range startupFiles(arguments) {
+		warnings, err := Load(&c, file.name)
+		for _, warning := range warnings {
+			xerr.WriteError(warning, 0, false)
+		}
+		if errors.Is(err, fs.ErrNotExist) && !file.required {
+			continue
+		}
+		if err != nil {
+			return c, nil, xerr.WithStatus(xerr.ParseStatus, err)
+		}
+	}
+
+	for i := 0; i < len(arguments); i++ {
+		arg := arguments[i]
+		// next returns the next argument, as the value of the given option
+		next := func(name string) (string, error) {
+			if i+1 >= len(arguments) {
+				return "", parseError("option %s requires a value", name)
+			}
+			i++
+			foreground = append(foreground, arguments[i])
+			return arguments[i], nil
+		}
+
+		switch {
+		case arg == "--":
+			for _, arg := range arguments[i+1:] {
+				link(&c, arg)
+			}
+			foreground = append(foreground, arguments[i:]...)
+			return c, foreground, nil
+
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			option, ok := lookupLong(name)
+			if !ok {
+				return c, nil, unknown(arg, "--"+name)
+			}
+			if option.Long != "background" {
+				foreground = append(foreground, arg)
+			}
+			switch {
+			case option.Value == "" && hasValue:
+				return c, nil, parseError("option --%s doesn't allow a value", name)
+			case option.Value != "" && !hasValue && !option.Optional:
+				if value, err = next(arg); err != nil {
+					return c, nil, err
+				}
+			}
+			if err = option.Set(&c, value); err != nil {
+				return c, nil, xerr.WithStatus(xerr.ParseStatus, err)
+			}
+
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// the short names of many letters, e.g. -nc, aren't bundled
+			if option, ok := lookupShort(arg[1:]); ok && len(option.Short) > 1 {
+				foreground = append(foreground, arg)
+				if err = option.Set(&c, ""); err != nil {
+					return c, nil, xerr.WithStatus(xerr.ParseStatus, err)
+				}
+				continue
+			}
+			bundle := "-"
+			for j := 1; j < len(arg); j++ {
+				option, ok := lookupShort(arg[j : j+1])
+				if !ok {
+					return c, nil, unknown(arg, "-"+arg[j:j+1])
+				}
+				if option.Long != "background" {
+					bundle += arg[j : j+1]
+				}
+				var value string
+				if option.Value != "" {
+					// the value is the rest of the argument, after any '=', or the next argument
+					value = strings.TrimPrefix(arg[j+1:], "=")
+					bundle += arg[j+1:]
+					j = len(arg)
+					if value == "" {
+						if len(bundle) > 1 {
+							foreground = append(foreground, bundle)
+							bundle = "-"
+						}
+						if value, err = next("-" + option.Short); err != nil {
+							return c, nil, err
+						}
+					}
+				}
+				if err = option.Set(&c, value); err != nil {
+					return c, nil, xerr.WithStatus(xerr.ParseStatus, err)
+				}
+			}
+			if len(bundle) > 1 {
+				foreground = append(foreground, bundle)
+			}
+
+		default:
+			foreground = append(foreground, arg)
+			link(&c, arg)
+		}
+	}
+	return c, foreground, nil
+}
+
+// link adds the given argument to the links to download, or reports it if it isn't a valid URL
+func link(c *ctx.Context, arg string) {
+	url, isValid, err := xurl.IsValidURL(arg)
+	if !isValid {
+		xerr.WriteError(err, 1, false)
+		return
+	}
+	c.Links = append(c.Links, url)
+}
+
+// unknown returns the error of the unknown option of the given name, e.g. "--mirorr", in the given argument,
+// suggesting the closest option, if any, e.g. `unknown option --mirorr, did you mean --mirror?`
+func unknown(arg, name string) error {
+	message := "unknown option " + name
+	// a long name given as a short option, e.g. -mirror, is suggested too
+	long, _, _ := strings.Cut(arg, "=")
+	if long != name {
+		message += fmt.Sprintf(" in %q", arg)
+	}
+	if suggestion := suggest(long); suggestion != "" {
+		message += fmt.Sprintf(", did you mean %s?", suggestion)
+	}
+	return parseError("%s\nTry 'wget --help' for more options.", message)
+}
+
+// parseError returns the error of the given message, that exits the program with xerr.ParseStatus
+func parseError(format string, a ...any) error {
+	return xerr.WithStatus(xerr.ParseStatus, fmt.Errorf(format, a...))
+}
+
+// Validate checks the download context for the options that can't be used together, and for
+// the missing URLs; returns an error, exiting the program with xerr.ParseStatus, if any, or with
+// xerr.GenericStatus for the missing URLs, as without any arguments
+func Validate(c ctx.Context) error {
+	var message string
+	switch {
+	case c.IsHelp || c.IsVersion || c.Jobs || c.Cancel != "":
+		return nil
+
+	case len(c.Links) == 0 && c.InputFile == "" && !c.Daemon:
+		return xerr.WithStatus(xerr.GenericStatus, errors.New(help.UsageMessage))
+
+	case c.ConvertLinks && !c.Mirror:
+		message = "option --convert-links is on but --mirror is off"
+
+	case (len(c.Exclude) != 0 || len(c.Rejects) != 0) && !c.Mirror:
+		message = "options [--exclude short hand -X; --reject short hand -R] are only valid in --mirror mode"
+
+	case c.Mirror && c.OutputFile != "":
+		message = "option --mirror with -O specified is ambiguous"
+
+	case c.Daemon && c.OutputFile != "":
+		message = "option --daemon with -O specified is ambiguous, give the output file of each job to the API"
+
+	case c.Listen != "" && !c.Daemon:
+		message = "option --listen is only valid with --daemon"
+
+	case c.OutputFile == "-" && (c.Continue || c.BackgroundMode):
+		message = "options --continue and -B can't be used with -O -, writing to stdout"
+
+	case c.SaveHeaders == fetch.HeadersInline && c.Continue:
+		message = "option --save-headers can't be used with --continue, " +
+			"use --save-headers=sidecar to save the headers to files of their own"
+
+	case c.SaveHeaders == fetch.HeadersSidecar && c.OutputFile == "-":
+		message = "option --save-headers=sidecar can't be used with -O -, writing to stdout"
+
+	case len(c.Links) > 1 && c.OutputFile != "" && c.OutputFile != "-":
+		message = "many URLs to download but -O is specified, this is ambiguous"
+
+	default:
+		return nil
+	}
+	return parseError("bad format: %s", message)
+}
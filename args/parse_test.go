@@ -0,0 +1,197 @@
+package args
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"wget/ctx"
+	"wget/fetch"
+	"wget/progress"
+	"wget/xerr"
+)
+
+func TestParse(t *testing.T) {
+	const url = "https://example.com/a.txt"
+	tests := []struct {
+		name      string
+		arguments []string
+		want      ctx.Context
+	}{
+		{"equals", []string{"-O=a.txt", url}, ctx.Context{OutputFile: "a.txt", Links: []string{url}}},
+		{"space", []string{"-O", "a.txt", url}, ctx.Context{OutputFile: "a.txt", Links: []string{url}}},
+		{"attached", []string{"-Oa.txt", url}, ctx.Context{OutputFile: "a.txt", Links: []string{url}}},
+		{"stdout", []string{"-O", "-", url}, ctx.Context{OutputFile: "-", Links: []string{url}}},
+		{
+			"long space", []string{"--output-document", "a.txt", url},
+			ctx.Context{OutputFile: "a.txt", Links: []string{url}},
+		},
+		{
+			"long equals", []string{url, "--output-document=a.txt"},
+			ctx.Context{OutputFile: "a.txt", Links: []string{url}},
+		},
+		{
+			"bundled", []string{"-qc", url},
+			ctx.Context{Verbosity: progress.Quiet, Continue: true, Links: []string{url}},
+		},
+		{
+			"bundled value", []string{"-qO", "a.txt", url},
+			ctx.Context{Verbosity: progress.Quiet, OutputFile: "a.txt", Links: []string{url}},
+		},
+		{
+			"many letters", []string{"-nc", "-nv", url},
+			ctx.Context{Clobber: "no-clobber", Verbosity: progress.NoVerbose, Links: []string{url}},
+		},
+		{
+			"lists", []string{"-R", "jpg,gif", "--reject=png", "--mirror", url},
+			ctx.Context{Rejects: []string{"jpg", "gif", "png"}, Mirror: true, Links: []string{url}},
+		},
+		{
+			"optional value", []string{"--save-headers", url, "--save-headers=sidecar"},
+			ctx.Context{SaveHeaders: fetch.HeadersSidecar, Links: []string{url}},
+		},
+		{
+			"dashes", []string{"-c", "--", url, "-B"},
+			ctx.Context{Continue: true, Links: []string{url}},
+		},
+		{"help", []string{"-h"}, ctx.Context{IsHelp: true}},
+		{"version", []string{"--version"}, ctx.Context{IsVersion: true}},
+		{"jobs", []string{"--jobs"}, ctx.Context{Jobs: true}},
+		{"cancel", []string{"--cancel", "3f9a2c1b"}, ctx.Context{Cancel: "3f9a2c1b"}},
+		{
+			"daemon", []string{"--daemon", "--listen=unix:/run/wget.sock"},
+			ctx.Context{Daemon: true, Listen: "unix:/run/wget.sock"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.arguments)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments []string
+		want      string
+	}{
+		{"unknown", []string{"--mirorr"}, "unknown option --mirorr, did you mean --mirror?"},
+		{"unknown value", []string{"--rate-limt=20k"}, "unknown option --rate-limt, did you mean --rate-limit?"},
+		{"abbreviated", []string{"--conv"}, "unknown option --conv, did you mean --convert-links?"},
+		{"single dash", []string{"-mirror"}, `unknown option -m in "-mirror", did you mean --mirror?`},
+		{"no suggestion", []string{"--frobnicate"}, "unknown option --frobnicate\n"},
+		{"bundled", []string{"-qz"}, `unknown option -z in "-qz"`},
+		{"missing value", []string{"https://example.com", "-O"}, "option -O requires a value"},
+		{"missing long value", []string{"--output-document"}, "option --output-document requires a value"},
+		{"flag value", []string{"--mirror=yes"}, "option --mirror doesn't allow a value"},
+		{"invalid value", []string{"--cut-dirs", "-1"}, `invalid number of directories to cut: "-1"`},
+		{"invalid output", []string{"-O", "/"}, `invalid output document "/"`},
+		{"invalid format", []string{"--output-format=xml"}, `invalid --output-format "xml"`},
+		{"invalid quota", []string{"--quota=abc"}, `invalid amount "abc"`},
+		{"invalid max filesize", []string{"--max-filesize", "5g"}, `invalid amount "5g"`},
+		{"missing job", []string{"--cancel="}, "missing job ID"},
+		{"invalid listen", []string{"--daemon", "--listen", "/run/wget.sock"}, `invalid --listen "/run/wget.sock"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.arguments)
+			if err == nil {
+				t.Fatalf("Parse() error = nil, want %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %q, want %q", err, tt.want)
+			}
+			if status := xerr.ExitStatus(err); status != xerr.ParseStatus {
+				t.Errorf("ExitStatus() = %d, want %d", status, xerr.ParseStatus)
+			}
+		})
+	}
+}
+
+func TestParse_Foreground(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments []string
+		want      []string
+	}{
+		{"short", []string{"-B", "-O", "a.txt", "URL"}, []string{"-O", "a.txt", "URL"}},
+		{"long", []string{"--background", "URL", "-B"}, []string{"URL"}},
+		{"bundled", []string{"-qBc", "URL"}, []string{"-qc", "URL"}},
+		{"bundled value", []string{"-BO", "a.txt", "URL"}, []string{"-O", "a.txt", "URL"}},
+		{"bundled attached value", []string{"-qBO=a.txt", "URL"}, []string{"-qO=a.txt", "URL"}},
+		{"dashes", []string{"-B", "--", "-B"}, []string{"--", "-B"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, err := parse(tt.arguments)
+			if err != nil {
+				t.Fatalf("parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse() foreground = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	links := []string{"https://example.com/a.txt"}
+	tests := []struct {
+		name       string
+		c          ctx.Context
+		want       string
+		wantStatus int
+	}{
+		{"valid", ctx.Context{Links: links, OutputFile: "a.txt"}, "", 0},
+		{"help", ctx.Context{IsHelp: true}, "", 0},
+		{"jobs", ctx.Context{Jobs: true}, "", 0},
+		{"cancel", ctx.Context{Cancel: "3f9a2c1b"}, "", 0},
+		{"daemon", ctx.Context{Daemon: true}, "", 0},
+		{"daemon output", ctx.Context{Daemon: true, OutputFile: "a"}, "ambiguous", xerr.ParseStatus},
+		{"listen", ctx.Context{Links: links, Listen: "unix:/run/wget.sock"}, "--daemon", xerr.ParseStatus},
+		{"input file", ctx.Context{InputFile: "urls.txt"}, "", 0},
+		{"missing URL", ctx.Context{Continue: true}, "missing URL", xerr.GenericStatus},
+		{"convert links", ctx.Context{Links: links, ConvertLinks: true}, "--convert-links", xerr.ParseStatus},
+		{"reject", ctx.Context{Links: links, Rejects: []string{"jpg"}}, "--mirror mode", xerr.ParseStatus},
+		{"mirror output", ctx.Context{Links: links, Mirror: true, OutputFile: "a"}, "ambiguous", xerr.ParseStatus},
+		{
+			"stdout continue", ctx.Context{Links: links, OutputFile: "-", Continue: true},
+			"writing to stdout", xerr.ParseStatus,
+		},
+		{
+			"inline continue", ctx.Context{Links: links, SaveHeaders: fetch.HeadersInline, Continue: true},
+			"--save-headers=sidecar", xerr.ParseStatus,
+		},
+		{
+			"sidecar stdout", ctx.Context{Links: links, SaveHeaders: fetch.HeadersSidecar, OutputFile: "-"},
+			"writing to stdout", xerr.ParseStatus,
+		},
+		{
+			"many URLs", ctx.Context{Links: append(links, "https://example.com/b.txt"), OutputFile: "a"},
+			"many URLs", xerr.ParseStatus,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.c)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.want)
+			}
+			if status := xerr.ExitStatus(err); status != tt.wantStatus {
+				t.Errorf("ExitStatus() = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package args
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"wget/ctx"
+	"wget/fileio"
+)
+
+// systemWgetrc is the system-wide startup file, read before the user's
+var systemWgetrc = "/etc/wgetrc"
+
+// errUnknownCommand is the error of the commands naming no option; only a warning in the startup files
+var errUnknownCommand = errors.New("unknown command")
+
+// aliases maps the names of the commands of GNU Wget, as normalized by command, to the long names of the options
+// they stand for, where the names differ
+var aliases = map[string]string{
+	"dirprefix":          "directory-prefix",
+	"excludedirectories": "exclude",
+	"input":              "input-file",
+	"limitrate":          "rate-limit",
+	"logfile":            "output-file",
+}
+
+// gnuCommands are the names of the commands of GNU Wget, as normalized by Command; those naming no option
+// here are skipped silently, for the startup files shared with GNU Wget, e.g. a stock /etc/wgetrc
+var gnuCommands = map[string]bool{
+	"accept": true, "acceptregex": true, "addhostdir": true, "adjustextension": true, "askpassword": true,
+	"authnochallenge": true, "background": true, "backupconverted": true, "backups": true, "base": true,
+	"bindaddress": true, "bindtodevice": true, "bodydata": true, "bodyfile": true, "cacertificate": true,
+	"cadirectory": true, "cache": true, "certificate": true, "certificatetype": true, "checkcertificate": true,
+	"chooseconfig": true, "ciphers": true, "compression": true, "connecttimeout": true,
+	"contentdisposition": true, "contentonerror": true, "continue": true, "convertfilenameonly": true,
+	"convertlinks": true, "cookies": true, "crlfile": true, "cutdirs": true, "debug": true, "defaultpage": true,
+	"deleteafter": true, "dirprefix": true, "dirstruct": true, "dnscache": true, "dnstimeout": true,
+	"domains": true, "dotbytes": true, "dotsinline": true, "dotspacing": true, "dotstyle": true, "egdfile": true,
+	"excludedirectories": true, "excludedomains": true, "followftp": true, "followtags": true, "forcehtml": true,
+	"ftppasswd": true, "ftppassword": true, "ftpproxy": true, "ftpsclearingdataconnection": true,
+	"ftpsfallbacktoftp": true, "ftpsimplicit": true, "ftpsresumessl": true, "ftpuser": true, "glob": true,
+	"header": true, "hsts": true, "hstsfile": true, "htmlextension": true, "htmlify": true, "httpkeepalive": true,
+	"httppasswd": true, "httppassword": true, "httpproxy": true, "httpsonly": true, "httpsproxy": true,
+	"httpuser": true, "ifmodifiedsince": true, "ignorecase": true, "ignorelength": true, "ignoretags": true,
+	"includedirectories": true, "inet4only": true, "inet6only": true, "input": true, "iri": true,
+	"keepbadhash": true, "keepsessioncookies": true, "limitrate": true, "loadcookies": true,
+	"localencoding": true, "locale": true, "logfile": true, "login": true, "maxredirect": true, "method": true,
+	"mirror": true, "netrc": true, "noclobber": true, "noparent": true, "noproxy": true, "numtries": true,
+	"outputdocument": true, "pagerequisites": true, "passiveftp": true, "passwd": true, "password": true,
+	"pinnedpubkey": true, "postdata": true, "postfile": true, "preferfamily": true, "preservepermissions": true,
+	"privatekey": true, "privatekeytype": true, "progress": true, "protocoldirectories": true,
+	"proxypasswd": true, "proxypassword": true, "proxyuser": true, "quiet": true, "quota": true,
+	"randomfile": true, "randomwait": true, "readtimeout": true, "reclevel": true, "recursive": true,
+	"referer": true, "regextype": true, "reject": true, "rejectedlog": true, "rejectregex": true,
+	"relativeonly": true, "remoteencoding": true, "removelisting": true, "reportspeed": true,
+	"restrictfilenames": true, "retrsymlinks": true, "retryconnrefused": true, "retryonhosterror": true,
+	"retryonhttperror": true, "robots": true, "savecookies": true, "saveheaders": true, "secureprotocol": true,
+	"serverresponse": true, "showprogress": true, "spanhosts": true, "spider": true, "startpos": true,
+	"strictcomments": true, "timeout": true, "timestamping": true, "tries": true, "trustservernames": true,
+	"unlink": true, "useaskpass": true, "useproxy": true, "user": true, "useragent": true,
+	"useservertimestamps": true, "verbose": true, "wait": true, "waitretry": true, "warccdx": true, "warccdxdedup": true,
+	"warccompression": true, "warcdigests": true, "warcfile": true, "warcheader": true, "warckeeplog": true,
+	"warcmaxsize": true, "warctempdir": true, "xattr": true,
+}
+
+// startupFile is a startup file to read; it's skipped if it doesn't exist, unless required
+type startupFile struct {
+	name     string
+	required bool
+}
+
+// startupFiles returns the startup files to read before the command line is parsed, in order: /etc/wgetrc, and
+// ~/.wgetrc, or the file named by $WGETRC instead; or only the file given to --config; or none with --no-config
+func startupFiles(arguments []string) (files []startupFile) {
+	var config string
+scan:
+	for i, arg := range arguments {
+		switch {
+		case arg == "--":
+			break scan
+		case arg == "--no-config":
+			return nil
+		case arg == "--config" && i+1 < len(arguments):
+			config = arguments[i+1]
+		case strings.HasPrefix(arg, "--config="):
+			config = strings.TrimPrefix(arg, "--config=")
+		}
+	}
+	if config != "" {
+		return []startupFile{{config, true}}
+	}
+
+	files = append(files, startupFile{systemWgetrc, false})
+	if name := os.Getenv("WGETRC"); name != "" {
+		return append(files, startupFile{name, true})
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, startupFile{filepath.Join(home, ".wgetrc"), false})
+	}
+	return files
+}
+
+// Load runs the commands of the named startup file, one per line, e.g.
+//
+//	# limit the rate of all the downloads
+//	limit_rate = 20k
+//	continue = on
+//
+// The unknown commands are returned as warnings, e.g. `/home/user/.wgetrc:3: unknown command "proxy"`, and
+// skipped, as are those of GNU Wget, silently; the invalid values are returned as an error, with their line
+// number too. See Command
+func Load(c *ctx.Context, name string) (warnings []string, err error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fileio.Close(file)
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		command := strings.TrimSpace(scanner.Text())
+		if command == "" || strings.HasPrefix(command, "#") {
+			continue
+		}
+		err = Command(c, command)
+		if errors.Is(err, errUnknownCommand) {
+			warnings = append(warnings, fmt.Sprintf("%s:%d: %v", name, line, err))
+		} else if err != nil {
+			return warnings, fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+	}
+	return warnings, scanner.Err()
+}
+
+// Command runs the given command, as a line of the startup files, or given to -e, e.g. "limit_rate = 20k".
+// Commands are named after the long names of the options, or after GNU Wget's commands, in any case, and
+// with or without the dashes, or underscores, e.g. "rate-limit", "limit_rate" or "LimitRate". The flags
+// are set "on" or "off", e.g. "mirror = on". The commands of GNU Wget naming no option here, e.g.
+// "passive_ftp", do nothing
+func Command(c *ctx.Context, command string) error {
+	name, value, ok := strings.Cut(command, "=")
+	if !ok {
+		return fmt.Errorf("invalid command %q, want NAME = VALUE", command)
+	}
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+	normalized := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+	if long, ok := aliases[normalized]; ok {
+		normalized = long
+	}
+	for _, option := range options {
+		if option.CommandLine || (option.Long != normalized && strings.ReplaceAll(option.Long, "-", "") != normalized) {
+			continue
+		}
+		if option.Value == "" && value == "" {
+			return fmt.Errorf("missing value of %q, want on or off", name)
+		}
+		return option.Set(c, value)
+	}
+	if gnuCommands[normalized] && !commandLineOnly(normalized) {
+		return nil
+	}
+	return fmt.Errorf("%w %q", errUnknownCommand, name)
+}
+
+// commandLineOnly reports whether the given normalized name is of an option only valid on the command line,
+// e.g. "background", that is still reported in the startup files, even though GNU Wget has a command of that name
+func commandLineOnly(normalized string) bool {
+	for _, option := range options {
+		if option.CommandLine && strings.ReplaceAll(option.Long, "-", "") == normalized {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package args
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"wget/ctx"
+	"wget/progress"
+	"wget/xerr"
+)
+
+// TestMain keeps the startup files of the machine out of the tests
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "wgetrc")
+	if err != nil {
+		panic(err)
+	}
+	systemWgetrc = filepath.Join(home, "system-wgetrc")
+	_ = os.Setenv("HOME", home)
+	_ = os.Unsetenv("WGETRC")
+	code := m.Run()
+	_ = os.RemoveAll(home)
+	os.Exit(code)
+}
+
+// writeWgetrc writes the given startup file into a temporary directory, returning its name
+func writeWgetrc(t *testing.T, content string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "wgetrc")
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    ctx.Context
+	}{
+		{"mirror = on", ctx.Context{Mirror: true}},
+		{"Mirror=yes", ctx.Context{Mirror: true}},
+		{"mirror = off", ctx.Context{}},
+		{"limit_rate = 20k", ctx.Context{RateLimit: "20k", RateLimitValue: 20000}},
+		{"rate-limit = 20k", ctx.Context{RateLimit: "20k", RateLimitValue: 20000}},
+		{"RateLimit = 20k", ctx.Context{RateLimit: "20k", RateLimitValue: 20000}},
+		{"file_rate_limit = 50k", ctx.Context{FileRateLimit: "50k", FileRateLimitValue: 50000}},
+		{"quiet = on", ctx.Context{Verbosity: progress.Quiet}},
+		{"no_clobber = on", ctx.Context{Clobber: "no-clobber"}},
+		{"save_headers = on", ctx.Context{SaveHeaders: "inline"}},
+		{"reject = jpg,gif", ctx.Context{Rejects: []string{"jpg", "gif"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			var got ctx.Context
+			if err := Command(&got, tt.command); err != nil {
+				t.Fatalf("Command() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Command() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommand_Errors(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"proxy = on", `unknown command "proxy"`},
+		{"background = on", `unknown command "background"`},
+		{"config = other", `unknown command "config"`},
+		{"mirror", `invalid command "mirror"`},
+		{"mirror =", `missing value of "mirror"`},
+		{"mirror = maybe", `invalid value "maybe"`},
+		{"cut_dirs = x", "invalid number of directories to cut"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			var c ctx.Context
+			if err := Command(&c, tt.command); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Command() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommand_GNU(t *testing.T) {
+	// the commands of GNU Wget naming no option here are skipped silently
+	for _, command := range []string{"passive_ftp = on", "header = X-A: 1", "use-proxy = off", "Tries = 3"} {
+		var c ctx.Context
+		if err := Command(&c, command); err != nil {
+			t.Errorf("Command(%q) error = %v, want none", command, err)
+		}
+		if !reflect.DeepEqual(c, ctx.Context{}) {
+			t.Errorf("Command(%q) = %+v, want nothing set", command, c)
+		}
+	}
+}
+
+func TestCommand_Off(t *testing.T) {
+	c := ctx.Context{Verbosity: progress.NoVerbose, Clobber: "overwrite"}
+	for _, command := range []string{"quiet = off", "no_clobber = off"} {
+		if err := Command(&c, command); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// turning a verbosity off doesn't reset another one
+	if c.Verbosity != progress.NoVerbose || c.Clobber != "overwrite" {
+		t.Errorf("Command() = %+v, want the verbosity and clobber kept", c)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	name := writeWgetrc(t, "# the defaults\n\nlimit_rate = 20k\n  proxy = on\ncontinue = on\npassive_ftp = on\nspeed = 1\n")
+	var c ctx.Context
+	warnings, err := Load(&c, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{name + `:4: unknown command "proxy"`, name + `:7: unknown command "speed"`}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("Load() warnings = %q, want %q", warnings, want)
+	}
+	if c.RateLimitValue != 20000 || !c.Continue {
+		t.Errorf("Load() = %+v, want the rate limit and continue set", c)
+	}
+
+	_, err = Load(&c, writeWgetrc(t, "mirror = on\nwait = never\n"))
+	if err == nil || !strings.Contains(err.Error(), "wgetrc:2: invalid wait period") {
+		t.Errorf("Load() error = %v, want the invalid wait period of line 2", err)
+	}
+}
+
+func TestStartupFiles(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	user := filepath.Join(home, ".wgetrc")
+	tests := []struct {
+		name      string
+		env       string
+		arguments []string
+		want      []startupFile
+	}{
+		{"default", "", []string{"URL"}, []startupFile{{systemWgetrc, false}, {user, false}}},
+		{"env", "/tmp/rc", []string{"URL"}, []startupFile{{systemWgetrc, false}, {"/tmp/rc", true}}},
+		{"config", "/tmp/rc", []string{"--config", "a.rc", "URL"}, []startupFile{{"a.rc", true}}},
+		{"config equals", "", []string{"--config=a.rc"}, []startupFile{{"a.rc", true}}},
+		{"no config", "/tmp/rc", []string{"--config=a.rc", "--no-config"}, nil},
+		{"dashes", "", []string{"--", "--no-config"}, []startupFile{{systemWgetrc, false}, {user, false}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WGETRC", tt.env)
+			if got := startupFiles(tt.arguments); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("startupFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Wgetrc(t *testing.T) {
+	const url = "https://example.com/a.txt"
+	name := writeWgetrc(t, "limit_rate = 20k\nquiet = on\ncontinue = on\nreject = gif\n")
+	t.Setenv("WGETRC", name)
+
+	tests := []struct {
+		name      string
+		arguments []string
+		want      ctx.Context
+	}{
+		{
+			"file", []string{url},
+			ctx.Context{
+				RateLimit: "20k", RateLimitValue: 20000, Verbosity: progress.Quiet, Continue: true,
+				Rejects: []string{"gif"}, Links: []string{url},
+			},
+		},
+		{
+			"command line wins", []string{"--rate-limit=1M", "--verbose", "-R", "jpg", url},
+			ctx.Context{
+				RateLimit: "1M", RateLimitValue: 1000000, Verbosity: progress.Verbose, Continue: true,
+				Rejects: []string{"gif", "jpg"}, Links: []string{url},
+			},
+		},
+		{
+			"execute", []string{"-e", "continue = off", "--execute=limit_rate=5k", url},
+			ctx.Context{
+				RateLimit: "5k", RateLimitValue: 5000, Verbosity: progress.Quiet,
+				Rejects: []string{"gif"}, Links: []string{url},
+			},
+		},
+		{"no config", []string{"--no-config", url}, ctx.Context{Links: []string{url}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.arguments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_WgetrcDirectory(t *testing.T) {
+	dir := t.TempDir()
+	overridden, prefix := filepath.Join(dir, "overridden"), filepath.Join(dir, "prefix")
+	t.Setenv("WGETRC", writeWgetrc(t, "dir_prefix = "+overridden+"\n"))
+
+	c, err := Parse([]string{"-P", prefix, "https://example.com/a.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SavePath != prefix {
+		t.Errorf("Parse() SavePath = %q, want %q", c.SavePath, prefix)
+	}
+	if _, err := os.Stat(prefix); err != nil {
+		t.Errorf("the directory of -P isn't created: %v", err)
+	}
+	if _, err := os.Stat(overridden); !os.IsNotExist(err) {
+		t.Errorf("the directory of the startup file is created, error = %v, want it not to exist", err)
+	}
+}
+
+func TestParse_WgetrcErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments []string
+		want      string
+	}{
+		{"missing config", []string{"--config", filepath.Join(t.TempDir(), "none"), "URL"}, "no such file"},
+		{"invalid config", []string{"--config", writeWgetrc(t, "cut_dirs = -1\n"), "URL"}, "wgetrc:1: invalid"},
+		{"unknown execute", []string{"-e", "proxy = on", "URL"}, `unknown command "proxy"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.arguments)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Parse() error = %v, want %q", err, tt.want)
+			}
+			if status := xerr.ExitStatus(err); status != xerr.ParseStatus {
+				t.Errorf("ExitStatus() = %d, want %d", status, xerr.ParseStatus)
+			}
+		})
+	}
+}
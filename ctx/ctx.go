@@ -102,6 +102,11 @@
 // create. Go doesn't have traditional class-based inheritance.
 package ctx
 
+import (
+	"time"
+	"wget/restrict"
+)
+
 // Context defines the circumstances that form the setting for a download event,
 // as specified in commandline arguments passed by the user.
 //
@@ -136,11 +141,141 @@ type Context struct {
 	RateLimit string
 	// if RateLimit is specified, RateLimitValue will be
 	RateLimitValue int64
+	// identified by the --rate-burst flag, specifies how many bytes may be downloaded at once,
+	//above the RateLimit, after the downloads have been idle
+	RateBurst string
+	// if RateBurst is specified, RateBurstValue will be the burst in bytes
+	RateBurstValue int64
+	// identified by the --file-rate-limit flag, specifies the download speed of each file, within the RateLimit
+	FileRateLimit string
+	// if FileRateLimit is specified, FileRateLimitValue will be the speed in bytes/second
+	FileRateLimitValue int64
+	// identified by the --rate-schedule flag, specifies different rate limits for different times of the day
+	RateSchedule string
+	// if RateSchedule is specified, RateScheduleValue will be the parsed time windows and their rate limits
+	RateScheduleValue RateSchedule
 	// identified by the --help flag, if pared it will print our program manual
 	IsHelp bool
+	// identified by the --version or -v flag, if parsed it will print the version of our program
+	IsVersion bool
+	// identified by the --jobs flag, if parsed it will list the background jobs
+	Jobs bool
+	// identified by the --cancel flag, takes the ID of the background job to stop
+	Cancel string
+	// identified by the --daemon flag, if parsed the downloads are queued by a long-running process
+	Daemon bool
+	// identified by the --listen flag, takes the address of the API of the daemon
+	Listen string
 	// identified by the --convert-links
 	ConvertLinks bool
 	// identified by the --exclude or -X, takes a comma separated list of paths (directory),
 	//to avoid when fetching a resource
 	Exclude []string
+	// identified by the --quota flag, specifies the maximum total size of the files to download;
+	//no new downloads will be started once the quota is exceeded
+	Quota string
+	// if Quota is specified, QuotaValue will be the quota in bytes
+	QuotaValue int64
+	// identified by the --max-filesize flag, specifies the maximum size of a single file to download
+	MaxFileSize string
+	// if MaxFileSize is specified, MaxFileSizeValue will be the maximum size in bytes
+	MaxFileSizeValue int64
+	// identified by the --continue or -c flag, continues downloading the partial files left behind by an
+	// interrupted download, instead of downloading the whole file anew
+	Continue bool
+	// identified by the --no-clobber (-nc), --overwrite, --backups or --unique-names flags, names what
+	// happens to existing files when a download is saved under their name; the last of the flags wins.
+	// Empty by default, i.e., unique names for downloads, and overwriting for mirrors
+	Clobber string
+	// identified by the --backups flag, the number of backups of an overwritten file to keep
+	Backups int
+	// identified by the --content-disposition flag, names downloaded files by the Content-Disposition header
+	// sent by the server, if any
+	ContentDisposition bool
+	// identified by the --restrict-file-names flag, a comma separated list of the modes
+	// restricting the characters allowed in the names of downloaded files
+	RestrictFileNames string
+	// RestrictFileNamesValue is the parsed RestrictFileNames, the zero Mode is restrict.Default
+	RestrictFileNamesValue restrict.Mode
+	// identified by the --adjust-extension or -E flag, appends the .html or .css extension to the names of
+	// HTML and CSS files without the extension, e.g. page.php is saved as page.php.html
+	AdjustExtension bool
+	// identified by the --no-directories or -nd flag, saves all mirrored files into the save path,
+	// without creating the host and path directories of their URLs
+	NoDirectories bool
+	// identified by the --no-host-directories or -nH flag, omits the host directories of mirrored files
+	NoHostDirectories bool
+	// identified by the --protocol-directories flag, nests the host directories of mirrored files
+	// in a directory named after the scheme of their URLs, e.g. https/example.com
+	ProtocolDirectories bool
+	// identified by the --cut-dirs flag, the number of leading directories of the URL paths
+	// to omit from the paths of mirrored files
+	CutDirs int
+	// identified by the --ignore-query-params flag, a comma separated list of the glob patterns of
+	// query parameters, e.g. utm_*, to drop from mirrored URLs
+	IgnoreQueryParams []string
+	// identified by the --keep-partial flag, keeps the partial files of failed downloads
+	KeepPartial bool
+	// identified by the --min-free-space flag, specifies the free disk space to leave on the filesystem
+	// holding the downloaded files; downloads that wouldn't leave this much space will not be started
+	MinFreeSpace string
+	// if MinFreeSpace is specified, MinFreeSpaceValue will be the free disk space in bytes
+	MinFreeSpaceValue int64
+	// identified by the --quiet (-q), --no-verbose (-nv) or --verbose flags, names how much is reported
+	// about the downloads; the last of the flags wins. Empty by default, i.e., verbose
+	Verbosity string
+	// identified by the --progress flag, e.g. "dot:mega", names how the progress of downloads is drawn.
+	// Empty by default, i.e., a progress bar on terminals, and dots otherwise
+	Progress string
+	// identified by the --output-format flag, either "text", the default if empty, or "jsonl", which reports
+	// the progress of the downloads as JSON events, one per line, for tools wrapping this program
+	OutputFormat string
+	// identified by the -o (--output-file) or -a (--append-output) flags, names the log file all the messages
+	// to the user are written to, instead of the terminal, each line prefixed by the time it was written
+	LogFile string
+	// identified by the -a (--append-output) flag, appends to the LogFile, instead of truncating it
+	AppendOutput bool
+	// identified by the --debug flag, writes the debug output to the LogFile, or to stderr if none;
+	// otherwise, the debug output is discarded
+	Debug bool
+	// identified by the --server-response (-S) flag, reports the status line, and the headers, of each response,
+	// including the responses redirecting to the next request
+	ServerResponse bool
+	// identified by the --save-headers flag, names how the headers of the downloaded files are saved: "inline",
+	// before the contents of the file, as with a bare --save-headers, or "sidecar", to a file of their own named
+	// after the downloaded file, e.g. "file.zip.headers". Empty by default, i.e., the headers aren't saved
+	SaveHeaders string
+	// identified by the --wait flag, specifies the delay between requests to the same host
+	Wait time.Duration
+	// identified by the --random-wait flag, randomizes the Wait to between 0.5 and 1.5 times its value
+	RandomWait bool
+}
+
+// RateWindow defines the rate limit of downloads within a time window of the day,
+// as specified by the --rate-schedule flag, e.g. "08:00-18:00=500k"
+type RateWindow struct {
+	// From and To are the start (inclusive) and end (exclusive) of the window, as
+	// durations since midnight. If To is before From, the window wraps around midnight
+	From, To time.Duration
+	// Rate is the rate limit within the window, in bytes/second; 0 means no rate limiting
+	Rate int64
+}
+
+// RateSchedule is a list of time windows and their rate limits
+type RateSchedule []RateWindow
+
+// RateAt returns the rate limit of the first window that contains the time of
+// the day of the given time, or false if no window contains the given time
+func (s RateSchedule) RateAt(t time.Time) (int64, bool) {
+	hour, minute, second := t.Clock()
+	now := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second
+	for _, w := range s {
+		if w.From <= w.To && now >= w.From && now < w.To {
+			return w.Rate, true
+		}
+		if w.From > w.To && (now >= w.From || now < w.To) {
+			return w.Rate, true
+		}
+	}
+	return 0, false
 }
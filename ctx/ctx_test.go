@@ -3,6 +3,7 @@ package ctx
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 type Arg struct {
@@ -39,3 +40,39 @@ func TestContext(t *testing.T) {
 		t.Errorf("IsMirroring not enabled yet it was just set to true")
 	}
 }
+
+func TestRateSchedule_RateAt(t *testing.T) {
+	schedule := RateSchedule{
+		{From: 8 * time.Hour, To: 18 * time.Hour, Rate: 500_000},
+		{From: 18 * time.Hour, To: 8 * time.Hour, Rate: 0},
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name   string
+		t      time.Time
+		want   int64
+		wantOk bool
+	}{
+		{"business hours start", at(8, 0), 500_000, true},
+		{"business hours", at(12, 30), 500_000, true},
+		{"business hours end", at(18, 0), 0, true},
+		{"night", at(23, 59), 0, true},
+		{"after midnight", at(0, 0), 0, true},
+		{"early morning", at(7, 59), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := schedule.RateAt(tt.t)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("RateAt(%v) = %d, %v, want %d, %v", tt.t, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+
+	if _, ok := schedule[:1].RateAt(at(20, 0)); ok {
+		t.Errorf("expected no rate outside the scheduled windows")
+	}
+}
@@ -0,0 +1,159 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"wget/temp"
+)
+
+// DefaultAddress returns the address the daemon listens on by default: the Unix socket wget.sock of the
+// temporary directory of the program
+func DefaultAddress() string {
+	return "unix:" + filepath.Join(temp.Dir(), "wget.sock")
+}
+
+// ParseAddress parses the address given to --listen, i.e., `unix:PATH`, of a Unix socket, and returns its
+// network and address, as given to net.Listen. The API isn't authenticated, thus, it's only served on Unix sockets,
+// only accessible to the user, as anyone able to reach it could have files downloaded anywhere the user may write
+func ParseAddress(address string) (network, addr string, err error) {
+	network, addr, _ = strings.Cut(address, ":")
+	if network != "unix" || addr == "" {
+		return "", "", fmt.Errorf("invalid --listen %q, want unix:PATH", address)
+	}
+	return network, addr, nil
+}
+
+// Listen listens on the given address, see ParseAddress. The Unix socket is only accessible to the user,
+// and replaces the socket left behind by a daemon that didn't stop cleanly, unless it's still listening
+func Listen(address string) (net.Listener, error) {
+	network, addr, err := ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if conn, err := net.Dial(network, addr); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("another daemon is listening on %s", address)
+	}
+	if info, err := os.Lstat(addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+		_ = os.Remove(addr)
+	}
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(addr, 0600); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Serve serves the API of the daemon on the given listener, and runs the jobs of its queue, until the given
+// context is done. The running jobs are then stopped gracefully, keeping their partial files, and queued
+// again, for the next daemon to continue them
+func (d *Daemon) Serve(cx context.Context, listener net.Listener) error {
+	// the streams of events are closed as the context is done, so the server shuts down right away
+	server := &http.Server{Handler: d.Handler(), BaseContext: func(net.Listener) context.Context { return cx }}
+	scheduled := make(chan struct{})
+	go func() {
+		d.schedule(cx)
+		close(scheduled)
+	}()
+	go func() {
+		<-cx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	err := server.Serve(listener)
+	<-scheduled
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return err
+}
+
+// Handler returns the handler of the API of the daemon, where the jobs, and the requests, are JSON objects,
+// see Job and Request, and the errors are objects of their `error` message:
+//
+//	GET    /jobs              lists the jobs, in the order they run
+//	POST   /jobs              adds the job of the Request, e.g., {"url":"https://example.com/a.iso","priority":1}
+//	GET    /jobs/{id}         returns the job
+//	PATCH  /jobs/{id}         reprioritizes the job, e.g., {"priority":10}
+//	DELETE /jobs/{id}         removes the job, stopping it if running; its files are kept
+//	POST   /jobs/{id}/pause   pauses the job, keeping its partial files
+//	POST   /jobs/{id}/resume  queues the job again, paused, or failed
+//	GET    /events            streams the events of all the jobs, or of the one given by ?job=ID, see Event
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, d.Jobs())
+	})
+	mux.HandleFunc("POST /jobs", func(w http.ResponseWriter, r *http.Request) {
+		var request Request
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid job: %w", err))
+			return
+		}
+		respond(w, http.StatusCreated)(d.Add(request))
+	})
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, http.StatusOK)(d.Job(r.PathValue("id")))
+	})
+	mux.HandleFunc("PATCH /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var patch struct {
+			Priority *int `json:"priority"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch.Priority == nil {
+			writeError(w, http.StatusBadRequest, errors.New(`invalid patch, want {"priority":N}`))
+			return
+		}
+		respond(w, http.StatusOK)(d.Prioritize(r.PathValue("id"), *patch.Priority))
+	})
+	mux.HandleFunc("DELETE /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, http.StatusOK)(d.Remove(r.PathValue("id")))
+	})
+	mux.HandleFunc("POST /jobs/{id}/pause", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, http.StatusOK)(d.Pause(r.PathValue("id")))
+	})
+	mux.HandleFunc("POST /jobs/{id}/resume", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, http.StatusOK)(d.Resume(r.PathValue("id")))
+	})
+	mux.HandleFunc("GET /events", d.serveEvents)
+	return mux
+}
+
+// respond returns the function writing the job, with the given status, or the error, as returned by the
+// methods of the Daemon
+func respond(w http.ResponseWriter, status int) func(job Job, err error) {
+	return func(job Job, err error) {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			writeError(w, http.StatusNotFound, err)
+		case errors.Is(err, ErrState):
+			writeError(w, http.StatusConflict, err)
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err)
+		default:
+			writeJSON(w, status, job)
+		}
+	}
+}
+
+// writeError writes the given error, as an object of its `error` message
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeJSON writes the given value as JSON, with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	d, _ := newDaemon(t)
+	server := httptest.NewServer(d.Handler())
+	defer server.Close()
+
+	do := func(method, path, body string) (int, map[string]any) {
+		t.Helper()
+		request, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		var decoded map[string]any
+		_ = json.NewDecoder(response.Body).Decode(&decoded)
+		return response.StatusCode, decoded
+	}
+
+	status, job := do("POST", "/jobs", `{"url":"https://example.com/a.txt","priority":1}`)
+	if status != http.StatusCreated || job["state"] != Queued {
+		t.Fatalf("POST /jobs = %d %v, want the job created", status, job)
+	}
+	id := job["id"].(string)
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{"GET", "/jobs/" + id, "", http.StatusOK, `"priority":1`},
+		{"PATCH", "/jobs/" + id, `{"priority":7}`, http.StatusOK, `"priority":7`},
+		{"PATCH", "/jobs/" + id, `{}`, http.StatusBadRequest, "invalid patch"},
+		{"POST", "/jobs/" + id + "/pause", "", http.StatusOK, `"state":"paused"`},
+		{"POST", "/jobs/" + id + "/pause", "", http.StatusConflict, "invalid job state"},
+		{"POST", "/jobs/" + id + "/resume", "", http.StatusOK, `"state":"queued"`},
+		{"POST", "/jobs", `{"url":"example"}`, http.StatusBadRequest, "invalid domain"},
+		{"POST", "/jobs", `{"url":`, http.StatusBadRequest, "invalid job"},
+		{"GET", "/jobs/missing", "", http.StatusNotFound, "no such job"},
+		{"GET", "/events?job=missing", "", http.StatusNotFound, "no such job"},
+		{"DELETE", "/jobs/" + id, "", http.StatusOK, `"state":"removed"`},
+		{"DELETE", "/jobs/" + id, "", http.StatusNotFound, "no such job"},
+	}
+	for _, tt := range tests {
+		status, body := do(tt.method, tt.path, tt.body)
+		encoded, _ := json.Marshal(body)
+		if status != tt.status || !strings.Contains(string(encoded), tt.want) {
+			t.Errorf("%s %s = %d %s, want %d %s", tt.method, tt.path, status, encoded, tt.status, tt.want)
+		}
+	}
+
+	response, err := http.Get(server.URL + "/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var jobs []Job
+	if err = json.NewDecoder(response.Body).Decode(&jobs); err != nil || len(jobs) != 0 {
+		t.Errorf("GET /jobs = %v, %v, want no jobs", jobs, err)
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address, network, addr string
+	}{
+		{"unix:/run/wget.sock", "unix", "/run/wget.sock"},
+		{"tcp:127.0.0.1:8080", "", ""},
+		{"unix:", "", ""},
+		{"127.0.0.1:8080", "", ""},
+		{"/run/wget.sock", "", ""},
+	}
+	for _, tt := range tests {
+		network, addr, err := ParseAddress(tt.address)
+		if network != tt.network || addr != tt.addr || (err == nil) != (tt.network != "") {
+			t.Errorf("ParseAddress(%q) = %q, %q, %v", tt.address, network, addr, err)
+		}
+	}
+}
+
+func TestListen(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "wget.sock")
+	listener, err := Listen("unix:" + socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the socket to be only accessible to the user, got %v, %v", info, err)
+	}
+	if _, err = Listen("unix:" + socket); err == nil || !strings.Contains(err.Error(), "another daemon") {
+		t.Errorf("Listen() error = %v, want another daemon listening", err)
+	}
+	_ = listener.Close()
+
+	// the socket left behind by a daemon that didn't stop cleanly is replaced
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	_ = stale.Close()
+	if listener, err = Listen("unix:" + socket); err != nil {
+		t.Fatalf("Listen() error = %v, want the stale socket replaced", err)
+	}
+	_ = listener.Close()
+}
+
+func TestDaemon_Serve(t *testing.T) {
+	files := newServer(t)
+	d, _ := newDaemon(t)
+	socket := filepath.Join(t.TempDir(), "wget.sock")
+	listener, err := Listen("unix:" + socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() { served <- d.Serve(cx, listener) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(cx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(cx, "unix", socket)
+		},
+	}}
+	events, err := client.Get("http://wget/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Body.Close()
+
+	response, err := client.Post("http://wget/jobs", "application/json",
+		strings.NewReader(`{"url":"`+files.URL+`/a.txt"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+
+	// the job is reported as it's added, started, downloading, and done
+	var kinds []string
+	scanner := bufio.NewScanner(events.Body)
+	for scanner.Scan() {
+		if kind, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			kinds = append(kinds, kind)
+		}
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok && strings.Contains(data, `"state":"done"`) {
+			break
+		}
+	}
+	got := strings.Join(kinds, ",")
+	if !strings.HasPrefix(got, "job,job,start,") || !strings.Contains(got, ",finished,") || !strings.HasSuffix(got, ",job") {
+		t.Errorf("expected the events of the job, got %s", got)
+	}
+
+	cancel()
+	if err = <-served; err != nil {
+		t.Errorf("Serve() error = %v", err)
+	}
+	if _, err = os.Stat(socket); err == nil {
+		t.Error("expected the socket to be removed")
+	}
+}
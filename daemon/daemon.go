@@ -0,0 +1,506 @@
+// Package daemon runs the downloads of a long-running process, as started by --daemon: a persistent queue of
+// jobs, each a file to download or a website to mirror, controlled by a local HTTP API, see Handler.
+//
+// The queue is kept in a file, see DefaultQueue, such that the jobs survive restarts: the jobs running as the
+// daemon stops are stopped gracefully, keeping their partial files, and continued by the next daemon.
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"wget/ctx"
+	"wget/downloader"
+	"wget/fetch"
+	"wget/fileio"
+	"wget/progress"
+	"wget/syscheck"
+	"wget/xerr"
+	"wget/xurl"
+)
+
+// The states of a job
+const (
+	// Queued is the state of the jobs waiting to run, by priority
+	Queued = "queued"
+	// Running is the state of the jobs downloading
+	Running = "running"
+	// Paused is the state of the jobs paused by the API, until resumed; their partial files are kept
+	Paused = "paused"
+	// Done is the state of the jobs whose downloads all succeeded
+	Done = "done"
+	// Failed is the state of the jobs that failed; they may be resumed to try again
+	Failed = "failed"
+	// Removed is the state of the jobs removed by the API, only reported by their last EventJob
+	Removed = "removed"
+)
+
+var (
+	// ErrNotFound is returned for the IDs naming no job
+	ErrNotFound = errors.New("no such job")
+	// ErrState is returned when a job can't be paused, or resumed, in its state
+	ErrState = errors.New("invalid job state")
+)
+
+// Job is a job of the queue: a file to download, or a website to mirror
+type Job struct {
+	// ID identifies the job in the API
+	ID string `json:"id"`
+	// URL is the URL of the file to download, or of the website to mirror, if Mirror
+	URL    string `json:"url"`
+	Mirror bool   `json:"mirror,omitempty"`
+	// Dir is the directory the job saves its files to, and Output the name of the file downloaded, if not
+	// named after the URL
+	Dir    string `json:"dir"`
+	Output string `json:"output,omitempty"`
+	// Priority orders the queue: the queued jobs of the highest priority run first, in the order they were added
+	Priority int `json:"priority"`
+	// State is the state of the job, e.g., Queued
+	State string `json:"state"`
+	// Added, Started and Finished are the times the job was added, and last started, and finished
+	Added    time.Time  `json:"added"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	// Files is the number of the downloads started, and Done the number of those that finished
+	Files int `json:"files"`
+	Done  int `json:"done"`
+	// Bytes is the number of bytes downloaded, out of Total, the total size of the files, as far as known
+	Bytes int64 `json:"bytes"`
+	Total int64 `json:"total"`
+	// Error is the error the job failed with, and Status its exit status, see xerr.ExitStatus
+	Error  string `json:"error,omitempty"`
+	Status int    `json:"status,omitempty"`
+	// Partial is whether the job was stopped before it finished, leaving partial files to continue as it runs
+	// again, e.g., as it's paused, or the daemon stops
+	Partial bool `json:"partial,omitempty"`
+}
+
+// Request is a job to add to the queue, see Daemon.Add
+type Request struct {
+	URL    string `json:"url"`
+	Mirror bool   `json:"mirror"`
+	// Dir is the directory to save the files to; the directory of --directory-prefix, or the current
+	// directory of the daemon, if empty
+	Dir    string `json:"dir"`
+	Output string `json:"output"`
+	// Priority is the priority of the job, 0 by default, see Job.Priority
+	Priority int `json:"priority"`
+}
+
+// Options are the options of a Daemon
+type Options struct {
+	// Settings are the settings of all the downloads, e.g., --rate-limit, as given along with --daemon
+	Settings ctx.Context
+	// Queue is the file the queue is kept in, see DefaultQueue
+	Queue string
+	// Parallel is the number of jobs run at once; 1 if 0
+	Parallel int
+	// Log is where the daemon reports the jobs starting, and finishing; nothing is reported if nil
+	Log io.Writer
+}
+
+// Daemon runs the jobs of its queue, see Serve
+type Daemon struct {
+	options Options
+	events  *hub
+	// wake wakes the scheduler up, once a job may be started
+	wake chan struct{}
+
+	// mutex guards the jobs, and the state file of the queue
+	mutex sync.Mutex
+	// jobs are the jobs of the queue, in the order they were added
+	jobs []*Job
+	// running cancels the running jobs, by their ID
+	running map[string]context.CancelFunc
+	// saved is the last time the queue was saved, to save the progress of the jobs at most once a second
+	saved time.Time
+}
+
+// DefaultQueue returns the file the queue is kept in by default: $XDG_STATE_HOME/wget/queue.json, or
+// ~/.local/state/wget/queue.json
+func DefaultQueue() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "wget", "queue.json")
+}
+
+// New returns a daemon running the jobs of the queue of the given options, creating the queue if it doesn't
+// exist. The jobs that were running as the last daemon stopped are queued again
+func New(options Options) (*Daemon, error) {
+	if options.Queue == "" {
+		options.Queue = DefaultQueue()
+	}
+	options.Parallel = max(options.Parallel, 1)
+	d := &Daemon{options: options, events: newHub(), wake: make(chan struct{}, 1), running: map[string]context.CancelFunc{}}
+
+	if err := os.MkdirAll(filepath.Dir(options.Queue), 0755); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(options.Queue)
+	if errors.Is(err, fs.ErrNotExist) {
+		return d, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &d.jobs); err != nil {
+		return nil, fmt.Errorf("invalid queue %s: %w", options.Queue, err)
+	}
+	for _, job := range d.jobs {
+		if job.State == Running {
+			// the daemon stopped abruptly, the partial files of the job are left behind
+			job.State, job.Partial = Queued, true
+		}
+	}
+	return d, nil
+}
+
+// Add adds the given job to the queue
+func (d *Daemon) Add(request Request) (Job, error) {
+	job, err := d.job(request)
+	if err != nil {
+		return Job{}, err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.jobs = append(d.jobs, job)
+	d.changed(job)
+	return *job, nil
+}
+
+// addOnce adds the given job to the queue, as Add does, unless the queue holds a job of the same URL, saving
+// the same files already, e.g., as the daemon starts again with the same URLs; that job is returned then
+func (d *Daemon) addOnce(request Request) (Job, bool, error) {
+	job, err := d.job(request)
+	if err != nil {
+		return Job{}, false, err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, queued := range d.jobs {
+		if queued.URL == job.URL && queued.Mirror == job.Mirror && queued.Dir == job.Dir && queued.Output == job.Output {
+			return *queued, false, nil
+		}
+	}
+	d.jobs = append(d.jobs, job)
+	d.changed(job)
+	return *job, true, nil
+}
+
+// job returns the new job of the given request, not added to the queue yet
+func (d *Daemon) job(request Request) (*Job, error) {
+	link, ok, err := xurl.IsValidURL(request.URL)
+	if !ok {
+		if err == nil {
+			err = errors.New("invalid URL")
+		}
+		return nil, fmt.Errorf("%q: %w", request.URL, err)
+	}
+	switch {
+	case request.Mirror && request.Output != "":
+		return nil, errors.New("a mirror with an output file is ambiguous")
+	case request.Output != "" && (request.Output == downloader.Stdout || filepath.Base(request.Output) != request.Output):
+		return nil, fmt.Errorf("invalid output file %q, want the name of a file of the directory", request.Output)
+	}
+	dir := request.Dir
+	if dir == "" {
+		dir = d.options.Settings.SavePath
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 4)
+	if _, err = rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &Job{
+		ID: hex.EncodeToString(id), URL: link, Mirror: request.Mirror, Dir: dir, Output: request.Output,
+		Priority: request.Priority, State: Queued, Added: time.Now(),
+	}, nil
+}
+
+// Jobs returns the jobs of the queue, in the order they run: by priority, then in the order they were added
+func (d *Daemon) Jobs() []Job {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	jobs := make([]Job, len(d.jobs))
+	for i, job := range d.ordered() {
+		jobs[i] = *job
+	}
+	return jobs
+}
+
+// Job returns the job of the given ID
+func (d *Daemon) Job(id string) (Job, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	job, err := d.find(id)
+	if err != nil {
+		return Job{}, err
+	}
+	return *job, nil
+}
+
+// Pause pauses the given job, queued or running. A running job is stopped gracefully, keeping its partial
+// files, to be continued once resumed
+func (d *Daemon) Pause(id string) (Job, error) {
+	return d.update(id, func(job *Job) error {
+		if job.State != Queued && job.State != Running {
+			return fmt.Errorf("%w: job %s is %s, not queued or running", ErrState, id, job.State)
+		}
+		if cancel, ok := d.running[id]; ok {
+			cancel()
+		}
+		job.State = Paused
+		return nil
+	})
+}
+
+// Resume queues the given job again, paused, or failed, to try again
+func (d *Daemon) Resume(id string) (Job, error) {
+	return d.update(id, func(job *Job) error {
+		if job.State != Paused && job.State != Failed {
+			return fmt.Errorf("%w: job %s is %s, not paused or failed", ErrState, id, job.State)
+		}
+		if _, ok := d.running[id]; ok {
+			// the job is still stopping, it's queued again once stopped
+			job.State = Running
+			return nil
+		}
+		job.State = Queued
+		return nil
+	})
+}
+
+// Prioritize changes the priority of the given job, see Job.Priority
+func (d *Daemon) Prioritize(id string, priority int) (Job, error) {
+	return d.update(id, func(job *Job) error {
+		job.Priority = priority
+		return nil
+	})
+}
+
+// Remove removes the given job from the queue, stopping it if it's running. The files it downloaded are kept
+func (d *Daemon) Remove(id string) (Job, error) {
+	return d.update(id, func(job *Job) error {
+		if cancel, ok := d.running[id]; ok {
+			cancel()
+		}
+		for i := range d.jobs {
+			if d.jobs[i] == job {
+				d.jobs = append(d.jobs[:i], d.jobs[i+1:]...)
+				break
+			}
+		}
+		job.State = Removed
+		return nil
+	})
+}
+
+// update updates the given job with the given function, unless it fails
+func (d *Daemon) update(id string, f func(job *Job) error) (Job, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	job, err := d.find(id)
+	if err == nil {
+		err = f(job)
+	}
+	if err != nil {
+		return Job{}, err
+	}
+	d.changed(job)
+	return *job, nil
+}
+
+// find returns the job of the given ID; the caller must hold the mutex
+func (d *Daemon) find(id string) (*Job, error) {
+	for _, job := range d.jobs {
+		if job.ID == id {
+			return job, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrNotFound, id)
+}
+
+// ordered returns the jobs in the order they run; the caller must hold the mutex
+func (d *Daemon) ordered() []*Job {
+	jobs := append([]*Job(nil), d.jobs...)
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].Priority > jobs[j].Priority })
+	return jobs
+}
+
+// changed saves the queue, and reports the new state of the given job, once changed by the API, or by the
+// scheduler; the caller must hold the mutex
+func (d *Daemon) changed(job *Job) {
+	if err := d.save(); err != nil {
+		d.logf("failed to save the queue %s: %v", d.options.Queue, err)
+	}
+	snapshot := *job
+	d.events.publish(Event{Job: job.ID, Event: progress.Event{Event: EventJob, Time: time.Now()}, State: &snapshot})
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// save writes the queue to its file; the caller must hold the mutex
+func (d *Daemon) save() error {
+	d.saved = time.Now()
+	data, err := json.MarshalIndent(d.jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileio.Replace(d.options.Queue, data)
+}
+
+// schedule starts the queued jobs, by priority, up to Options.Parallel at once, until the given context is
+// done. The running jobs are then stopped, and queued again, for the next daemon
+func (d *Daemon) schedule(cx context.Context) {
+	var wg sync.WaitGroup
+	for {
+		d.mutex.Lock()
+		for _, job := range d.ordered() {
+			if len(d.running) >= d.options.Parallel {
+				break
+			}
+			if job.State == Queued {
+				wg.Add(1)
+				go func(job *Job, jcx context.Context) {
+					defer wg.Done()
+					d.run(jcx, job)
+				}(job, d.start(cx, job))
+			}
+		}
+		d.mutex.Unlock()
+
+		select {
+		case <-cx.Done():
+			wg.Wait()
+			return
+		case <-d.wake:
+		}
+	}
+}
+
+// start marks the given job as Running, and returns its context; the caller must hold the mutex
+func (d *Daemon) start(cx context.Context, job *Job) context.Context {
+	jcx, cancel := context.WithCancel(cx)
+	now := time.Now()
+	d.running[job.ID] = cancel
+	job.State, job.Started, job.Finished, job.Error, job.Status = Running, &now, nil, "", 0
+	job.Files, job.Done, job.Bytes, job.Total = 0, 0, 0, 0
+	d.logf("job %s started: %s", job.ID, job.URL)
+	d.changed(job)
+	return jcx
+}
+
+// run runs the given job, with its context, until done, and records its state as it finishes. The jobs
+// stopped as the daemon's context is done are queued again; those paused, or removed, are left so
+func (d *Daemon) run(cx context.Context, job *Job) {
+	d.mutex.Lock()
+	settings := d.settings(job)
+	d.mutex.Unlock()
+
+	err := os.MkdirAll(settings.SavePath, 0755)
+	if err == nil {
+		err = downloader.Run(cx, settings, downloader.Options{
+			Reporter: func() progress.Reporter {
+				return progress.New(progress.Options{Format: progress.JSONL, Out: d.recorder(job)})
+			},
+			Stdout: io.Discard,
+		})
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.running[job.ID]()
+	delete(d.running, job.ID)
+	now := time.Now()
+	job.Finished = &now
+	// the partial files are kept as the downloads are stopped, or fail with --keep-partial
+	job.Partial = err != nil && (errors.Is(err, context.Canceled) || settings.KeepPartial)
+	switch {
+	case job.State == Removed:
+		d.logf("job %s removed: %s", job.ID, job.URL)
+		return
+	case err == nil:
+		job.State = Done
+		d.logf("job %s done: %s", job.ID, job.URL)
+	case job.State == Paused:
+		d.logf("job %s paused: %s", job.ID, job.URL)
+	case errors.Is(err, context.Canceled):
+		// the daemon is stopping, and the next one continues the job; or the job was resumed while stopping
+		job.State = Queued
+	default:
+		job.State, job.Error, job.Status = Failed, err.Error(), xerr.ExitStatus(err)
+		d.logf("job %s failed: %s: %v", job.ID, job.URL, err)
+	}
+	d.changed(job)
+}
+
+// settings returns the download context of the given job, as the settings of the daemon, continuing the
+// partial files of the job, if it was stopped; the caller must hold the mutex
+func (d *Daemon) settings(job *Job) ctx.Context {
+	settings := d.options.Settings
+	settings.Links, settings.InputFile = []string{job.URL}, ""
+	settings.Mirror, settings.SavePath, settings.OutputFile = job.Mirror, job.Dir, job.Output
+	settings.BackgroundMode, settings.Daemon, settings.Listen = false, false, ""
+	// the files already in place are left to the clobber policy, unless they're the partial files of the job;
+	// the headers saved inline would be in the middle of the continued file
+	settings.Continue = settings.Continue || (job.Partial && settings.SaveHeaders != fetch.HeadersInline)
+	return settings
+}
+
+// logf reports the given message about the jobs to the Log of the options, if any
+func (d *Daemon) logf(format string, a ...any) {
+	if d.options.Log != nil {
+		_, _ = fmt.Fprintf(d.options.Log, format+"\n", a...)
+	}
+}
+
+// Run runs the daemon of the command line, as given --daemon, serving its API on the address given to
+// --listen, see Listen, until the given context is done. The URLs given along are added to the queue, as
+// downloads, or mirrors with --mirror, unless queued already; and the other settings apply to all the jobs
+func Run(cx context.Context, settings ctx.Context) error {
+	var log io.Writer
+	if settings.Verbosity != progress.Quiet {
+		log = syscheck.Output()
+	}
+	d, err := New(Options{Settings: settings, Log: log})
+	if err != nil {
+		return xerr.WithStatus(xerr.IOStatus, err)
+	}
+	for _, link := range settings.Links {
+		// the queue persists, thus, the URLs given again, as the daemon starts again, are queued already
+		job, added, err := d.addOnce(Request{URL: link, Mirror: settings.Mirror})
+		if err != nil {
+			return xerr.WithStatus(xerr.ParseStatus, err)
+		} else if !added {
+			d.logf("%s is in the queue already, as job %s, %s", job.URL, job.ID, job.State)
+		}
+	}
+
+	address := settings.Listen
+	if address == "" {
+		address = DefaultAddress()
+	}
+	listener, err := Listen(address)
+	if err != nil {
+		return xerr.WithStatus(xerr.IOStatus, err)
+	}
+	d.logf("listening on %s, the queue is kept in %s", address, d.options.Queue)
+	return d.Serve(cx, listener)
+}
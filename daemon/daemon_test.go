@@ -0,0 +1,279 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"wget/ctx"
+)
+
+// newServer serves the files of the tests; /slow/ files take a while, until the test ends
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	done := make(chan struct{})
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				switch filepath.Dir(r.URL.Path) {
+				case "/slow":
+					w.Header().Set("Content-Length", "1000")
+					_, _ = fmt.Fprint(w, "partial")
+					w.(http.Flusher).Flush()
+					select {
+					case <-done:
+					case <-r.Context().Done():
+					}
+				case "/missing":
+					w.WriteHeader(http.StatusNotFound)
+				default:
+					_, _ = fmt.Fprint(w, "contents")
+				}
+			},
+		),
+	)
+	t.Cleanup(func() {
+		close(done)
+		server.Close()
+	})
+	return server
+}
+
+// newDaemon returns a daemon of a queue of its own, saving the files into the returned directory
+func newDaemon(t *testing.T) (*Daemon, string) {
+	t.Helper()
+	dir := t.TempDir()
+	d, err := New(Options{Queue: filepath.Join(dir, "state", "queue.json"), Settings: ctx.Context{SavePath: dir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, dir
+}
+
+// waitFor waits for the given job to be in the given state, and returns it
+func waitFor(t *testing.T, d *Daemon, id, state string) Job {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if job, _ := d.Job(id); job.State == state {
+			return job
+		}
+	}
+	job, _ := d.Job(id)
+	t.Fatalf("job %s is %s, want %s", id, job.State, state)
+	return job
+}
+
+func TestDaemon_Add(t *testing.T) {
+	d, dir := newDaemon(t)
+	job, err := d.Add(Request{URL: "https://example.com/a.txt", Output: "b.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != Queued || job.Dir != dir || job.Output != "b.txt" || job.ID == "" {
+		t.Errorf("Add() = %+v, want the job queued into %s", job, dir)
+	}
+
+	tests := []struct {
+		name    string
+		request Request
+	}{
+		{"invalid URL", Request{URL: "example"}},
+		{"mirror output", Request{URL: "https://example.com", Mirror: true, Output: "a.html"}},
+		{"stdout", Request{URL: "https://example.com/a.txt", Output: "-"}},
+		{"output path", Request{URL: "https://example.com/a.txt", Output: "../a.txt"}},
+	}
+	for _, tt := range tests {
+		if _, err := d.Add(tt.request); err == nil {
+			t.Errorf("%s: Add() error = nil", tt.name)
+		}
+	}
+}
+
+func TestDaemon_Jobs(t *testing.T) {
+	d, _ := newDaemon(t)
+	a, _ := d.Add(Request{URL: "https://example.com/a.txt"})
+	b, _ := d.Add(Request{URL: "https://example.com/b.txt", Priority: 1})
+	c, _ := d.Add(Request{URL: "https://example.com/c.txt"})
+	if _, err := d.Prioritize(c.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, job := range d.Jobs() {
+		got = append(got, job.ID)
+	}
+	if want := []string{c.ID, b.ID, a.ID}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Jobs() = %v, want %v, by priority", got, want)
+	}
+}
+
+func TestDaemon_States(t *testing.T) {
+	d, _ := newDaemon(t)
+	job, _ := d.Add(Request{URL: "https://example.com/a.txt"})
+
+	if _, err := d.Resume(job.ID); !errors.Is(err, ErrState) {
+		t.Errorf("Resume() error = %v, want %v", err, ErrState)
+	}
+	if paused, err := d.Pause(job.ID); err != nil || paused.State != Paused {
+		t.Errorf("Pause() = %+v, %v, want the job paused", paused, err)
+	}
+	if _, err := d.Pause(job.ID); !errors.Is(err, ErrState) {
+		t.Errorf("Pause() error = %v, want %v", err, ErrState)
+	}
+	if resumed, err := d.Resume(job.ID); err != nil || resumed.State != Queued {
+		t.Errorf("Resume() = %+v, %v, want the job queued", resumed, err)
+	}
+	if removed, err := d.Remove(job.ID); err != nil || removed.State != Removed {
+		t.Errorf("Remove() = %+v, %v, want the job removed", removed, err)
+	}
+	if _, err := d.Job(job.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Job() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestDaemon_Run(t *testing.T) {
+	server := newServer(t)
+	d, dir := newDaemon(t)
+	cx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.schedule(cx)
+
+	file, _ := d.Add(Request{URL: server.URL + "/a.txt", Output: "b.txt"})
+	missing, _ := d.Add(Request{URL: server.URL + "/missing/a.txt"})
+
+	job := waitFor(t, d, file.ID, Done)
+	if job.Files != 1 || job.Done != 1 || job.Bytes != 8 || job.Total != 8 || job.Finished == nil {
+		t.Errorf("job = %+v, want 1 file of 8 bytes downloaded", job)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "b.txt")); string(got) != "contents" {
+		t.Errorf("expected the file to be downloaded, got %q", got)
+	}
+	if job = waitFor(t, d, missing.ID, Failed); job.Error == "" || job.Status != 8 {
+		t.Errorf("job = %+v, want the failure of a server error", job)
+	}
+}
+
+func TestDaemon_ExistingFile(t *testing.T) {
+	server := newServer(t)
+	d, dir := newDaemon(t)
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.schedule(cx)
+
+	// the job has no partial files, the existing file is left to the clobber policy
+	job, _ := d.Add(Request{URL: server.URL + "/a.txt"})
+	waitFor(t, d, job.ID, Done)
+	if got, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(got) != "existing" {
+		t.Errorf("expected the existing file to be kept, got %q", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "a(1).txt")); string(got) != "contents" {
+		t.Errorf("expected the file to be downloaded under a unique name, got %q", got)
+	}
+}
+
+func TestDaemon_Pause(t *testing.T) {
+	server := newServer(t)
+	d, dir := newDaemon(t)
+	cx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.schedule(cx)
+
+	slow, _ := d.Add(Request{URL: server.URL + "/slow/a.txt"})
+	next, _ := d.Add(Request{URL: server.URL + "/b.txt"})
+	waitFor(t, d, slow.ID, Running)
+	if job, _ := d.Job(next.ID); job.State != Queued {
+		t.Errorf("expected the next job to wait, got %s", job.State)
+	}
+
+	if _, err := d.Pause(slow.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, d, next.ID, Done)
+	if job := waitFor(t, d, slow.ID, Paused); job.Finished == nil || !job.Partial {
+		t.Errorf("job = %+v, want the paused job stopped, leaving its partial file", job)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.txt.part")); err != nil {
+		t.Errorf("expected the partial file to be kept, got %v", err)
+	}
+}
+
+func TestDaemon_Restart(t *testing.T) {
+	server := newServer(t)
+	d, _ := newDaemon(t)
+	cx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		d.schedule(cx)
+		close(stopped)
+	}()
+
+	slow, _ := d.Add(Request{URL: server.URL + "/slow/a.txt"})
+	paused, _ := d.Add(Request{URL: server.URL + "/b.txt"})
+	_, _ = d.Pause(paused.ID)
+	waitFor(t, d, slow.ID, Running)
+	cancel()
+	<-stopped
+
+	restarted, err := New(d.options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := restarted.Jobs()
+	if len(jobs) != 2 || jobs[0].State != Queued || jobs[1].State != Paused {
+		t.Errorf("New() jobs = %+v, want the running job queued again, and the paused job kept", jobs)
+	}
+}
+
+func TestDaemon_AddOnce(t *testing.T) {
+	d, _ := newDaemon(t)
+	job, added, err := d.addOnce(Request{URL: "https://example.com/a.txt"})
+	if err != nil || !added {
+		t.Fatalf("addOnce() = %v, %v, want the job added", added, err)
+	}
+
+	// the daemon starts again, with the same URLs
+	restarted, err := New(d.options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, added, err := restarted.addOnce(Request{URL: "https://example.com/a.txt"})
+	if err != nil || added || queued.ID != job.ID {
+		t.Errorf("addOnce() = %+v, %v, %v, want the queued job %s", queued, added, err, job.ID)
+	}
+	if _, added, _ = restarted.addOnce(Request{URL: "https://example.com/a.txt", Mirror: true}); !added {
+		t.Errorf("expected the mirror of the URL to be added, as a job of its own")
+	}
+	if jobs := restarted.Jobs(); len(jobs) != 2 {
+		t.Errorf("Jobs() = %+v, want the download, and the mirror, once each", jobs)
+	}
+}
+
+func TestNew_InvalidQueue(t *testing.T) {
+	queue := filepath.Join(t.TempDir(), "queue.json")
+	if err := os.WriteFile(queue, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(Options{Queue: queue}); err == nil {
+		t.Error("New() error = nil, want the invalid queue")
+	}
+}
+
+func TestDefaultQueue(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/state")
+	if got := DefaultQueue(); got != "/state/wget/queue.json" {
+		t.Errorf("DefaultQueue() = %q", got)
+	}
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("HOME", "/home/user")
+	if got := DefaultQueue(); got != "/home/user/.local/state/wget/queue.json" {
+		t.Errorf("DefaultQueue() = %q", got)
+	}
+}
@@ -0,0 +1,170 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"wget/progress"
+)
+
+// EventJob is the kind of the events reporting the new State of a job, as it's added, started, paused, resumed,
+// reprioritized, finished, or removed
+const EventJob = "job"
+
+// Event is an event of the daemon, streamed by GET /events: the progress.Event of a download of a job, as
+// reported by --output-format=jsonl, or the new State of a job, of the EventJob events, e.g.
+//
+//	{"job":"3f9a2c1b","event":"progress","time":"2024-10-18T10:00:00Z","id":0,"url":"https://example.com/a.iso","downloaded":1024,"total":76800}
+//	{"job":"3f9a2c1b","event":"job","time":"2024-10-18T10:00:01Z","state":{"id":"3f9a2c1b","state":"done",...}}
+type Event struct {
+	// Job is the ID of the job
+	Job string `json:"job"`
+	progress.Event
+	// State is the job, of the EventJob events
+	State *Job `json:"state,omitempty"`
+}
+
+// subscribers receive the events of the hub, up to their buffer; a subscriber too slow to keep up misses
+// the events that don't fit
+const subscriberBuffer = 256
+
+// hub publishes the events of the daemon to its subscribers
+type hub struct {
+	mutex sync.Mutex
+	// subscribers maps the channels of the subscribers to the jobs they subscribed to, all if empty
+	subscribers map[chan Event]string
+}
+
+func newHub() *hub {
+	return &hub{subscribers: map[chan Event]string{}}
+}
+
+// subscribe returns the channel of the events of the given job, all if empty, and the function that
+// unsubscribes from them
+func (h *hub) subscribe(job string) (<-chan Event, func()) {
+	events := make(chan Event, subscriberBuffer)
+	h.mutex.Lock()
+	h.subscribers[events] = job
+	h.mutex.Unlock()
+	return events, func() {
+		h.mutex.Lock()
+		delete(h.subscribers, events)
+		h.mutex.Unlock()
+	}
+}
+
+// publish sends the given event to the subscribers of its job, never blocking
+func (h *hub) publish(e Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for events, job := range h.subscribers {
+		if job != "" && job != e.Job {
+			continue
+		}
+		select {
+		case events <- e:
+		default:
+		}
+	}
+}
+
+// recorder records the progress of the downloads of a job, from the events written by a progress.JSONL
+// reporter, and publishes them
+type recorder struct {
+	daemon *Daemon
+	job    *Job
+	// downloads are the bytes downloaded, and the sizes, of the downloads, by the IDs of the events, as counted
+	// in the totals of the job
+	downloads map[int]*[2]int64
+}
+
+// recorder returns the writer of the events of a reporter of the given job. Each mirrored website is reported
+// by a reporter of its own, whose IDs start from 0 again, thus, each is given a recorder of its own
+func (d *Daemon) recorder(job *Job) *recorder {
+	return &recorder{daemon: d, job: job, downloads: map[int]*[2]int64{}}
+}
+
+// Write records the event of the given line; the reporter writes each line whole
+func (r *recorder) Write(line []byte) (int, error) {
+	var e progress.Event
+	if err := json.Unmarshal(line, &e); err != nil {
+		return len(line), nil
+	}
+	d := r.daemon
+	d.mutex.Lock()
+	job := r.job
+	if e.ID != nil {
+		download, ok := r.downloads[*e.ID]
+		if !ok {
+			download = &[2]int64{}
+			r.downloads[*e.ID] = download
+		}
+		switch e.Event {
+		case progress.EventStart:
+			job.Files++
+		case progress.EventFinished:
+			job.Done++
+		}
+		// the size may be known once the download finishes only
+		if e.Downloaded != nil && e.Total != nil {
+			downloaded, total := *e.Downloaded, max(*e.Total, 0)
+			job.Bytes += downloaded - download[0]
+			job.Total += total - download[1]
+			download[0], download[1] = downloaded, total
+		}
+	}
+	if time.Since(d.saved) >= time.Second {
+		if err := d.save(); err != nil {
+			d.logf("failed to save the queue %s: %v", d.options.Queue, err)
+		}
+	}
+	d.mutex.Unlock()
+
+	d.events.publish(Event{Job: job.ID, Event: e})
+	return len(line), nil
+}
+
+// serveEvents streams the events of the daemon, or of the job given by the `job` query parameter, as
+// server-sent events, named after their kinds, until the client goes away, or the daemon stops
+func (d *Daemon) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming isn't supported"))
+		return
+	}
+	job := r.URL.Query().Get("job")
+	if _, err := d.Job(job); job != "" && err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	events, unsubscribe := d.events.subscribe(job)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// the comments keep the idle connections from being closed by the proxies
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+		case e := <-events:
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Event.Event, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"testing"
+	"wget/progress"
+)
+
+func TestHub(t *testing.T) {
+	h := newHub()
+	all, unsubscribeAll := h.subscribe("")
+	a, unsubscribeA := h.subscribe("a")
+	defer unsubscribeA()
+
+	h.publish(Event{Job: "a"})
+	h.publish(Event{Job: "b"})
+	if len(all) != 2 || len(a) != 1 {
+		t.Errorf("expected 2 events for all the jobs, and 1 for a, got %d and %d", len(all), len(a))
+	}
+
+	// the events that don't fit are dropped, rather than blocking the downloads
+	for i := 0; i < subscriberBuffer; i++ {
+		h.publish(Event{Job: "a"})
+	}
+	if len(a) != subscriberBuffer {
+		t.Errorf("expected the buffer to be full, got %d", len(a))
+	}
+
+	unsubscribeAll()
+	h.publish(Event{Job: "a"})
+	if len(all) != subscriberBuffer {
+		t.Errorf("expected no more events once unsubscribed, got %d", len(all))
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	d, _ := newDaemon(t)
+	job := &Job{ID: "a"}
+	events, unsubscribe := d.events.subscribe("a")
+	defer unsubscribe()
+
+	// the events of a jsonl reporter; the second download's size is only known once finished
+	reporter := progress.New(progress.Options{Format: progress.JSONL, Out: d.recorder(job)})
+	a, b := reporter.Listener(0, "https://example.com/a.txt"), reporter.Listener(1, "https://example.com/b.txt")
+	a.OnStart(job.Added)
+	b.OnStart(job.Added)
+	a.OnContentLength(100)
+	a.OnProgress(40, 100, -1)
+	a.OnProgress(100, 100, -1)
+	a.OnDownloadFinished("https://example.com/a.txt", job.Added)
+	b.OnProgress(50, -1, -1)
+	b.OnContentLength(50)
+	b.OnDownloadFinished("https://example.com/b.txt", job.Added)
+
+	if job.Files != 2 || job.Done != 2 || job.Bytes != 150 || job.Total != 150 {
+		t.Errorf("recorded %+v, want 2/2 files, 150/150 bytes", job)
+	}
+	if e := <-events; e.Job != "a" || e.Event.Event != progress.EventStart {
+		t.Errorf("expected the events to be published, got %+v", e)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -21,7 +22,7 @@ func TestToFile_Success(t *testing.T) {
 	defer mockServer.Close()
 
 	// Call the ToFile function with the mock server URL
-	filename := "testfile.txt"
+	filename := filepath.Join(t.TempDir(), "testfile.txt")
 	err := ToFile(mockServer.URL, filename)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
@@ -46,7 +47,7 @@ func TestToFile_HttpError(t *testing.T) {
 	defer mockServer.Close()
 
 	// Call the ToFile function with the mock server URL
-	err := ToFile(mockServer.URL, "testfile.txt")
+	err := ToFile(mockServer.URL, filepath.Join(t.TempDir(), "testfile.txt"))
 	if err == nil || err.Error() != "error downloading file: 404 Not Found" {
 		t.Errorf("expected error downloading file: 404 Not Found, got %v", err)
 	}
@@ -2,18 +2,31 @@
 package downloader
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"wget/ctx"
 	"wget/fetch"
+	"wget/fileio"
 	"wget/globals"
+	"wget/httpx"
+	"wget/limitedio"
 	"wget/mirror"
+	"wget/pace"
+	"wget/progress"
 	"wget/syscheck"
+	"wget/xerr"
 )
 
 // arg represents the commandline arguments passed through the command line by the user
@@ -23,86 +36,268 @@ import (
 // https://www.example.com is the link to where the resource resides
 type arg struct {
 	*ctx.Context
+	// reporter reports the progress of the downloads, as selected by --progress, --quiet, etc.
+	reporter progress.Reporter
+	// options are the options of the program embedding the downloads, see Run
+	options Options
 }
 
-// Get downloads any files, website mirrors, or resources as defined by the provided download context
-func Get(c ctx.Context) {
-	a := arg{Context: &c}
+// Options are the options of Run, given by the programs embedding the downloads, rather than on the command line
+type Options struct {
+	// Reporter returns the reporter of the progress of the downloads; called once for all the downloads,
+	// including those of all the mirrored websites
+	Reporter func() progress.Reporter
+	// Stdout is where the downloaded contents are written to, with -O=-
+	Stdout io.Writer
+	// Limiter, Pacer, and Quota, unless nil, are shared with the downloads of other runs, e.g., of the calls of
+	// a client embedding the downloads; otherwise, they're created for this run, as defined by --rate-limit,
+	// --rate-schedule, --wait, and --quota
+	Limiter *limitedio.SharedLimiter
+	Pacer   *pace.Pacer
+	Quota   *limitedio.Quota
+}
+
+// Stdout is the name of the output file, given to -O, that stands for stdout
+const Stdout = "-"
+
+// stdout writes the downloaded contents to the Stdout of the Options, for -O=-. Closing it doesn't close
+// the writer, such that the contents of all the URLs are concatenated
+type stdout struct {
+	io.Writer
+}
+
+func (stdout) Close() error { return nil }
+
+// Name is the name the output is reported by, in the progress of the download
+func (stdout) Name() string { return "STDOUT" }
+
+// Get downloads any files, website mirrors, or resources as defined by the provided download context.
+// Returns an error wrapping xerr.ErrQuotaExceeded or xerr.ErrFileTooLarge, if the --quota was exceeded, or some
+// files were larger than --max-filesize, respectively; or any other error that failed the download.
+//
+// Once the given context is done, no new downloads are started, and the active downloads are stopped,
+// keeping their partial files to be continued later; the returned error then wraps the context's error
+//
+// With -O=-, the downloaded contents are written to stdout, thus, the progress is written to stderr instead,
+// unless it is written to a log file, see syscheck.Out. The reporters are wrapped by track, unless nil, e.g.,
+// to record the progress of a background job
+func Get(cx context.Context, c ctx.Context, track func(progress.Reporter) progress.Reporter) error {
+	if c.OutputFile == Stdout && syscheck.Output() == os.Stdout {
+		defer func(out io.Writer) { syscheck.Out = out }(syscheck.Out)
+		syscheck.Out = os.Stderr
+	}
+	return Run(cx, c, Options{
+		Reporter: func() progress.Reporter {
+			if track != nil {
+				return track(Reporter(c))
+			}
+			return Reporter(c)
+		},
+		Stdout: os.Stdout,
+	})
+}
+
+// Reporter returns the reporter selected by the given download context, e.g., by --quiet, or --output-format,
+// that reports to the terminal output, see syscheck.Output
+func Reporter(c ctx.Context) progress.Reporter {
+	return progress.New(progress.Options{
+		Format:         c.OutputFormat,
+		Verbosity:      c.Verbosity,
+		Progress:       c.Progress,
+		Log:            c.LogFile != "",
+		ServerResponse: c.ServerResponse,
+	})
+}
+
+// Run downloads the files, or website mirrors, defined by the provided download context, as Get does,
+// but reports their progress to the reporters of the given options, and writes the downloaded contents,
+// with -O=-, to their Stdout. Run never writes to the terminal itself
+func Run(cx context.Context, c ctx.Context, options Options) error {
+	a := arg{Context: &c, options: options}
+	// all the downloads share a single pacer, so that the delay between requests is
+	// respected per host, regardless of how many downloads target the same host
+	if a.options.Pacer == nil {
+		a.options.Pacer = pace.New(c.Wait, c.RandomWait)
+	}
+	// likewise, all the downloads share the bandwidth defined by --rate-limit, and the --quota
+	if a.options.Limiter == nil {
+		a.options.Limiter = limitedio.NewSharedLimiter(int32(c.RateLimitValue), c.RateBurstValue)
+		if len(c.RateScheduleValue) != 0 {
+			// change the rate limit as the schedule moves to the next time window
+			stop, cancel := context.WithCancel(cx)
+			defer cancel()
+			a.options.Limiter.Follow(stop, a.rateAt, int32(c.RateLimitValue))
+		}
+	}
+	if a.options.Quota == nil {
+		a.options.Quota = limitedio.NewQuota(c.QuotaValue)
+	}
+	a.reporter = options.Reporter()
+	a.reporter.Start()
+	// the run is summed up once all the messages are reported, see progress.EventSummary
+	defer a.reporter.Finish()
 	var err error
 	var dType string
 	if a.Mirror {
 		// run in mirror mode
-		err = a.MirrorWeb()
+		err = a.MirrorWeb(cx)
 		dType = "mirror"
 	} else {
 		// regular download
-		err = a.Download()
+		err = a.Download(cx)
 		dType = "download"
 	}
 	if err != nil {
-		syscheck.ShowCursor()
-		fmt.Printf("\n%s failed: %v\n", dType, err)
+		a.reporter.Printf("\n%s failed: %v\n", dType, err)
 	}
+	return err
 }
 
 // Download handles each download and prints progress across 6 lines.
-func (a *arg) Download() error {
+// The URLs are downloaded concurrently, unless they're written to stdout, in which case, they're
+// downloaded one after the other, such that their contents are concatenated in order
+func (a *arg) Download(cx context.Context) error {
 	var wg sync.WaitGroup
 	successfulDownloads := make(chan string, len(a.Links))
+	// downloaded keeps the bytes read by all the downloads, as they're read, against the --quota
+	downloaded := a.options.Quota
+	// skipped counts the files not downloaded due to the --quota, or the --max-filesize
+	var quotaSkipped, tooLarge atomic.Int32
+	// interrupted counts the downloads stopped, or never started, as the context was done
+	var interrupted atomic.Int32
+	// existing counts the files not downloaded, as they already exist, and may not be clobbered
+	var existing atomic.Int32
+	// failures keeps the errors of the downloads that failed, other than those counted above
+	var failures []error
+	var failuresMutex sync.Mutex
+	// unless specified, downloads never replace existing files, they're saved under unique names instead
+	policy := fileio.NewPolicy(a.Clobber, a.Backups, fileio.UniqueNames)
 
-	syscheck.MoveCursor(1)
-	syscheck.ClearScreen()
-	syscheck.HideCursor()
-	defer syscheck.ShowCursor() // Ensure cursor is shown again when done
-
-	// how many rows each download progress indicator for a given link is allocated
-	rows := 9
 	for i, url := range a.Links {
 		lineNumber := i
 		wg.Add(1)
-		go func() {
+		download := func() {
 			defer wg.Done()
-			outputFilePath := CheckIfFileExists(a.determineOutputPath(url))
-
-			GetFile := func(downloadUrl string, header http.Header) (*os.File, error) {
-				return os.OpenFile(outputFilePath, os.O_RDWR|os.O_CREATE, 0644)
+			if cx.Err() != nil {
+				// don't start any new downloads once interrupted
+				interrupted.Add(1)
+				a.reporter.Error(lineNumber, url, fmt.Errorf("skipped: %w", cx.Err()))
+				return
+			}
+			// the download is stopped once another download exceeds the quota
+			dcx, id, ok := downloaded.Start(cx)
+			if !ok {
+				// don't start any new downloads once the quota is exceeded
+				quotaSkipped.Add(1)
+				a.reporter.Error(lineNumber, url, fmt.Errorf("skipped: %w", xerr.ErrQuotaExceeded))
+				return
 			}
+			defer downloaded.Done(id)
 
-			// define a download status listener for the current mirror URL
-			status := fetch.DownloadStatus{}
-			status.OnUpdate = func(status *fetch.DownloadStatus, hint int) {
-				// whenever the status of this download has changed, we print the progress to its
-				// respective position in the terminal
-				globals.PrintLines((lineNumber*rows)+hint, []string{status.GetField(hint)})
+			GetFile := func(downloadUrl string, header http.Header) (io.WriteCloser, error) {
+				if a.OutputFile == Stdout {
+					return stdout{a.options.Stdout}, nil
+				}
+				// the name of the file is decided once the response headers arrive
+				outputFilePath := a.determineOutputPath(downloadUrl, header)
+				if !a.Continue {
+					// when continuing, the partial file is saved over the file it stands for
+					var err error
+					if outputFilePath, err = policy.Resolve(outputFilePath); err != nil {
+						return nil, err
+					}
+				}
+				return fileio.OpenPart(outputFilePath)
+			}
+			var resumeFrom func(url string, header http.Header) int64
+			if a.Continue && a.OutputFile != Stdout {
+				resumeFrom = func(url string, header http.Header) int64 {
+					return fileio.PartSize(a.determineOutputPath(url, header))
+				}
 			}
-			globals.PrintLines(lineNumber*rows, globals.StringTimes("\033[38;5;208m···\033[0m", rows-1))
 
 			// configure an Advanced Progress Listener for the GET request
-			advancedProgressListener := *status.ProgressListener()
-			_, err := fetch.URL(
+			advancedProgressListener := a.reporter.Listener(lineNumber, url)
+			// read keeps the bytes read by this download, added to the others as they're read; the bytes of
+			// the partial file it continues were read by an earlier run, they don't count against the --quota
+			var read int64
+			{
+				originalOnResume := advancedProgressListener.OnResume
+				advancedProgressListener.OnResume = func(offset int64) {
+					read = offset
+					originalOnResume(offset)
+				}
+				originalOnProgress := advancedProgressListener.OnProgress
+				advancedProgressListener.OnProgress = func(bytes, total int64, rate int32) {
+					if rate < 0 {
+						downloaded.Add(id, bytes-read)
+						read = bytes
+					}
+					originalOnProgress(bytes, total, rate)
+				}
+			}
+			info, err := fetch.URL(
+				dcx,
 				url,
 				fetch.Config{
 					GetFile:                  GetFile,
-					Limit:                    int32(a.RateLimitValue),
+					ResumeFrom:               resumeFrom,
+					KeepPartial:              a.KeepPartial || a.Continue,
+					Limit:                    int32(a.FileRateLimitValue),
+					Limiter:                  a.options.Limiter,
+					MaxFileSize:              a.MaxFileSizeValue,
+					MinFreeSpace:             a.MinFreeSpaceValue,
+					Pacer:                    a.options.Pacer,
 					ProgressListener:         nil,
 					RateListener:             nil,
 					Body:                     nil,
 					Method:                   "GET",
 					AllowedStatusCodes:       []int{http.StatusOK},
+					SaveHeaders:              a.SaveHeaders == fetch.HeadersInline,
 					AdvancedProgressListener: advancedProgressListener,
 				},
 			)
+			if err == nil && a.SaveHeaders == fetch.HeadersSidecar && a.OutputFile != Stdout {
+				if err = info.SaveHeaders(); err != nil {
+					err = xerr.WithStatus(xerr.IOStatus, fmt.Errorf("failed to save the headers: %w", err))
+				}
+			}
 
+			if err != nil && cx.Err() == nil && dcx.Err() != nil {
+				// another download exceeded the quota, the partial file is kept for --continue
+				quotaSkipped.Add(1)
+				err = fmt.Errorf("stopped: %w", xerr.ErrQuotaExceeded)
+			}
+			if errors.Is(err, xerr.ErrFileTooLarge) {
+				tooLarge.Add(1)
+			}
+			if errors.Is(err, fileio.ErrFileExists) {
+				existing.Add(1)
+			}
+			if cx.Err() != nil && err != nil {
+				interrupted.Add(1)
+			}
+			// the files too large, or existing, are summed up below, and the interruption overrides any failure
+			skipped := errors.Is(err, xerr.ErrFileTooLarge) || errors.Is(err, fileio.ErrFileExists) ||
+				errors.Is(err, xerr.ErrQuotaExceeded) || cx.Err() != nil
+			if err != nil && !skipped {
+				failuresMutex.Lock()
+				failures = append(failures, fmt.Errorf("%s: %w", url, err))
+				failuresMutex.Unlock()
+			}
 			if err != nil {
-				errString := fmt.Sprintf("error: %s", err.Error())
-				errString = strings.Replace(errString, "\n", " : ", -1)
-				globals.PrintLines((lineNumber*rows)+5, []string{errString})
+				a.reporter.Error(lineNumber, url, err)
 			} else {
 				if lineNumber != len(a.Links) {
 					successfulDownloads <- url
 				}
 			}
-		}()
+		}
+		if a.OutputFile == Stdout {
+			download()
+		} else {
+			go download()
+		}
 	}
 
 	// wait for all go routines to finish in order to close the channel
@@ -116,64 +311,80 @@ func (a *arg) Download() error {
 		successList = append(successList, url)
 	}
 
-	if len(a.Links) != 0 {
-		// move the cursor to the terminal row after the last progress
-		syscheck.MoveCursor(len(a.Links) * rows)
-	}
-
 	if len(successList) > 1 {
 		// Print the successfully downloaded URLs
-		fmt.Printf("\nDownloads finished:\t%v\n", successList)
+		a.reporter.Printf("\nDownloads finished:\t%v\n", successList)
 	}
 
-	return nil
-}
-
-// CheckIfFileExists checks if a file with the provided name exists. If it exists, it will add
-// a number starting from 1 between the filename and the beginning of extension
-// example: if file.txt exist CheckIfFileExist will generate a new name file1.txt. It does this iteratively.
-func CheckIfFileExists(filename string) string {
-	if strings.TrimSpace(filename) == "" {
-		return ""
+	if n := interrupted.Load(); n > 0 {
+		a.reporter.Printf("\nInterrupted: %d downloads stopped, run again with --continue to pick them up\n", n)
+		return fmt.Errorf("download interrupted: %w", cx.Err())
 	}
-	// get the file extension
-	extension := filepath.Ext(filename)
-	base := filename
-	if extension != "" {
-		base = filename[:len(filename)-len(extension)]
+	if n := existing.Load(); n > 0 {
+		a.reporter.Printf("\nSkipped: %d files that already exist\n", n)
+	}
+	if n := tooLarge.Load(); n > 0 {
+		a.reporter.Printf("\nSkipped: %d files larger than %s\n", n, globals.FormatSize(a.MaxFileSizeValue))
+	}
+	// the program exits with the status of the most severe failure, see xerr.ExitStatus
+	errs := failures
+	failed := len(failures) + int(quotaSkipped.Load()+tooLarge.Load())
+	if n := quotaSkipped.Load(); n > 0 {
+		a.reporter.Printf("\nDownload quota of %s EXCEEDED! Skipped %d files\n", globals.FormatSize(a.QuotaValue), n)
+		errs = append(errs, fmt.Errorf(
+			"%w: downloaded %s", xerr.ErrQuotaExceeded, globals.FormatSize(downloaded.Downloaded()),
+		))
+	} else if n := tooLarge.Load(); n > 0 {
+		errs = append(errs, fmt.Errorf("%w: skipped %d files", xerr.ErrFileTooLarge, n))
 	}
-	n := 1
 
-	for {
-		_, err := os.Stat(filename)
-		if os.IsNotExist(err) {
-			return filename
-		}
+	return xerr.Join(fmt.Sprintf("%d of %d downloads failed", failed, len(a.Links)), errs...)
+}
 
-		filename = fmt.Sprintf("%s(%d)%s", base, n, extension)
-		n++
-	}
+// rateAt returns the rate limit of the --rate-schedule window that contains the given time
+func (a *arg) rateAt(t time.Time) (int32, bool) {
+	rate, ok := a.RateScheduleValue.RateAt(t)
+	return int32(rate), ok
 }
 
-// determineOutputPath determines the full path for the output file
-func (a *arg) determineOutputPath(url string) string {
-	var outputFilePath string
+// CheckIfFileExists checks if a file with the provided name exists. If it exists, it will add
+// a number starting from 1 between the filename and the beginning of extension
+// example: if file.txt exist CheckIfFileExist will generate a new name file(1).txt. It does this iteratively.
+// See fileio.UniqueName
+func CheckIfFileExists(filename string) string {
+	return fileio.UniqueName(filename)
+}
 
+// determineOutputPath determines the full path for the output file, of the resource downloaded from the
+// given (final) url, with the given response headers. Unless specified by -O, the file is named after the
+// Content-Disposition header, with --content-disposition, or the last segment of the url's path, or index.html;
+// then sanitized as per --restrict-file-names, such that the file is always saved within the -P directory
+func (a *arg) determineOutputPath(downloadUrl string, header http.Header) string {
 	if a.OutputFile != "" {
-		outputFilePath = filepath.Join(a.SavePath, a.OutputFile)
-	} else {
-		// get the filename from the url
-		tokens := strings.Split(url, "/")
-		filename := tokens[len(tokens)-1]
+		// the user named the file, it is saved as is
+		return filepath.Join(a.SavePath, a.OutputFile)
+	}
 
-		// If SavePath is specified use it otherwise use the current directory
-		if a.SavePath != "" {
-			outputFilePath = filepath.Join(a.SavePath, filename)
-		} else {
-			outputFilePath = filename
+	var filename string
+	if a.ContentDisposition {
+		filename, _ = httpx.FilenameFromContentDisposition(header)
+	}
+	if filename == "" {
+		// get the filename from the url, ignoring any query string
+		if u, err := url.Parse(downloadUrl); err == nil {
+			filename = path.Base(u.Path)
 		}
 	}
-	return outputFilePath
+	if filename == "" || filename == "." || filename == "/" {
+		filename = "index.html"
+	}
+	if a.AdjustExtension {
+		filename = mirror.AdjustExtension(filename, header)
+	}
+	filename = a.RestrictFileNamesValue.Name(filename)
+
+	// If SavePath is specified use it otherwise use the current directory
+	return filepath.Join(a.SavePath, filename)
 }
 
 // IsEmpty function checks whether an iterable is empty, an iterable is a string,array or slice.
@@ -201,13 +412,25 @@ func (a *arg) IsEmpty(data interface{}) bool {
 	}
 }
 
-func (a *arg) MirrorWeb() (gErr error) {
+// MirrorWeb mirrors the websites of all the links, one after the other, reported as a whole, and within a single
+// --quota. Returns the failures of all the mirrors, see xerr.Join
+func (a *arg) MirrorWeb(cx context.Context) error {
+	shared := &mirror.Shared{
+		Reporter: a.reporter, Limiter: a.options.Limiter, Pacer: a.options.Pacer, Quota: a.options.Quota,
+	}
+	var errs []error
 	for _, link := range a.Links {
-		err := mirror.Site(*a.Context, link)
-		if err != nil {
-			gErr = err
-			continue
+		if cx.Err() != nil {
+			// don't start mirroring the remaining links once interrupted
+			errs = append(errs, fmt.Errorf("mirror interrupted: %w", cx.Err()))
+			break
+		}
+		if err := mirror.SiteWith(cx, *a.Context, link, shared); err != nil {
+			errs = append(errs, err)
+			if cx.Err() != nil {
+				break
+			}
 		}
 	}
-	return
+	return xerr.Join(fmt.Sprintf("%d of %d mirrors failed", len(errs), len(a.Links)), errs...)
 }
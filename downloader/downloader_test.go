@@ -1,12 +1,24 @@
 package downloader
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"wget/ctx"
+	"wget/fetch"
 	"wget/httpx"
+	"wget/limitedio"
+	"wget/progress"
+	"wget/syscheck"
+	"wget/xerr"
 )
 
 // func TestGetResource_Success(t *testing.T) {
@@ -90,19 +102,73 @@ func TestDetermineOutputPath(t *testing.T) {
 
 	url := "http://example.com/file.txt"
 	expected := "file.txt"
-	result := a.determineOutputPath(url)
+	result := a.determineOutputPath(url, http.Header{})
 	if result != expected {
 		t.Fatalf("Expected %s but got %s", expected, result)
 	}
 
 	c.SavePath = "/path/to/dir"
 	expected = "/path/to/dir/file.txt"
-	result = a.determineOutputPath(url)
+	result = a.determineOutputPath(url, http.Header{})
 	if result != expected {
 		t.Fatalf("Expected %s but got %s", expected, result)
 	}
 }
 
+func TestDetermineOutputPath_Headers(t *testing.T) {
+	disposition := http.Header{}
+	disposition.Set("Content-Disposition", "attachment; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf")
+
+	tests := []struct {
+		name               string
+		url                string
+		header             http.Header
+		outputFile         string
+		contentDisposition bool
+		want               string
+	}{
+		{"root url", "https://example.com/", http.Header{}, "", false, "index.html"},
+		{"no path", "https://example.com", http.Header{}, "", false, "index.html"},
+		{"query string", "https://example.com/download?id=42", http.Header{}, "", false, "download"},
+		{"escaped path", "https://example.com/my%20file.txt", http.Header{}, "", false, "my file.txt"},
+		{"disposition ignored", "https://example.com/download?id=42", disposition, "", false, "download"},
+		{"disposition", "https://example.com/download?id=42", disposition, "", true, "résumé.pdf"},
+		{"no disposition", "https://example.com/download?id=42", http.Header{}, "", true, "download"},
+		{"output file", "https://example.com/download?id=42", disposition, "cv.pdf", true, "cv.pdf"},
+		{"escaped traversal", "https://example.com/..%2F..%2F.bashrc", http.Header{}, "", false, ".bashrc"},
+		{"escaped parent", "https://example.com/a/%2E%2E", http.Header{}, "", false, "%2E%2E"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				a := arg{Context: &ctx.Context{OutputFile: tt.outputFile, ContentDisposition: tt.contentDisposition}}
+				if got := a.determineOutputPath(tt.url, tt.header); got != tt.want {
+					t.Errorf("determineOutputPath(%q) = %q, want %q", tt.url, got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestDetermineOutputPath_AdjustExtension(t *testing.T) {
+	html := http.Header{}
+	html.Set("Content-Type", "text/html")
+
+	a := arg{Context: &ctx.Context{AdjustExtension: true}}
+	if got := a.determineOutputPath("https://example.com/page.php?id=1", html); got != "page.php.html" {
+		t.Errorf("expected the .html extension to be appended, got %q", got)
+	}
+	if got := a.determineOutputPath("https://example.com/", html); got != "index.html" {
+		t.Errorf("expected index.html to be kept, got %q", got)
+	}
+
+	// the name given by -O is used as is
+	a.OutputFile = "page.php"
+	if got := a.determineOutputPath("https://example.com/page.php", html); got != "page.php" {
+		t.Errorf("expected the output file to be used as is, got %q", got)
+	}
+}
+
 func TestRoundOfSizeOfData(t *testing.T) {
 	testCases := []struct {
 		bytes    int64
@@ -158,3 +224,359 @@ func TestCheckIfFileExists_Multi(t *testing.T) {
 		})
 	}
 }
+
+func TestGet_Stdout(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/first" {
+					// the first download finishes last, unless the downloads are sequential
+					time.Sleep(50 * time.Millisecond)
+				}
+				_, _ = fmt.Fprintf(w, "%s\n", r.URL.Path)
+			},
+		),
+	)
+	defer server.Close()
+
+	originalStdout := os.Stdout
+	defer func() { os.Stdout = originalStdout }()
+	var err error
+	os.Stdout, err = os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	savePath := t.TempDir()
+	c := ctx.Context{
+		Links:      []string{server.URL + "/first", server.URL + "/second"},
+		OutputFile: Stdout,
+		SavePath:   savePath,
+	}
+	if err = Get(context.Background(), c, nil); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if got, _ := os.ReadFile(os.Stdout.Name()); string(got) != "/first\n/second\n" {
+		t.Errorf("expected the contents to be concatenated to stdout in order, got %q", got)
+	}
+	if entries, _ := os.ReadDir(savePath); len(entries) != 0 {
+		t.Errorf("expected no files to be saved, got %v", entries)
+	}
+	if syscheck.Out != nil {
+		t.Errorf("expected the terminal output to be restored, got %v", syscheck.Out)
+	}
+}
+
+func TestGet_SaveHeaders(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Cache", "MISS")
+				_, _ = fmt.Fprint(w, "contents")
+			},
+		),
+	)
+	defer server.Close()
+
+	for _, mode := range []string{fetch.HeadersInline, fetch.HeadersSidecar} {
+		t.Run(mode, func(t *testing.T) {
+			savePath := t.TempDir()
+			c := ctx.Context{
+				Links:       []string{server.URL + "/file.txt"},
+				SavePath:    savePath,
+				Verbosity:   progress.Quiet,
+				SaveHeaders: mode,
+			}
+			if err := Get(context.Background(), c, nil); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+
+			file, _ := os.ReadFile(filepath.Join(savePath, "file.txt"))
+			sidecar, err := os.ReadFile(filepath.Join(savePath, "file.txt"+fetch.HeadersSuffix))
+			headers := string(sidecar)
+			if mode == fetch.HeadersInline {
+				if err == nil {
+					t.Errorf("expected no sidecar file, got %q", sidecar)
+				}
+				var contents string
+				headers, contents, _ = strings.Cut(string(file), "\r\n\r\n")
+				if contents != "contents" {
+					t.Errorf("expected the headers before the contents, got %q", file)
+				}
+			} else if string(file) != "contents" {
+				t.Errorf("expected the contents without the headers, got %q", file)
+			}
+			if !strings.HasPrefix(headers, "HTTP/1.1 200 OK\r\n") || !strings.Contains(headers, "\r\nX-Cache: MISS") {
+				t.Errorf("expected the headers to be saved, got %q", headers)
+			}
+		})
+	}
+}
+
+func TestGet_Failures(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/missing.txt" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = fmt.Fprint(w, "contents")
+			},
+		),
+	)
+	defer server.Close()
+
+	c := ctx.Context{
+		Links:     []string{server.URL + "/a.txt", server.URL + "/missing.txt"},
+		SavePath:  t.TempDir(),
+		Verbosity: progress.Quiet,
+	}
+	err := Get(context.Background(), c, nil)
+	if err == nil || !strings.Contains(err.Error(), "/missing.txt") {
+		t.Fatalf("expected the missing file to fail the download, got %v", err)
+	}
+	if status := xerr.ExitStatus(err); status != xerr.ServerStatus {
+		t.Errorf("expected the exit status of a server error, got %d", status)
+	}
+
+	c.Links = []string{server.URL + "/a.txt"}
+	if err = Get(context.Background(), c, nil); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestRun_Options(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = fmt.Fprint(w, "contents")
+			},
+		),
+	)
+	defer server.Close()
+
+	var stdout, out strings.Builder
+	c := ctx.Context{Links: []string{server.URL + "/a.txt"}, OutputFile: Stdout, SavePath: t.TempDir()}
+	options := Options{
+		Reporter: func() progress.Reporter {
+			return progress.New(progress.Options{Out: &out, Verbosity: progress.NoVerbose})
+		},
+		Stdout: &stdout,
+	}
+	if err := Run(context.Background(), c, options); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if stdout.String() != "contents" {
+		t.Errorf("expected the contents to be written to the Stdout of the options, got %q", stdout.String())
+	}
+	if !strings.Contains(out.String(), "a.txt") {
+		t.Errorf("expected the download to be reported to the reporter of the options, got %q", out.String())
+	}
+}
+
+func TestRun_Summary(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/missing.txt" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = fmt.Fprint(w, "contents")
+			},
+		),
+	)
+	defer server.Close()
+	other := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = fmt.Fprint(w, "contents")
+			},
+		),
+	)
+	defer other.Close()
+
+	for _, test := range []struct {
+		name   string
+		mirror bool
+		links  []string
+	}{
+		{"download", false, []string{server.URL + "/a.txt", server.URL + "/missing.txt"}},
+		{"mirror", true, []string{server.URL + "/a.txt", other.URL + "/b.txt"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var out strings.Builder
+			reporters := 0
+			options := Options{
+				Reporter: func() progress.Reporter {
+					reporters++
+					return progress.New(progress.Options{Out: &out, Format: progress.JSONL})
+				},
+				Stdout: io.Discard,
+			}
+			c := ctx.Context{Links: test.links, Mirror: test.mirror, SavePath: t.TempDir()}
+			_ = Run(context.Background(), c, options)
+			if reporters != 1 {
+				t.Errorf("expected a single reporter for the whole run, got %d", reporters)
+			}
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			summaries := strings.Count(out.String(), `"event":"summary"`)
+			if last := lines[len(lines)-1]; summaries != 1 || !strings.Contains(last, `"event":"summary"`) {
+				t.Fatalf("expected a single summary, after all the other events, got\n%s", out.String())
+			}
+			if !strings.Contains(lines[len(lines)-1], `"downloads":2`) {
+				t.Errorf("expected the summary of both downloads, got %s", lines[len(lines)-1])
+			}
+		})
+	}
+}
+
+func TestGet_QuotaParallel(t *testing.T) {
+	const size = 200 << 10
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", fmt.Sprint(size))
+				// the files are sent slowly, so that all the downloads are in progress at once
+				chunk := make([]byte, 10<<10)
+				for sent := 0; sent < size; sent += len(chunk) {
+					if _, err := w.Write(chunk); err != nil {
+						return
+					}
+					w.(http.Flusher).Flush()
+					time.Sleep(5 * time.Millisecond)
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	savePath := t.TempDir()
+	c := ctx.Context{
+		Links:      []string{server.URL + "/a.bin", server.URL + "/b.bin", server.URL + "/c.bin"},
+		SavePath:   savePath,
+		Verbosity:  progress.Quiet,
+		QuotaValue: 100 << 10,
+	}
+	err := Get(context.Background(), c, nil)
+	if status := xerr.ExitStatus(err); status != xerr.QuotaExceededStatus {
+		t.Fatalf("expected the exit status of the exceeded quota, got %d: %v", status, err)
+	}
+
+	// the download that exceeded the quota is completed, the others are stopped
+	var complete []string
+	for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+		if info, err := os.Stat(filepath.Join(savePath, name)); err == nil && info.Size() == size {
+			complete = append(complete, name)
+		}
+	}
+	if len(complete) != 1 {
+		t.Errorf("expected a single download to complete, got %v", complete)
+	}
+}
+
+func TestGet_FileRateLimit(t *testing.T) {
+	const size = 30_000
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", fmt.Sprint(size))
+				_, _ = w.Write(make([]byte, size))
+			},
+		),
+	)
+	defer server.Close()
+
+	// each file is limited, though the bandwidth shared by the files isn't
+	savePath := t.TempDir()
+	c := ctx.Context{
+		Links:              []string{server.URL + "/a.bin", server.URL + "/b.bin"},
+		SavePath:           savePath,
+		Verbosity:          progress.Quiet,
+		FileRateLimitValue: 20_000,
+	}
+	start := time.Now()
+	if err := Get(context.Background(), c, nil); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("expected the files of %d bytes to take about 0.5s at 20000 bytes/s, took %v", size, elapsed)
+	}
+	for _, name := range []string{"a.bin", "b.bin"} {
+		if info, err := os.Stat(filepath.Join(savePath, name)); err != nil || info.Size() != size {
+			t.Errorf("expected %s to be downloaded, got %v", name, err)
+		}
+	}
+}
+
+func TestRun_QuotaResumed(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				http.ServeContent(w, r, r.URL.Path, time.Time{}, strings.NewReader(content))
+			},
+		),
+	)
+	defer server.Close()
+
+	// an earlier run downloaded 900 bytes of the file, the quota of this run leaves room for the 100 left
+	savePath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(savePath, "a.txt.part"), []byte(content[:900]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	options := Options{
+		Reporter: func() progress.Reporter { return progress.New(progress.Options{Out: io.Discard}) },
+		Quota:    limitedio.NewQuota(500),
+	}
+	c := ctx.Context{Links: []string{server.URL + "/a.txt"}, SavePath: savePath, Continue: true, QuotaValue: 500}
+	if err := Run(context.Background(), c, options); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := options.Quota.Downloaded(); got != 100 {
+		t.Errorf("expected only the 100 bytes of this run to count against the quota, got %d", got)
+	}
+
+	c.Links = []string{server.URL + "/b.txt"}
+	if err := Run(context.Background(), c, options); err != nil {
+		t.Errorf("expected the quota not to be exceeded yet, got %v", err)
+	}
+}
+
+func TestRun_QuotaMirrors(t *testing.T) {
+	newSite := func() *httptest.Server {
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "text/plain")
+					_, _ = fmt.Fprint(w, strings.Repeat("x", 100))
+				},
+			),
+		)
+		t.Cleanup(server.Close)
+		return server
+	}
+	first, second := newSite(), newSite()
+
+	savePath := t.TempDir()
+	c := ctx.Context{
+		Links:      []string{first.URL + "/a.txt", second.URL + "/b.txt"},
+		Mirror:     true,
+		SavePath:   savePath,
+		Verbosity:  progress.Quiet,
+		QuotaValue: 50,
+	}
+	err := Get(context.Background(), c, nil)
+	if status := xerr.ExitStatus(err); status != xerr.QuotaExceededStatus {
+		t.Fatalf("expected the quota to apply to both mirrors together, got %d: %v", status, err)
+	}
+	if _, err = os.Stat(filepath.Join(savePath, "127.0.0.1", "a.txt")); err != nil {
+		t.Errorf("expected the first mirror to complete the download that exceeded the quota, got %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(savePath, "127.0.0.1", "b.txt")); err == nil {
+		t.Errorf("expected the second mirror not to start once the first exceeded the quota")
+	}
+}
@@ -3,6 +3,9 @@
 package fetch
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -17,13 +20,19 @@ import (
 	"wget/globals"
 	"wget/httpx"
 	"wget/limitedio"
+	"wget/logfile"
+	"wget/pace"
 	"wget/syscheck"
+	"wget/xerr"
 )
 
 const KiB = 1024
 
 var client = &http.Client{}
 
+// ErrSkipped is returned when the resource isn't downloaded, as Config.ShouldDownload rejected it
+var ErrSkipped = errors.New("skipping download")
+
 // headers our http client will send by default. Adapted from Chrome,
 // as some web servers will deny requests without a valid user agent
 var headers = map[string]string{
@@ -50,6 +59,48 @@ type FileInfo struct {
 	Name       string
 	Headers    http.Header
 	StatusCode int
+	// Proto and Status are the protocol, and the status, of the response, e.g., "HTTP/1.1" and "200 OK"
+	Proto  string
+	Status string
+}
+
+// The ways the headers of downloaded files are saved, as given to --save-headers
+const (
+	// HeadersInline writes the headers to the downloaded file, before its contents, see Config.SaveHeaders
+	HeadersInline = "inline"
+	// HeadersSidecar writes the headers to a file of their own, next to the downloaded file, see FileInfo.SaveHeaders
+	HeadersSidecar = "sidecar"
+)
+
+// HeadersSuffix is the suffix of the file the headers of a downloaded file are saved to, see FileInfo.SaveHeaders
+const HeadersSuffix = ".headers"
+
+// WriteHeaders writes the status line, and the headers, of the response the file was downloaded from, followed
+// by an empty line, as the server would have sent them, e.g., "HTTP/1.1 200 OK\r\nContent-Length: 4\r\n\r\n"
+func (info FileInfo) WriteHeaders(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%s %s\r\n", info.Proto, info.Status); err != nil {
+		return err
+	}
+	if err := info.Headers.Write(w); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+// SaveHeaders saves the headers of the downloaded file, see FileInfo.WriteHeaders, to a file named after
+// the downloaded file, with the HeadersSuffix, e.g., "file.zip.headers"
+func (info FileInfo) SaveHeaders() (err error) {
+	file, err := os.Create(info.Name + HeadersSuffix)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	return info.WriteHeaders(file)
 }
 
 // AdvancedProgressListener registers some callbacks that will be called when
@@ -57,6 +108,9 @@ type FileInfo struct {
 type AdvancedProgressListener struct {
 	// OnStart will be called with the time just before the download starts
 	OnStart func(time time.Time)
+	// OnResponse will be called with each response received, before its body is read, including the
+	// responses redirecting to the next request, e.g., to print the response headers
+	OnResponse func(response *http.Response)
 	// OnStatus will be called with the status code and message received
 	OnStatus func(status string, code int)
 	// OnContentLength will be called with the expected size of the download. This
@@ -65,6 +119,9 @@ type AdvancedProgressListener struct {
 	OnContentLength func(length int64)
 	// OnGetFile will be called with the filename the contents will be downloaded to
 	OnGetFile func(filename string)
+	// OnResume will be called with the number of bytes already downloaded, once the download continues
+	// a partial file from there, see Config.ResumeFrom; the bytes reported to OnProgress include them
+	OnResume func(offset int64)
 	// OnProgress will be called with stats on the size of the content that has been
 	// downloaded against the total content length, alongside the rate of the
 	// download in bytes/second
@@ -79,6 +136,9 @@ func (from *AdvancedProgressListener) init() {
 	if from.OnStart == nil {
 		l.OnStart = func(time time.Time) {}
 	}
+	if from.OnResponse == nil {
+		l.OnResponse = func(response *http.Response) {}
+	}
 	if from.OnStatus == nil {
 		l.OnStatus = func(status string, code int) {}
 	}
@@ -88,6 +148,9 @@ func (from *AdvancedProgressListener) init() {
 	if from.OnGetFile == nil {
 		l.OnGetFile = func(filename string) {}
 	}
+	if from.OnResume == nil {
+		l.OnResume = func(offset int64) {}
+	}
 	if from.OnProgress == nil {
 		l.OnProgress = func(downloaded, total int64, rate int32) {}
 	}
@@ -99,14 +162,58 @@ func (from *AdvancedProgressListener) init() {
 // Config contains configuration options for URL
 type Config struct {
 	// GetFile will be called to return a valid file, with write access, to hold the resource from the
-	// given URL. The function is provided the headers received from the request to
-	// the target url
-	GetFile func(url string, header http.Header) (*os.File, error)
+	// given URL. The function is provided the final URL of the resource, after any redirects, and the
+	// headers received from the request to that url.
+	//
+	// If the returned file is a partial file, see fileio.OpenPart, it is renamed to its complete file only after
+	// the whole resource has been downloaded, flushed to disk, and verified; otherwise, it is removed on failure,
+	// unless KeepPartial is set.
+	//
+	// The returned writer need not be an *os.File, e.g., to stream the resource to stdout, in which case, the
+	// resource is simply written to it: the download may not be resumed, nor verified, and the free disk space
+	// isn't checked. A writer that has a `Name() string` method, is reported by that name
+	GetFile func(url string, header http.Header) (io.WriteCloser, error)
+	// ResumeFrom, if not nil, will be called with the URL to return the number of bytes of the resource
+	// already downloaded, e.g., the size of an existing partial file. If the server honours the range
+	// request, the download continues from that offset of the file returned by GetFile;
+	// otherwise, the file is truncated and the whole resource downloaded anew.
+	//
+	// The headers are empty, as the request is yet to be sent. ResumeFrom is called again with the final URL,
+	// and the headers of its response, as GetFile is, since the partial file may be named after them; the
+	// request is sent again if the number of bytes turns out to differ.
+	//
+	// A partial file holding the whole resource already is saved as is. With no bytes downloaded, the complete
+	// file of the partial file returned by GetFile, if as long as the Content-Length, is kept as is, and the
+	// returned error wraps fileio.ErrFileExists
+	ResumeFrom func(url string, header http.Header) int64
+	// KeepPartial keeps the partial file returned by GetFile when the download fails, so that it may be continued
+	KeepPartial bool
+	// Verify, if not nil, will be called with the fully downloaded file, to check its contents, e.g.,
+	// against a checksum, before the file is saved. A partial file that fails verification is always removed.
+	// Verify requires GetFile to return an *os.File
+	Verify func(file *os.File) error
 	// ShouldDownload will be called to validate whether the file from the given url
 	// should be downloaded, based on the given headers as retrieved from the server
 	ShouldDownload func(url string, header http.Header) bool
 	// Limit the download speed to a maximum of Limit bytes/second. A Limit <= 0 infers no rate limiting
 	Limit int32
+	// Limiter, if not nil, shares its bandwidth between all downloads using it,
+	// such that their total download speed never exceeds the rate of the Limiter.
+	// Limit then overrides the bandwidth available to this download alone
+	Limiter *limitedio.SharedLimiter
+	// MaxFileSize, if > 0, is the maximum size of the file to download, in bytes. Files whose
+	// Content-Length exceeds the MaxFileSize will not be downloaded at all, while files of unknown
+	// length will be aborted, and removed, as soon as the downloaded bytes exceed the MaxFileSize.
+	// In both cases, the returned error wraps xerr.ErrFileTooLarge
+	MaxFileSize int64
+	// MinFreeSpace is the free disk space, in bytes, that must remain on the filesystem holding
+	// the downloaded file, after the download. Before any bytes are written to the file, the
+	// filesystem is checked for enough space for the file (when its Content-Length is known) and the
+	// MinFreeSpace, otherwise, the returned error wraps xerr.ErrInsufficientSpace
+	MinFreeSpace int64
+	// Pacer, if not nil, delays the request until it may be sent to the target host,
+	// and adapts the delay to the response the host sends
+	Pacer *pace.Pacer
 	// ProgressListener will be called every time some buffered read occurs,
 	// depending on the underlying buffer size or the Limit. It reports how much of
 	// the resource has been downloaded, and how much is the expected total.
@@ -126,12 +233,18 @@ type Config struct {
 	// AllowedStatusCodes keeps a list of all the status codes that are allowed for the given request.
 	// Any other status code will be considered an error
 	AllowedStatusCodes []int
+	// SaveHeaders writes the headers of the response, see FileInfo.WriteHeaders, to the file returned by
+	// GetFile, before the resource, as with --save-headers. A continued download has its headers already
+	SaveHeaders bool
 	AdvancedProgressListener
 }
 
 // URL downloads the file from the given url, and saves it to the given file,
-// respecting the given speed limit; i.e., the download speed never exceeds `limit` bytes/second
-func URL(url string, config Config) (info FileInfo, err error) {
+// respecting the given speed limit; i.e., the download speed never exceeds `limit` bytes/second.
+//
+// The download is aborted as soon as the given context is done, in which case, the returned error wraps the
+// context's error, and the partial file, if any, is kept so that the download may be continued later
+func URL(cx context.Context, url string, config Config) (info FileInfo, err error) {
 	{ // sanity checks on the configuration
 		if config.GetFile == nil {
 			err = errors.New("bad config: function `GetFile` is required")
@@ -161,57 +274,194 @@ func URL(url string, config Config) (info FileInfo, err error) {
 		}
 	}()
 
-	req, err := http.NewRequest(config.Method, url, config.Body)
-	if err != nil {
-		return info, fmt.Errorf("failed to create new request: %v", err)
-	}
+	// send sends the request, asking the server to skip the given number of bytes, if any
+	started := false
+	send := func(offset int64) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(cx, config.Method, url, config.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create new request: %v", err)
+		}
+
+		// Set the default client headers, including user agent
+		setClientHeaders(&req.Header)
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
 
-	// Set the default client headers, including user agent
-	setClientHeaders(&req.Header)
+		// Wait for our turn to send a request to the host, then send the request
+		if err = config.Pacer.Wait(cx, req.URL.Host); err != nil {
+			return nil, err
+		}
+		if !started {
+			started = true
+			config.AdvancedProgressListener.OnStart(time.Now())
+		}
+		config.AdvancedProgressListener.OnStatus("", -1)
+		resp, err := redirectListener(config.OnResponse).Do(req)
+		if err != nil {
+			return nil, xerr.WithStatus(requestStatus(err), fmt.Errorf("failed to download file: %w", err))
+		}
+		config.Pacer.Adapt(req.URL.Host, resp.StatusCode, resp.Header)
+		return resp, nil
+	}
 
-	// Send the request
-	config.AdvancedProgressListener.OnStart(time.Now())
-	config.AdvancedProgressListener.OnStatus("", -1)
-	resp, err := client.Do(req)
+	// offset is the number of bytes already downloaded, that the server is asked to skip
+	offset := int64(0)
+	if config.ResumeFrom != nil {
+		offset = config.ResumeFrom(url, http.Header{})
+	}
+	resp, err := send(offset)
 	if err != nil {
-		err = fmt.Errorf("failed to download file: %v", err)
 		return
 	}
+	if config.ResumeFrom != nil && config.Body == nil &&
+		(resp.StatusCode < 300 || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable) {
+		// the partial file may be named after the response, e.g., after its Content-Disposition, ask again
+		if named := config.ResumeFrom(resp.Request.URL.String(), resp.Header); named != offset {
+			fileio.Close(resp.Body)
+			offset = named
+			if resp, err = send(offset); err != nil {
+				return
+			}
+		}
+	}
 	defer fileio.Close(resp.Body)
 
+	config.AdvancedProgressListener.OnResponse(resp)
 	config.AdvancedProgressListener.OnStatus(resp.Status, resp.StatusCode)
 	if config.ShouldDownload != nil && !config.ShouldDownload(url, resp.Header) {
-		err = fmt.Errorf("skipping download of url: %q", url)
+		err = fmt.Errorf("%w of url: %q", ErrSkipped, url)
 		return
 	}
 
-	if config.AllowedStatusCodes != nil && !slices.Contains(config.AllowedStatusCodes, resp.StatusCode) {
-		err = fmt.Errorf("bad status code: %v", resp.Status)
+	// the server may ignore the range request, in which case, the whole resource is downloaded anew
+	resumed := offset > 0 && resp.StatusCode == http.StatusPartialContent
+	// the range starts at the end of a partial file that holds the whole resource already
+	complete := offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable &&
+		httpx.ExtractCompleteLength(resp.Header) == offset
+	continuing := config.ResumeFrom != nil && offset == 0
+	resumed = resumed || complete
+	if !resumed {
+		offset = 0
+	}
+	if !resumed && config.AllowedStatusCodes != nil && !slices.Contains(config.AllowedStatusCodes, resp.StatusCode) {
+		err = xerr.WithStatus(responseStatus(resp.StatusCode), fmt.Errorf("bad status code: %v", resp.Status))
 		return
 	}
 
 	info.Headers = resp.Header
 	info.StatusCode = resp.StatusCode
+	info.Proto = resp.Proto
+	info.Status = resp.Status
 
-	contentLength := httpx.ExtractContentLength(resp.Header)
+	// the length of the content remaining to be downloaded, and the length of the whole file
+	remaining := httpx.ExtractContentLength(resp.Header)
+	if complete {
+		remaining = 0
+	}
+	contentLength := remaining
+	if remaining >= 0 {
+		contentLength += offset
+	}
 	config.AdvancedProgressListener.OnContentLength(contentLength)
+	if config.MaxFileSize > 0 && contentLength > config.MaxFileSize {
+		err = fmt.Errorf(
+			"%w: %s is larger than %s", xerr.ErrFileTooLarge,
+			globals.FormatSize(contentLength), globals.FormatSize(config.MaxFileSize),
+		)
+		return
+	}
 
 	// Create the output file
-	file, err := config.GetFile(url, resp.Header)
+	output, err := config.GetFile(resp.Request.URL.String(), resp.Header)
 	if err != nil {
-		err = fmt.Errorf("failed to get writable file: %v", err)
+		err = xerr.WithStatus(xerr.IOStatus, fmt.Errorf("failed to get writable file: %w", err))
 		return
 	}
-	defer fileio.Close(file)
-	info.Name = file.Name()
-	config.AdvancedProgressListener.OnGetFile(file.Name())
+	// file is nil, unless the output is a file, e.g., rather than stdout
+	file, _ := output.(*os.File)
+	// discard removes the file as it's closed, whatever its kind, e.g., as its contents turn out to be useless
+	discard := false
+	defer func() {
+		fileio.Close(output)
+		if file == nil {
+			return
+		}
+		if discard || (err != nil && !config.KeepPartial && cx.Err() == nil && fileio.IsPart(file.Name())) {
+			// never leave an incomplete file behind, unless it is to be continued, e.g., after an interruption
+			_ = os.Remove(file.Name())
+		}
+	}()
+	if named, ok := output.(interface{ Name() string }); ok {
+		info.Name = strings.TrimSuffix(named.Name(), fileio.PartSuffix)
+	}
+	config.AdvancedProgressListener.OnGetFile(info.Name)
+	if resumed {
+		config.AdvancedProgressListener.OnResume(offset)
+	}
+
+	if continuing && contentLength >= 0 && file != nil && fileio.IsPart(file.Name()) {
+		// the download completed already, e.g., continued with no partial file left to continue
+		if stat, statErr := os.Stat(info.Name); statErr == nil && stat.Mode().IsRegular() && stat.Size() == contentLength {
+			discard = true
+			err = fmt.Errorf("%w: %s is fully retrieved, nothing to do", fileio.ErrFileExists, info.Name)
+			return
+		}
+	}
+
+	if file == nil {
+		if resumed {
+			// the bytes already downloaded aren't available to continue from
+			err = fmt.Errorf("failed to continue download: %q is not a file", info.Name)
+			return
+		}
+	} else {
+		if resumed {
+			// the file may not be the one ResumeFrom measured, e.g., if it was named after the response headers
+			if stat, statErr := file.Stat(); statErr != nil || stat.Size() < offset {
+				err = xerr.WithStatus(
+					xerr.IOStatus,
+					fmt.Errorf("failed to continue download: %q holds fewer than %d bytes", info.Name, offset),
+				)
+				return
+			}
+		}
+		// drop anything in the file past the bytes already downloaded, and continue writing from there
+		if err = file.Truncate(offset); err == nil {
+			_, err = file.Seek(offset, io.SeekStart)
+		}
+		if err != nil {
+			err = xerr.WithStatus(xerr.IOStatus, fmt.Errorf("failed to prepare download file: %v", err))
+			return
+		}
+
+		// ensure the file fits in the filesystem before writing any bytes, rather than finding out halfway
+		err = preflight(file, offset, remaining, config.MinFreeSpace)
+		if err != nil {
+			// no bytes were written yet, unless continuing
+			discard = !resumed
+			return
+		}
+	}
+
+	if config.SaveHeaders && !resumed {
+		if err = info.WriteHeaders(output); err != nil {
+			err = xerr.WithStatus(xerr.IOStatus, fmt.Errorf("failed to write to download file: %v", err))
+			return
+		}
+	}
 
 	// Create a buffer to store the downloaded bytes
 	// Many clients use a default buffer size of 8KiB, we follow that standard
 	buffer := make([]byte, 8*KiB)
 
 	// Use a speed governed reader to limit reads from the response body
-	body := limitedio.NewSGReader(config.Limit, &resp.Body)
+	var body *limitedio.SGReader
+	if config.Limiter != nil {
+		body = config.Limiter.NewReader(config.Limit, &resp.Body)
+	} else {
+		body = limitedio.NewSGReader(config.Limit, &resp.Body)
+	}
 	defer fileio.Close(body)
 	body.SetRateListener(
 		func(rate int32) {
@@ -220,12 +470,18 @@ func URL(url string, config Config) (info FileInfo, err error) {
 		},
 	)
 
-	// keeps track of how many bytes have been downloaded
-	downloadedBytes := int64(0)
+	// keeps track of how many bytes have been downloaded, including those downloaded before resuming
+	downloadedBytes := offset
+
+	// ReadAll bytes from the speed governed response body in chunks of 8KiBs, unless the partial file is
+	// complete already. See io.ReadAll for more details
+	for !complete {
+		if cx.Err() != nil {
+			// interrupted, the bytes written so far are kept in the partial file
+			err = fmt.Errorf("download interrupted: %w", cx.Err())
+			return
+		}
 
-	// ReadAll bytes from the speed governed response body in chunks of 8KiBs.
-	// See io.ReadAll for more details
-	for {
 		var n int
 		// Read a chunk of bytes from the response body
 		n, err = body.Read(buffer)
@@ -234,8 +490,11 @@ func URL(url string, config Config) (info FileInfo, err error) {
 				// Reached the end of the file, shouldn't be reported as an error
 				err = nil
 				break
+			} else if err != io.EOF && cx.Err() != nil {
+				err = fmt.Errorf("download interrupted: %w", cx.Err())
+				return
 			} else if err != io.EOF {
-				err = fmt.Errorf("failed to read response body: %v", err)
+				err = xerr.WithStatus(xerr.NetworkStatus, fmt.Errorf("failed to read response body: %w", err))
 				return
 			}
 			// read some n bytes, before reaching the end of the file,
@@ -243,11 +502,19 @@ func URL(url string, config Config) (info FileInfo, err error) {
 			err = nil
 		}
 		downloadedBytes += int64(n)
+		if config.MaxFileSize > 0 && downloadedBytes > config.MaxFileSize {
+			// the file is of unknown length, and has turned out to be too large, remove what we have so far
+			discard = true
+			err = fmt.Errorf(
+				"%w: downloaded more than %s", xerr.ErrFileTooLarge, globals.FormatSize(config.MaxFileSize),
+			)
+			return
+		}
 
 		// Write the chunk of bytes to the output file
-		_, err = file.Write(buffer[:n])
+		_, err = output.Write(buffer[:n])
 		if err != nil {
-			err = fmt.Errorf("failed to write to download file: %v", err)
+			err = xerr.WithStatus(xerr.IOStatus, fmt.Errorf("failed to write to download file: %v", err))
 			return
 		}
 
@@ -255,9 +522,121 @@ func URL(url string, config Config) (info FileInfo, err error) {
 		config.AdvancedProgressListener.OnProgress(downloadedBytes, contentLength, -1)
 	}
 
+	if config.Verify != nil && file == nil {
+		err = fmt.Errorf("failed to verify download: %q is not a file", info.Name)
+		return
+	}
+	if config.Verify != nil {
+		if err = config.Verify(file); err != nil {
+			// the contents are corrupt, there's no point in continuing this file later
+			discard = true
+			err = fmt.Errorf("failed to verify download file: %w", err)
+			return
+		}
+	}
+
+	switch {
+	case file == nil:
+		// there's nothing to save, the resource was written through to the output
+	case fileio.IsPart(file.Name()):
+		// the download is complete, move it into place
+		info.Name, err = fileio.CommitPart(file)
+		if err != nil {
+			err = xerr.WithStatus(xerr.IOStatus, fmt.Errorf("failed to save download file: %v", err))
+			return
+		}
+	default:
+		if err = file.Sync(); err != nil {
+			err = xerr.WithStatus(xerr.IOStatus, fmt.Errorf("failed to save download file: %v", err))
+			return
+		}
+	}
+
 	return info, nil
 }
 
+// requestStatus returns the exit status of the given failure to send a request, or to receive its response,
+// i.e., the TLSStatus if the TLS handshake failed, or the NetworkStatus, unless the error has a status already
+func requestStatus(err error) int {
+	var statusErr *xerr.StatusError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.Status
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &alertErr),
+		errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return xerr.TLSStatus
+	default:
+		return xerr.NetworkStatus
+	}
+}
+
+// responseStatus returns the exit status of the given, unexpected, response status code, i.e., the AuthStatus if
+// the server asked for authentication, the ServerStatus if it responded with an error, or the ProtocolStatus
+func responseStatus(code int) int {
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusProxyAuthRequired:
+		return xerr.AuthStatus
+	case code >= 400:
+		return xerr.ServerStatus
+	default:
+		return xerr.ProtocolStatus
+	}
+}
+
+// redirectListener returns the client that calls the given listener with each response redirecting to the
+// next request, following up to 10 redirects, as the default client does
+func redirectListener(onResponse func(response *http.Response)) *http.Client {
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.Response != nil {
+			onResponse(req.Response)
+		}
+		if len(via) >= 10 {
+			return xerr.WithStatus(xerr.ProtocolStatus, errors.New("stopped after 10 redirects"))
+		}
+		return nil
+	}
+	return &c
+}
+
+// preflight checks that the filesystem holding the given file, of `offset` bytes, has enough free space for `length`
+// bytes more, and the `margin`; then preallocates the space for the whole file. A `length` < 0 means the size of
+// the file is unknown
+func preflight(file *os.File, offset, length, margin int64) error {
+	if length <= 0 && margin <= 0 {
+		return nil
+	}
+
+	free, err := fileio.FreeSpace(file.Name())
+	if err != nil {
+		// can't tell how much space is available, go ahead and try to write the file anyway
+		logfile.Debug.Printf("failed to check free disk space for %q: %v\n", file.Name(), err)
+		return nil
+	}
+
+	if needed := max(length, 0) + margin; free < needed {
+		return fmt.Errorf(
+			"%w: %s needed to save %q, %s available", xerr.ErrInsufficientSpace,
+			globals.FormatSize(needed), file.Name(), globals.FormatSize(free),
+		)
+	}
+
+	if length <= 0 {
+		return nil
+	}
+	// the space is allocated from the start of the file, the bytes already written take none more
+	if err = fileio.Preallocate(file, offset+length); err != nil {
+		logfile.Debug.Printf("failed to preallocate %q: %v\n", file.Name(), err)
+	}
+	return nil
+}
+
 // DownloadStatus holds printable download status of a Get request at any one instance during the download
 type DownloadStatus struct {
 	StatusCode    int
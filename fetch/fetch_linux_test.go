@@ -0,0 +1,39 @@
+package fetch
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestPreflight_Resumed(t *testing.T) {
+	const MiB = 1 << 20
+	file, err := os.Create(filepath.Join(t.TempDir(), "file.bin.part"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	// a previous download was interrupted after 1MiB, 2MiB remain
+	if _, err = file.Write(make([]byte, MiB)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = preflight(file, MiB, 2*MiB, 0); err != nil {
+		t.Fatalf("preflight() error = %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != MiB {
+		t.Errorf("expected the file size to remain 1MiB, got %d", info.Size())
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Blocks*512 == MiB {
+		t.Skip("preallocation not supported")
+	}
+	if stat.Blocks*512 < 3*MiB {
+		t.Errorf("expected the whole 3MiB of the file allocated, got %d bytes", stat.Blocks*512)
+	}
+}
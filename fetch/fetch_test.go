@@ -1,6 +1,7 @@
 package fetch
 
 import (
+	"context"
 	"crypto/sha256"
 	"errors"
 	"fmt"
@@ -9,8 +10,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"wget/fileio"
+	"wget/xerr"
 )
 
 var randomFileHash = "Random File Hash"
@@ -77,7 +84,7 @@ func TestFetch(t *testing.T) {
 		),
 	)
 
-	GetFile := func(url string, headers http.Header) (*os.File, error) {
+	GetFile := func(url string, headers http.Header) (io.WriteCloser, error) {
 		file, err := createTempReadWriteFile()
 		if err != nil {
 			return nil, err
@@ -85,11 +92,11 @@ func TestFetch(t *testing.T) {
 		return file, nil
 	}
 
-	ErroneousGetFile := func(url string, headers http.Header) (*os.File, error) {
+	ErroneousGetFile := func(url string, headers http.Header) (io.WriteCloser, error) {
 		return nil, errors.New("ErroneousGetFile: won't create a file")
 	}
 
-	GetFile2 := func(url string, header http.Header) (*os.File, error) {
+	GetFile2 := func(url string, header http.Header) (io.WriteCloser, error) {
 		file, err := createTempReadWriteFile()
 		if err != nil {
 			return nil, err
@@ -98,7 +105,7 @@ func TestFetch(t *testing.T) {
 
 	}
 
-	ClosingGetFile := func(url string, header http.Header) (*os.File, error) {
+	ClosingGetFile := func(url string, header http.Header) (io.WriteCloser, error) {
 		file, err := createTempReadWriteFile()
 		if err != nil {
 			return nil, err
@@ -449,7 +456,7 @@ func TestFetch(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				gotInfo, err := URL(tt.args.url, tt.args.config)
+				gotInfo, err := URL(context.Background(), tt.args.url, tt.args.config)
 				if (err != nil) != tt.wantErr {
 					t.Errorf("URL() error = %v, wantErr %v", err, tt.wantErr)
 					return
@@ -512,3 +519,504 @@ func calculateFileHash(filePath string) (string, error) {
 
 	return fmt.Sprintf("%x", sha256.Sum256(fileData)), nil
 }
+
+func TestURL_MaxFileSize(t *testing.T) {
+	// serves 1000 bytes, with or without the Content-Length header
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Has("stream") {
+					w.Header().Set("Transfer-Encoding", "chunked")
+				} else {
+					w.Header().Set("Content-Length", "1000")
+				}
+				_, _ = w.Write([]byte(strings.Repeat("a", 1000)))
+			},
+		),
+	)
+	defer server.Close()
+
+	var name string
+	GetFile := func(url string, header http.Header) (io.WriteCloser, error) {
+		file, err := createTempReadWriteFile()
+		if err == nil {
+			name = file.Name()
+		}
+		return file, err
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		maxFileSize int64
+		wantErr     error
+		wantFile    bool
+	}{
+		{"No maximum", server.URL, 0, nil, true},
+		{"Within the maximum", server.URL, 1000, nil, true},
+		{"Content-Length exceeds the maximum", server.URL, 999, xerr.ErrFileTooLarge, false},
+		{"Stream within the maximum", server.URL + "?stream", 1000, nil, true},
+		{"Stream exceeds the maximum", server.URL + "?stream", 999, xerr.ErrFileTooLarge, false},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				name = ""
+				_, err := URL(context.Background(), tt.url, Config{GetFile: GetFile, MaxFileSize: tt.maxFileSize})
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("URL() error = %v, want %v", err, tt.wantErr)
+				}
+
+				_, statErr := os.Stat(name)
+				if exists := name != "" && statErr == nil; exists != tt.wantFile {
+					t.Errorf("expected the downloaded file to exist: %v, got %v", tt.wantFile, exists)
+				}
+				if name != "" {
+					_ = os.Remove(name)
+				}
+			},
+		)
+	}
+}
+
+func TestURL_MinFreeSpace(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "1000")
+				_, _ = w.Write([]byte(strings.Repeat("a", 1000)))
+			},
+		),
+	)
+	defer server.Close()
+
+	var name string
+	GetFile := func(url string, header http.Header) (io.WriteCloser, error) {
+		file, err := createTempReadWriteFile()
+		if err == nil {
+			name = file.Name()
+		}
+		return file, err
+	}
+
+	// no filesystem has an exabyte of free space, yet
+	_, err := URL(context.Background(), server.URL, Config{GetFile: GetFile, MinFreeSpace: 1 << 60})
+	if !errors.Is(err, xerr.ErrInsufficientSpace) {
+		t.Fatalf("URL() error = %v, want %v", err, xerr.ErrInsufficientSpace)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		_ = os.Remove(name)
+		t.Errorf("expected the empty file %q to be removed", name)
+	}
+
+	_, err = URL(context.Background(), server.URL, Config{GetFile: GetFile, MinFreeSpace: 1})
+	if err != nil {
+		t.Fatalf("URL() error = %v", err)
+	}
+	_ = os.Remove(name)
+}
+
+func TestURL_PartFile(t *testing.T) {
+	content := strings.Repeat("a", 1000)
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "1000")
+				if r.URL.Path == "/broken" {
+					// the connection breaks halfway through the body
+					_, _ = w.Write([]byte(content[:500]))
+					return
+				}
+				_, _ = w.Write([]byte(content))
+			},
+		),
+	)
+	defer server.Close()
+
+	name := filepath.Join(t.TempDir(), "file.txt")
+	GetFile := func(url string, header http.Header) (io.WriteCloser, error) {
+		return fileio.OpenPart(name)
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(name)
+		return err == nil
+	}
+
+	info, err := URL(context.Background(), server.URL, Config{GetFile: GetFile})
+	if err != nil {
+		t.Fatalf("URL() error = %v", err)
+	}
+	if info.Name != name || !exists(name) || exists(fileio.PartName(name)) {
+		t.Errorf("expected the part file to be renamed to %q, got %q", name, info.Name)
+	}
+	_ = os.Remove(name)
+
+	_, err = URL(context.Background(), server.URL+"/broken", Config{GetFile: GetFile})
+	if err == nil {
+		t.Fatalf("expected the broken download to fail")
+	}
+	if exists(name) || exists(fileio.PartName(name)) {
+		t.Errorf("expected the failed download to leave no files behind")
+	}
+
+	_, err = URL(context.Background(), server.URL+"/broken", Config{GetFile: GetFile, KeepPartial: true})
+	if err == nil {
+		t.Fatalf("expected the broken download to fail")
+	}
+	if exists(name) || fileio.PartSize(name) != 500 {
+		t.Errorf("expected the failed download to keep a part file of 500 bytes, got %d", fileio.PartSize(name))
+	}
+
+	errCorrupt := errors.New("checksum mismatch")
+	_, err = URL(
+		context.Background(), server.URL, Config{
+			GetFile:     GetFile,
+			KeepPartial: true,
+			Verify: func(file *os.File) error {
+				return errCorrupt
+			},
+		},
+	)
+	if !errors.Is(err, errCorrupt) {
+		t.Fatalf("URL() error = %v, want %v", err, errCorrupt)
+	}
+	if exists(name) || exists(fileio.PartName(name)) {
+		t.Errorf("expected the unverified download to leave no files behind")
+	}
+}
+
+func TestURL_ResumeFrom(t *testing.T) {
+	content := strings.Repeat("Hello", 200)
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/no-ranges" {
+					r.Header.Del("Range")
+				}
+				http.ServeContent(w, r, "file.txt", time.Time{}, strings.NewReader(content))
+			},
+		),
+	)
+	defer server.Close()
+
+	name := filepath.Join(t.TempDir(), "file.txt")
+	GetFile := func(url string, header http.Header) (io.WriteCloser, error) {
+		return fileio.OpenPart(name)
+	}
+	ResumeFrom := func(url string, header http.Header) int64 {
+		return fileio.PartSize(name)
+	}
+
+	for _, path := range []string{"/", "/no-ranges"} {
+		// a previous download was interrupted after 300 bytes
+		if err := os.WriteFile(fileio.PartName(name), []byte(content[:300]), 0664); err != nil {
+			t.Fatal(err)
+		}
+
+		var total, resumed int64
+		_, err := URL(
+			context.Background(), server.URL+path, Config{
+				GetFile:    GetFile,
+				ResumeFrom: ResumeFrom,
+				ProgressListener: func(downloaded, length int64) {
+					total = length
+				},
+				AdvancedProgressListener: AdvancedProgressListener{
+					OnResume: func(offset int64) {
+						resumed = offset
+					},
+				},
+			},
+		)
+		if err != nil {
+			t.Fatalf("URL(context.Background(), %q) error = %v", path, err)
+		}
+		// the server that ignores the range sends the whole content anew
+		if want := map[string]int64{"/": 300, "/no-ranges": 0}[path]; resumed != want {
+			t.Errorf("URL(context.Background(), %q) reported resuming from %d, want %d", path, resumed, want)
+		}
+		if total != int64(len(content)) {
+			t.Errorf("URL(context.Background(), %q) reported a total length of %d, want %d", path, total, len(content))
+		}
+		if got, err := os.ReadFile(name); err != nil || string(got) != content {
+			t.Errorf("URL(context.Background(), %q) saved %d bytes, want the %d bytes of the content; %v", path, len(got), len(content), err)
+		}
+	}
+
+	// the previous download completed, but its partial file wasn't saved: the server can't satisfy the range
+	if err := os.WriteFile(fileio.PartName(name), []byte(content), 0664); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := URL(context.Background(), server.URL, Config{GetFile: GetFile, ResumeFrom: ResumeFrom}); err != nil {
+		t.Fatalf("URL() of a complete partial file error = %v", err)
+	}
+	if got, err := os.ReadFile(name); err != nil || string(got) != content || fileio.PartSize(name) != 0 {
+		t.Errorf("expected the complete partial file to be saved, got %d bytes; %v", len(got), err)
+	}
+
+	// the previous download was saved, with no partial file left: the file is kept as is
+	modified := strings.ToUpper(content)
+	if err := os.WriteFile(name, []byte(modified), 0664); err != nil {
+		t.Fatal(err)
+	}
+	_, err := URL(context.Background(), server.URL, Config{GetFile: GetFile, ResumeFrom: ResumeFrom})
+	if !errors.Is(err, fileio.ErrFileExists) {
+		t.Fatalf("URL() of a complete file error = %v, want %v", err, fileio.ErrFileExists)
+	}
+	if got, err := os.ReadFile(name); err != nil || string(got) != modified {
+		t.Errorf("expected the complete file to be kept as is, got %d bytes; %v", len(got), err)
+	}
+	if _, err := os.Stat(fileio.PartName(name)); !os.IsNotExist(err) {
+		t.Errorf("expected no partial file to be left behind, got %v", err)
+	}
+
+	// a file of another length is downloaded anew
+	if err := os.WriteFile(name, []byte(content[:300]), 0664); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := URL(context.Background(), server.URL, Config{GetFile: GetFile, ResumeFrom: ResumeFrom}); err != nil {
+		t.Fatalf("URL() error = %v", err)
+	}
+	if got, err := os.ReadFile(name); err != nil || string(got) != content {
+		t.Errorf("expected the file to be downloaded anew, got %d bytes; %v", len(got), err)
+	}
+}
+
+func TestURL_Canceled(t *testing.T) {
+	cx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "1000")
+				_, _ = w.Write([]byte(strings.Repeat("a", 500)))
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			},
+		),
+	)
+	defer server.Close()
+
+	name := filepath.Join(t.TempDir(), "file.txt")
+	GetFile := func(url string, header http.Header) (io.WriteCloser, error) {
+		return fileio.OpenPart(name)
+	}
+
+	_, err := URL(
+		cx, server.URL, Config{
+			GetFile: GetFile,
+			ProgressListener: func(downloaded, total int64) {
+				if downloaded == 500 {
+					// the download is interrupted halfway through the body
+					cancel()
+				}
+			},
+		},
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("URL() error = %v, want %v", err, context.Canceled)
+	}
+	// the part file is kept, to continue the download later
+	if size := fileio.PartSize(name); size != 500 {
+		t.Errorf("expected a part file of 500 bytes to be kept, got %d", size)
+	}
+}
+
+func TestURL_GetFileFinalURL(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/latest" {
+					http.Redirect(w, r, "/releases/v1.2.tar.gz", http.StatusFound)
+					return
+				}
+				_, _ = w.Write([]byte("release"))
+			},
+		),
+	)
+	defer server.Close()
+
+	var got string
+	GetFile := func(url string, header http.Header) (io.WriteCloser, error) {
+		got = url
+		return createTempReadWriteFile()
+	}
+	info, err := URL(context.Background(), server.URL+"/latest", Config{GetFile: GetFile})
+	if err != nil {
+		t.Fatalf("URL() error = %v", err)
+	}
+	defer os.Remove(info.Name)
+
+	if want := server.URL + "/releases/v1.2.tar.gz"; got != want {
+		t.Errorf("GetFile() was called with %q, want the final URL %q", got, want)
+	}
+}
+
+// nopWriteCloser is a named writer that isn't a file, e.g., stdout
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func (nopWriteCloser) Name() string { return "STDOUT" }
+
+func TestURL_Writer(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("streamed"))
+			},
+		),
+	)
+	defer server.Close()
+
+	var out strings.Builder
+	GetFile := func(url string, header http.Header) (io.WriteCloser, error) {
+		return nopWriteCloser{&out}, nil
+	}
+	info, err := URL(context.Background(), server.URL, Config{GetFile: GetFile, MinFreeSpace: 1 << 60})
+	if err != nil {
+		t.Fatalf("URL() error = %v", err)
+	}
+	if out.String() != "streamed" {
+		t.Errorf("expected the contents to be written to the writer, got %q", out.String())
+	}
+	if info.Name != "STDOUT" {
+		t.Errorf("expected the download to be named after the writer, got %q", info.Name)
+	}
+
+	// the contents of a writer can't be verified
+	verify := func(*os.File) error { return nil }
+	if _, err = URL(context.Background(), server.URL, Config{GetFile: GetFile, Verify: verify}); err == nil {
+		t.Errorf("expected verifying a writer, that isn't a file, to fail")
+	}
+}
+
+func TestURL_OnResponse(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/latest":
+					http.Redirect(w, r, "/stable", http.StatusMovedPermanently)
+				case "/stable":
+					http.Redirect(w, r, "/releases/v1.2.tar.gz", http.StatusFound)
+				default:
+					w.Header().Set("X-Cache", "HIT")
+					_, _ = w.Write([]byte("release"))
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	var got []string
+	config := Config{GetFile: func(string, http.Header) (io.WriteCloser, error) { return nopWriteCloser{io.Discard}, nil }}
+	config.OnResponse = func(response *http.Response) {
+		got = append(got, fmt.Sprintf("%s %s %s", response.Request.URL.Path, response.Status, response.Header.Get("X-Cache")))
+	}
+	if _, err := URL(context.Background(), server.URL+"/latest", config); err != nil {
+		t.Fatalf("URL() error = %v", err)
+	}
+
+	want := []string{"/latest 301 Moved Permanently ", "/stable 302 Found ", "/releases/v1.2.tar.gz 200 OK HIT"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected every response, including the redirects, got %q, want %q", got, want)
+	}
+}
+
+func TestURL_SaveHeaders(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				_, _ = w.Write([]byte("body"))
+			},
+		),
+	)
+	defer server.Close()
+
+	var out strings.Builder
+	GetFile := func(url string, header http.Header) (io.WriteCloser, error) {
+		return nopWriteCloser{&out}, nil
+	}
+	info, err := URL(context.Background(), server.URL, Config{GetFile: GetFile, SaveHeaders: true})
+	if err != nil {
+		t.Fatalf("URL() error = %v", err)
+	}
+	if info.Proto != "HTTP/1.1" || info.Status != "200 OK" {
+		t.Errorf("unexpected status line %q %q", info.Proto, info.Status)
+	}
+	got := out.String()
+	if !strings.HasPrefix(got, "HTTP/1.1 200 OK\r\n") || !strings.Contains(got, "Content-Type: text/plain\r\n") {
+		t.Errorf("expected the headers before the contents, got %q", got)
+	}
+	if !strings.HasSuffix(got, "\r\n\r\nbody") {
+		t.Errorf("expected the headers to be followed by an empty line, and the contents, got %q", got)
+	}
+}
+
+func TestFileInfo_SaveHeaders(t *testing.T) {
+	info := FileInfo{
+		Name:    filepath.Join(t.TempDir(), "file.zip"),
+		Headers: http.Header{"Content-Length": {"4"}, "Etag": {`"abc"`}},
+		Proto:   "HTTP/1.1",
+		Status:  "200 OK",
+	}
+	if err := info.SaveHeaders(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(info.Name + HeadersSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "HTTP/1.1 200 OK\r\nContent-Length: 4\r\nEtag: \"abc\"\r\n\r\n"; string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+}
+
+func TestURL_ExitStatus(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/private":
+					w.WriteHeader(http.StatusUnauthorized)
+				case "/missing":
+					w.WriteHeader(http.StatusNotFound)
+				case "/loop":
+					http.Redirect(w, r, "/loop", http.StatusFound)
+				default:
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			},
+		),
+	)
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{server.URL + "/private", xerr.AuthStatus},
+		{server.URL + "/missing", xerr.ServerStatus},
+		{server.URL + "/unavailable", xerr.ServerStatus},
+		{server.URL + "/loop", xerr.ProtocolStatus},
+		// the certificate of the test server isn't trusted
+		{tlsServer.URL, xerr.TLSStatus},
+		{closed.URL, xerr.NetworkStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			GetFile := func(string, http.Header) (io.WriteCloser, error) { return nopWriteCloser{io.Discard}, nil }
+			_, err := URL(context.Background(), tt.url, Config{GetFile: GetFile, AllowedStatusCodes: []int{http.StatusOK}})
+			if got := xerr.ExitStatus(err); got != tt.want {
+				t.Errorf("ExitStatus(%v) = %d, want %d", err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package fileio
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Clobber defines what happens to an existing file, when a download is to be saved under its name
+type Clobber int
+
+const (
+	// Overwrite replaces the existing file with the download
+	Overwrite Clobber = iota
+	// NoClobber keeps the existing file, and doesn't save the download
+	NoClobber
+	// Backup renames the existing file to file.1, rotating older backups to file.2, file.3, and so on
+	Backup
+	// UniqueNames keeps the existing file, and saves the download under a new name, e.g., file(1).txt
+	UniqueNames
+)
+
+// clobbers maps the names of the commandline options to the Clobber they select
+var clobbers = map[string]Clobber{
+	"overwrite":    Overwrite,
+	"no-clobber":   NoClobber,
+	"backups":      Backup,
+	"unique-names": UniqueNames,
+}
+
+// ErrFileExists is returned when a download is not saved, as it would clobber an existing file
+var ErrFileExists = errors.New("file already exists")
+
+// Policy decides the name a download is saved under, when a file of the same name already exists
+type Policy struct {
+	Clobber Clobber
+	// Backups is the maximum number of backups of a file kept by the Backup clobber
+	Backups int
+}
+
+// NewPolicy creates the Policy selected by the named commandline option, one of
+// "overwrite", "no-clobber", "backups" or "unique-names"; keeping up to `backups` backups of each
+// file for the latter. Any other name selects the given fallback Clobber
+func NewPolicy(name string, backups int, fallback Clobber) Policy {
+	clobber, ok := clobbers[name]
+	if !ok {
+		clobber = fallback
+	}
+	if clobber == Backup && backups <= 0 {
+		// there's nowhere to keep the existing file
+		clobber = Overwrite
+	}
+	return Policy{Clobber: clobber, Backups: backups}
+}
+
+// Resolve returns the name to save a download of the named file under, making room
+// for the download, by the policy, if the file exists. Returns an error wrapping
+// ErrFileExists if the file exists and should not be clobbered
+func (p Policy) Resolve(name string) (string, error) {
+	if _, err := os.Stat(name); err != nil {
+		// nothing to clobber
+		return name, nil
+	}
+
+	switch p.Clobber {
+	case NoClobber:
+		return "", fmt.Errorf("%w: %s", ErrFileExists, name)
+	case Backup:
+		return name, rotate(name, p.Backups)
+	case UniqueNames:
+		return UniqueName(name), nil
+	default:
+		return name, nil
+	}
+}
+
+// rotate renames the named file to name.1, after renaming each of its existing
+// backups, name.1 to name.2, and so on, dropping the oldest of `n` backups
+func rotate(name string, n int) error {
+	for i := n - 1; i > 0; i-- {
+		from := fmt.Sprintf("%s.%d", name, i)
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", name, i+1)); err != nil {
+			return err
+		}
+	}
+	return os.Rename(name, name+".1")
+}
+
+// UniqueName returns the given filename if no such file exists, otherwise, it
+// adds the smallest number, starting from 1, between the base of the filename and
+// its extension, such that no such file exists, e.g., file(1).txt for file.txt
+func UniqueName(filename string) string {
+	if strings.TrimSpace(filename) == "" {
+		return ""
+	}
+	original := filename
+	for n := 1; ; n++ {
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return filename
+		}
+		filename = NumberedName(original, n)
+	}
+}
+
+// NumberedName returns the given filename, numbered with n before its extension, e.g., file(1).txt
+func NumberedName(filename string, n int) string {
+	extension := filepath.Ext(filename)
+	return fmt.Sprintf("%s(%d)%s", strings.TrimSuffix(filename, extension), n, extension)
+}
@@ -0,0 +1,121 @@
+package fileio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		backups  int
+		fallback Clobber
+		want     Policy
+	}{
+		{"", 0, UniqueNames, Policy{Clobber: UniqueNames}},
+		{"", 0, Overwrite, Policy{Clobber: Overwrite}},
+		{"no-clobber", 0, UniqueNames, Policy{Clobber: NoClobber}},
+		{"overwrite", 0, UniqueNames, Policy{Clobber: Overwrite}},
+		{"unique-names", 0, Overwrite, Policy{Clobber: UniqueNames}},
+		{"backups", 3, Overwrite, Policy{Clobber: Backup, Backups: 3}},
+		{"backups", 0, UniqueNames, Policy{Clobber: Overwrite}},
+	}
+	for _, tt := range tests {
+		if got := NewPolicy(tt.name, tt.backups, tt.fallback); got != tt.want {
+			t.Errorf("NewPolicy(%q, %d, %v) = %v, want %v", tt.name, tt.backups, tt.fallback, got, tt.want)
+		}
+	}
+}
+
+func TestNumberedName(t *testing.T) {
+	tests := []struct {
+		filename string
+		n        int
+		want     string
+	}{
+		{"file.txt", 1, "file(1).txt"},
+		{"file", 2, "file(2)"},
+		{"dir/archive.tar.gz", 1, "dir/archive.tar(1).gz"},
+	}
+	for _, tt := range tests {
+		if got := NumberedName(tt.filename, tt.n); got != tt.want {
+			t.Errorf("NumberedName(%q, %d) = %q, want %q", tt.filename, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy_Resolve(t *testing.T) {
+	// setup creates a directory holding the file "file.txt", with the given contents
+	setup := func(t *testing.T, contents string) string {
+		name := filepath.Join(t.TempDir(), "file.txt")
+		if err := os.WriteFile(name, []byte(contents), 0664); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+	read := func(name string) string {
+		contents, _ := os.ReadFile(name)
+		return string(contents)
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "file.txt")
+		for _, clobber := range []Clobber{Overwrite, NoClobber, Backup, UniqueNames} {
+			got, err := Policy{Clobber: clobber, Backups: 1}.Resolve(name)
+			if got != name || err != nil {
+				t.Errorf("Resolve() with %v = %q, %v, want %q", clobber, got, err, name)
+			}
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		name := setup(t, "old")
+		got, err := Policy{Clobber: Overwrite}.Resolve(name)
+		if got != name || err != nil {
+			t.Errorf("Resolve() = %q, %v, want %q", got, err, name)
+		}
+	})
+
+	t.Run("no-clobber", func(t *testing.T) {
+		name := setup(t, "old")
+		_, err := Policy{Clobber: NoClobber}.Resolve(name)
+		if !errors.Is(err, ErrFileExists) {
+			t.Errorf("Resolve() error = %v, want %v", err, ErrFileExists)
+		}
+		if read(name) != "old" {
+			t.Errorf("expected the existing file to be kept")
+		}
+	})
+
+	t.Run("backups", func(t *testing.T) {
+		name := setup(t, "v1")
+		policy := Policy{Clobber: Backup, Backups: 2}
+		for _, version := range []string{"v2", "v3", "v4"} {
+			got, err := policy.Resolve(name)
+			if got != name || err != nil {
+				t.Fatalf("Resolve() = %q, %v, want %q", got, err, name)
+			}
+			if err = os.WriteFile(name, []byte(version), 0664); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// only the 2 latest backups are kept
+		if read(name) != "v4" || read(name+".1") != "v3" || read(name+".2") != "v2" {
+			t.Errorf("expected v4, v3, v2, got %q, %q, %q", read(name), read(name+".1"), read(name+".2"))
+		}
+		if _, err := os.Stat(name + ".3"); !os.IsNotExist(err) {
+			t.Errorf("expected no more than 2 backups")
+		}
+	})
+
+	t.Run("unique-names", func(t *testing.T) {
+		name := setup(t, "old")
+		got, err := Policy{Clobber: UniqueNames}.Resolve(name)
+		want := filepath.Join(filepath.Dir(name), "file(1).txt")
+		if got != want || err != nil {
+			t.Errorf("Resolve() = %q, %v, want %q", got, err, want)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package fileio
+
+import (
+	"os"
+	"strings"
+)
+
+// PartSuffix is appended to the name of a file while it is being downloaded, so
+// that an interrupted download is never mistaken for a complete file
+const PartSuffix = ".part"
+
+// PartName returns the name of the partial file that holds the contents of the named file during its download
+func PartName(name string) string {
+	return name + PartSuffix
+}
+
+// IsPart reports whether the named file is a partial file, see PartName
+func IsPart(name string) bool {
+	return strings.HasSuffix(name, PartSuffix)
+}
+
+// OpenPart opens, or creates, the partial file of the named file for reading and writing.
+// The partial file is not truncated, so that an interrupted download may be continued
+func OpenPart(name string) (*os.File, error) {
+	return os.OpenFile(PartName(name), os.O_RDWR|os.O_CREATE, 0664)
+}
+
+// PartSize returns the size of the existing partial file of the named file,
+// or 0, if there is no such partial file
+func PartSize(name string) int64 {
+	info, err := os.Stat(PartName(name))
+	if err != nil || !info.Mode().IsRegular() {
+		return 0
+	}
+	return info.Size()
+}
+
+// CommitPart flushes the given partial file to disk, closes it, and renames it
+// to the complete file it stands for, replacing any existing file of the same name.
+// Returns the name of the complete file
+func CommitPart(file *os.File) (string, error) {
+	name := strings.TrimSuffix(file.Name(), PartSuffix)
+	if err := file.Sync(); err != nil {
+		Close(file)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	return name, os.Rename(file.Name(), name)
+}
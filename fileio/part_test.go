@@ -0,0 +1,52 @@
+package fileio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPart(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "file.txt")
+	if size := PartSize(name); size != 0 {
+		t.Errorf("PartSize() of a missing part = %d, want 0", size)
+	}
+
+	file, err := OpenPart(name)
+	if err != nil {
+		t.Fatalf("OpenPart() error = %v", err)
+	}
+	if !IsPart(file.Name()) || file.Name() != PartName(name) {
+		t.Errorf("OpenPart() opened %q, want %q", file.Name(), PartName(name))
+	}
+	if _, err = file.WriteString("Hello"); err != nil {
+		t.Fatal(err)
+	}
+	if size := PartSize(name); size != 5 {
+		t.Errorf("PartSize() = %d, want 5", size)
+	}
+
+	// reopening the part keeps its contents
+	Close(file)
+	file, err = OpenPart(name)
+	if err != nil {
+		t.Fatalf("OpenPart() error = %v", err)
+	}
+	if size := PartSize(name); size != 5 {
+		t.Errorf("PartSize() after reopening = %d, want 5", size)
+	}
+
+	committed, err := CommitPart(file)
+	if err != nil {
+		t.Fatalf("CommitPart() error = %v", err)
+	}
+	if committed != name {
+		t.Errorf("CommitPart() = %q, want %q", committed, name)
+	}
+	if contents, err := os.ReadFile(name); err != nil || string(contents) != "Hello" {
+		t.Errorf("expected the committed file to hold %q, got %q, %v", "Hello", contents, err)
+	}
+	if _, err := os.Stat(PartName(name)); !os.IsNotExist(err) {
+		t.Errorf("expected the part file to be renamed, got %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package fileio
+
+import (
+	"os"
+	"syscall"
+)
+
+// fallocKeepSize is the FALLOC_FL_KEEP_SIZE mode of fallocate(2), that allocates
+// disk space without changing the reported size of the file
+const fallocKeepSize = 0x01
+
+// Preallocate allocates disk space for the first `size` bytes of the given file, to
+// reduce fragmentation as the file is written, without changing the size of the
+// file. Filesystems that do not support preallocation return an error, which
+// is safe to ignore
+func Preallocate(file *os.File, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	return syscall.Fallocate(int(file.Fd()), fallocKeepSize, 0, size)
+}
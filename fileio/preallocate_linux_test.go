@@ -0,0 +1,33 @@
+package fileio
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestPreallocate(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "file.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(file)
+
+	err = Preallocate(file, 1<<20)
+	if err != nil {
+		t.Skipf("preallocation not supported: %v", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the size of the file doesn't change, but the disk space is allocated
+	if info.Size() != 0 {
+		t.Errorf("expected the file size to remain 0, got %d", info.Size())
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Blocks*512 < 1<<20 {
+		t.Errorf("expected at least 1MiB allocated, got %d bytes", stat.Blocks*512)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package fileio
+
+import (
+	"errors"
+	"os"
+)
+
+// Preallocate allocates disk space for the first `size` bytes of the given file, to
+// reduce fragmentation as the file is written, without changing the size of the
+// file. Preallocation is only supported on Linux, elsewhere an error is
+// returned, which is safe to ignore
+func Preallocate(file *os.File, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	return errors.ErrUnsupported
+}
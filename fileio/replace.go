@@ -0,0 +1,26 @@
+package fileio
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Replace writes the given data to the named file, replacing it at once, such that the file is never read
+// half written, e.g., by another process, nor lost, if the program stops while writing it
+func Replace(name string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), name)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+	return err
+}
@@ -0,0 +1,27 @@
+package fileio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "state.json")
+	for _, data := range []string{"first", "second"} {
+		if err := Replace(name, []byte(data)); err != nil {
+			t.Fatalf("Replace() error = %v", err)
+		}
+		if got, _ := os.ReadFile(name); string(got) != data {
+			t.Errorf("Replace() wrote %q, want %q", got, data)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected no temporary file to be left, got %v", entries)
+	}
+
+	if err := Replace(filepath.Join(dir, "missing", "state.json"), nil); err == nil {
+		t.Error("Replace() error = nil, want the missing directory")
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || freebsd || dragonfly)
+
+package fileio
+
+import "errors"
+
+// FreeSpace returns the number of bytes available to unprivileged users, in the
+// filesystem holding the given path. It's only supported on Linux, macOS, FreeBSD and
+// DragonFly BSD, elsewhere an error is returned, and the free space goes unchecked
+func FreeSpace(path string) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
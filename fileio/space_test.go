@@ -0,0 +1,26 @@
+package fileio
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+	free, err := FreeSpace(dir)
+	if err != nil {
+		t.Fatalf("FreeSpace(%q) error = %v", dir, err)
+	}
+	if free <= 0 {
+		t.Errorf("expected some free space in %q, got %d", dir, free)
+	}
+
+	// a path that doesn't exist yet reports the free space of its parent's filesystem
+	missing, err := FreeSpace(filepath.Join(dir, "a", "b", "file.txt"))
+	if err != nil {
+		t.Fatalf("FreeSpace() of a missing path error = %v", err)
+	}
+	if missing <= 0 {
+		t.Errorf("expected some free space for a missing path, got %d", missing)
+	}
+}
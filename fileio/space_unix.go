@@ -0,0 +1,29 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package fileio
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// FreeSpace returns the number of bytes available to unprivileged users, in the
+// filesystem holding the given path. If the path doesn't exist yet, the free
+// space of the filesystem holding its closest existing parent directory is returned
+func FreeSpace(path string) (int64, error) {
+	path = filepath.Clean(path)
+	for {
+		var stat syscall.Statfs_t
+		err := syscall.Statfs(path, &stat)
+		if err == nil {
+			return int64(stat.Bavail) * int64(stat.Bsize), nil
+		}
+
+		parent := filepath.Dir(path)
+		if !os.IsNotExist(err) || parent == path {
+			return 0, err
+		}
+		path = parent
+	}
+}
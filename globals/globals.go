@@ -103,9 +103,9 @@ func PrintLines(baseRow int, lines []string) {
 	defer printLinesMutex.Unlock()
 	for i, line := range lines {
 		i++
-		syscheck.MoveCursor(baseRow + i) // move to the correct line
-		fmt.Print("\033[K")              // clear the line
-		fmt.Print(line)
+		syscheck.MoveCursor(baseRow + i)               // move to the correct line
+		_, _ = fmt.Fprint(syscheck.Output(), "\033[K") // clear the line
+		_, _ = fmt.Fprint(syscheck.Output(), line)
 	}
 }
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand"
 	"strings"
+	"unicode/utf8"
 	"wget/info"
 )
 
@@ -16,34 +17,34 @@ Usage: wget [OPTION] [URL]
 Try 'wget --help' for more options.`
 )
 
-// PrintManPage prints the program's help text
-func PrintManPage() string {
+// Option is a row of the options table of the help text: the Names of an option, e.g. `-O | --output-document=FILE`,
+// and its Help; the Help is wrapped to the width of the table, and may be broken into paragraphs by newlines
+type Option struct {
+	Names string
+	Help  string
+}
+
+// The widths of the columns of the options table, without the padding
+const (
+	namesWidth = 28
+	helpWidth  = 62
+)
+
+// PrintManPage prints the program's help text, with the options table listing the given options
+func PrintManPage(options []Option) string {
 	intro := fmt.Sprintf("Zone01 Wget %s, a non-interactive network retriever.\n", info.Version)
 	man := `
 Usage: wget [OPTION]... [URL]...
 
-Mandatory arguments to long options are mandatory for short options too.
+Mandatory arguments to long options are mandatory for short options too. The arguments follow
+their options after ‘=’ or a space, e.g. ‘-O=file’, ‘-O file’ or ‘--output-document file’; short
+options may be bundled, e.g. ‘-qc’, and all the arguments after ‘--’ are URLs.
 
-    ┌──────────────────────────┬────────────────────────────────────────────────────────────────────┐
-    │ OPTION                   │ EXPLANATION                                                        │
-    ├──────────────────────────┼────────────────────────────────────────────────────────────────────┤
-    │ --help                   │ print this manual and exit                                         │
-    │ -v | --version           │ display the current version of wget and exit                       │
-    │ -B                       │ download a file immediately to the background,                     │
-    │                          │ redirecting the output to the log file (wget-log)                  │
-    │ -O=FILENAME              │ download a file and save it under a different name                 │
-    │ -P=PATH                  │ specify the path where to save downloaded resource                 │
-    │ --rate-limit=AMOUNT      │ Limit the download speed to AMOUNT bytes per second.               │
-    │                          │ Amount may be expressed in bytes, kilobytes with the ‘k’ suffix,   │ 
-    │                          │ or megabytes with the ‘M’ suffix. For example,                     │
-    │                          │ ‘--limit-rate=20k’ will limit the retrieval rate to 20KiB/s        │                         
-    │ -i=FILE                  │ Read URLs from the local file                                      │
-    │ --mirror                 │ mirror a website                                                   │
-    │ --reject | -R=list       │ list of file suffixes to avoid downloading during the retrieval    │
-    │ --exclude | -X=list      │ list of directories excluded from the download                     │
-    │ --convert-links          │ convert the links in the document,                                 │
-    │                          │ to make them suitable for local viewing                            │
-    └──────────────────────────┴────────────────────────────────────────────────────────────────────┘
+%s
+    Exit status, of the most severe failure, as GNU Wget:
+    0 success, 1 generic failure, 2 parse error, 3 file I/O error, 4 network failure,
+    5 TLS failure, 6 authentication failure, 7 protocol error, 8 server error response,
+    9 quota exceeded, 10 file too large, 130 interrupted
 
     Bug reports, questions, issues to:
     - https://github.com/rayjonesjay
@@ -95,6 +96,74 @@ Mandatory arguments to long options are mandatory for short options too.
 		randomQuote = fmt.Sprintf("Quote By %s:\n      %q", author, helpQuote)
 	}
 
-	man = strings.TrimLeft(man, "\n")
+	man = fmt.Sprintf(strings.TrimLeft(man, "\n"), table(options))
 	return intro + man + randomQuote
 }
+
+// table renders the options table, e.g.,
+//
+//	┌──────────┬───────────────────┐
+//	│ OPTION   │ EXPLANATION       │
+//	├──────────┼───────────────────┤
+//	│ --mirror │ mirror a website  │
+//	└──────────┴───────────────────┘
+func table(options []Option) string {
+	var b strings.Builder
+	rule := func(left, middle, right string) {
+		b.WriteString("    " + left + strings.Repeat("─", namesWidth+2) + middle)
+		b.WriteString(strings.Repeat("─", helpWidth+2) + right + "\n")
+	}
+	row := func(names, help string) {
+		b.WriteString("    │ " + pad(names, namesWidth) + " │ " + pad(help, helpWidth) + " │\n")
+	}
+
+	rule("┌", "┬", "┐")
+	row("OPTION", "EXPLANATION")
+	rule("├", "┼", "┤")
+	for _, option := range options {
+		names, help := wrap(option.Names, namesWidth, "  "), wrap(option.Help, helpWidth, "")
+		for i := 0; i < max(len(names), len(help)); i++ {
+			var n, h string
+			if i < len(names) {
+				n = names[i]
+			}
+			if i < len(help) {
+				h = help[i]
+			}
+			row(n, h)
+		}
+	}
+	rule("└", "┴", "┘")
+	return b.String()
+}
+
+// wrap breaks the given text into lines of at most width characters, at spaces and newlines, with the
+// continuation lines indented by indent. Words longer than the width are left whole
+func wrap(text string, width int, indent string) (lines []string) {
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			switch {
+			case line == "":
+				line = word
+				if len(lines) > 0 {
+					line = indent + word
+				}
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = indent + word
+			}
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// pad pads the given text with spaces to the given width, in characters
+func pad(text string, width int) string {
+	return text + strings.Repeat(" ", max(0, width-utf8.RuneCountInString(text)))
+}
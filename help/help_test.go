@@ -2,6 +2,8 @@ package help
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"wget/temp"
 )
@@ -13,10 +15,59 @@ func TestPrintManPage(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = file.WriteString(PrintManPage())
+	options := []Option{
+		{"--help", "print this manual and exit"},
+		{"-O | --output-document=FILE", "download a file and save it under a different name"},
+	}
+	_, err = file.WriteString(PrintManPage(options))
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	fmt.Printf("Man page written to file %q\n", file.Name())
 }
+
+func TestTable(t *testing.T) {
+	options := []Option{
+		{"--mirror", "mirror a website"},
+		{"-nH | --no-host-directories --and-more", "don't create the host directories of mirrored files, " +
+			"nor any of the ‘directories’ of the hosts, as the URLs are mirrored\nunless asked to"},
+	}
+	got := table(options)
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	for _, line := range lines {
+		if n := len([]rune(line)); n != 101 {
+			t.Errorf("table() line %q is %d characters wide, want 101", line, n)
+		}
+	}
+	if len(lines) != 8 {
+		t.Fatalf("table() = %d lines, want 8:\n%s", len(lines), got)
+	}
+	want := "    │   --and-more                 │ of the ‘directories’ of the hosts, as the URLs are mirrored    │"
+	if lines[5] != want {
+		t.Errorf("table() line 5 = %q, want %q", lines[5], want)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		width  int
+		indent string
+		want   []string
+	}{
+		{"fits", "mirror a website", 20, "", []string{"mirror a website"}},
+		{"wrapped", "mirror a whole website", 10, "", []string{"mirror a", "whole", "website"}},
+		{"indented", "-nH | --no-host-directories", 10, "  ", []string{"-nH |", "  --no-host-directories"}},
+		{"paragraphs", "a b\nc", 10, "", []string{"a b", "c"}},
+		{"empty", "", 10, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrap(tt.text, tt.width, tt.indent); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wrap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
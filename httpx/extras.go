@@ -4,6 +4,7 @@ package httpx
 import (
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -45,22 +46,54 @@ func ExtractContentLength(headers http.Header) int64 {
 	return contentLength
 }
 
+// ExtractCompleteLength extracts the complete length of the resource from the `Content-Range` HTTP response
+// header, e.g., 1000 of `bytes 200-999/1000`, or of `bytes */1000`, as sent along a 416 response. Suppose,
+// no `Content-Range` header exists, or the complete length is unknown, i.e., `*`, then, -1 is returned
+func ExtractCompleteLength(headers http.Header) int64 {
+	unit, rangeAndLength, found := strings.Cut(strings.TrimSpace(headers.Get("Content-Range")), " ")
+	if !found || unit != "bytes" {
+		return -1
+	}
+	_, completeLength, found := strings.Cut(rangeAndLength, "/")
+	if !found {
+		return -1
+	}
+
+	length, err := strconv.ParseInt(strings.TrimSpace(completeLength), 10, 64)
+	if err != nil || length < 0 {
+		return -1
+	}
+	return length
+}
+
 // FilenameFromContentDisposition returns the filename for the response contents, as dictated by
-// HTTP `Content -Disposition` headers
+// HTTP `Content -Disposition` headers. The RFC 6266 `filename*` parameter, whose value is encoded as per
+// RFC 5987, takes precedence over the `filename` parameter. Any directories in the filename are dropped
 func FilenameFromContentDisposition(headers http.Header) (string, error) {
 	contentDisposition := headers.Get("Content-Disposition")
 	if contentDisposition == "" {
 		return "", errors.New("content-disposition header not found")
 	}
 
-	re := regexp.MustCompile(`(?i)filename\s*=\s*"?([^";]+)"?`)
-	matches := re.FindStringSubmatch(contentDisposition)
+	// mime.ParseMediaType decodes the RFC 5987 encoded `filename*` into the `filename` parameter
+	var filename string
+	if _, params, err := mime.ParseMediaType(contentDisposition); err == nil {
+		filename = params["filename"]
+	} else {
+		// many servers send malformed headers, look for the filename anyway
+		re := regexp.MustCompile(`(?i)filename\s*=\s*"?([^";]+)"?`)
+		if matches := re.FindStringSubmatch(contentDisposition); len(matches) >= 2 {
+			filename = matches[1]
+		}
+	}
 
-	if len(matches) < 2 {
+	// the server doesn't get to choose the directory the file is saved in
+	filename = filename[strings.LastIndexAny(filename, `/\`)+1:]
+	if filename == "" || filename == "." || filename == ".." {
 		return "", errors.New("filename parameter not found in content-disposition header")
 	}
 
-	return matches[1], nil
+	return filename, nil
 }
 
 // RoundOfSizeOfData  converts dataInBytes (size of file downloaded) in bytes to the nearest size
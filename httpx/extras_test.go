@@ -126,6 +126,34 @@ func TestExtractContentLength(t *testing.T) {
 	}
 }
 
+func TestExtractCompleteLength(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentRange string
+		want         int64
+	}{
+		{"range", "bytes 200-999/1000", 1000},
+		{"unsatisfied range", "bytes */1000", 1000},
+		{"unknown length", "bytes 200-999/*", -1},
+		{"missing", "", -1},
+		{"other unit", "items 0-9/10", -1},
+		{"invalid", "bytes 200-999", -1},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				headers := http.Header{}
+				if tt.contentRange != "" {
+					headers.Set("Content-Range", tt.contentRange)
+				}
+				if got := ExtractCompleteLength(headers); got != tt.want {
+					t.Errorf("ExtractCompleteLength(%q) = %d, want %d", tt.contentRange, got, tt.want)
+				}
+			},
+		)
+	}
+}
+
 func TestFilenameFromContentDisposition(t *testing.T) {
 	type args struct {
 		headers http.Header
@@ -196,6 +224,32 @@ func TestFilenameFromContentDisposition(t *testing.T) {
 			want:    "文件.pdf",
 			wantErr: false,
 		},
+		{
+			name:    "RFC 5987 encoded filename",
+			args:    args{headers: http.Header{"Content-Disposition": []string{"attachment; filename*=UTF-8''na%C3%AFve%20file.txt"}}},
+			want:    "naïve file.txt",
+			wantErr: false,
+		},
+		{
+			name: "RFC 5987 encoded filename takes precedence",
+			args: args{headers: http.Header{"Content-Disposition": []string{
+				"attachment; filename=\"fallback.txt\"; filename*=UTF-8''%E2%82%AC%20rates.txt",
+			}}},
+			want:    "€ rates.txt",
+			wantErr: false,
+		},
+		{
+			name:    "Filename with directories",
+			args:    args{headers: http.Header{"Content-Disposition": []string{"attachment; filename=\"../../etc/passwd\""}}},
+			want:    "passwd",
+			wantErr: false,
+		},
+		{
+			name:    "Filename of a directory only",
+			args:    args{headers: http.Header{"Content-Disposition": []string{"attachment; filename=\"..\""}}},
+			want:    "",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
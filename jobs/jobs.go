@@ -0,0 +1,263 @@
+// Package jobs keeps the state of the downloads run in the background by -B, as files under temp.Dir(),
+// such that `wget --jobs` lists them, with their progress, and `wget --cancel=ID` stops them gracefully
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+	"wget/fileio"
+	"wget/globals"
+	"wget/temp"
+	"wget/xerr"
+)
+
+// The states of a job
+const (
+	// Running is the state of the jobs still downloading
+	Running = "running"
+	// Done is the state of the jobs whose downloads all succeeded
+	Done = "done"
+	// Failed is the state of the jobs that failed, including those whose process exited unexpectedly
+	Failed = "failed"
+	// Cancelled is the state of the jobs stopped by --cancel, or by an interrupt
+	Cancelled = "cancelled"
+)
+
+// Env is the environment variable giving the ID of its job to the background process
+const Env = "WGET_JOB"
+
+// Job is a download run in the background, as saved to its state file
+type Job struct {
+	// ID identifies the job, e.g., to --cancel
+	ID string `json:"id"`
+	// PID is the process ID of the background process; 0 until the process records it
+	PID int `json:"pid"`
+	// Process identifies the process of the PID, by the time it started, to tell it from a later process given
+	// the same PID; empty if it can't be told, see processStart
+	Process string `json:"process,omitempty"`
+	// URLs are the URLs given to the job, and Input the file of URLs given to -i, if any
+	URLs  []string `json:"urls"`
+	Input string   `json:"input,omitempty"`
+	// Log is the log file the job writes its output to
+	Log string `json:"log"`
+	// State is the state of the job, e.g., Running
+	State string `json:"state"`
+	// Started and Finished are the times the job started, and finished, if it did
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	// Files is the number of the downloads started, and Done the number of those that finished
+	Files int `json:"files"`
+	Done  int `json:"done"`
+	// Bytes is the number of bytes downloaded, out of Total, the total size of the files, as far as known
+	Bytes int64 `json:"bytes"`
+	Total int64 `json:"total"`
+	// Error is the error the job failed with, and Status its exit status, once finished
+	Error  string `json:"error,omitempty"`
+	Status int    `json:"status"`
+
+	mutex sync.Mutex
+	// saved is the last time the state file was saved, to save the progress at most once a second
+	saved time.Time
+}
+
+// Dir returns the directory of the state files of the jobs, creating it if needed
+func Dir() string {
+	dir := filepath.Join(temp.Dir(), "jobs")
+	_ = os.MkdirAll(dir, 0775)
+	return dir
+}
+
+// New creates the state file of a new job, downloading the given URLs, or those of the given input file,
+// writing its output to the given log file. The job is Running, its PID is set by the process as it starts,
+// see Current
+func New(urls []string, input, log string) (*Job, error) {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	if input != "" {
+		input, _ = filepath.Abs(input)
+	}
+	log, _ = filepath.Abs(log)
+	job := &Job{
+		ID: hex.EncodeToString(id), URLs: urls, Input: input, Log: log, State: Running, Started: time.Now(),
+	}
+	return job, job.Save()
+}
+
+// Current returns the job of the running process, as given by Env, or nil if it isn't a background job.
+// The PID of the job is set to that of the running process
+func Current() (*Job, error) {
+	id := os.Getenv(Env)
+	if id == "" {
+		return nil, nil
+	}
+	job, err := Load(id)
+	if err != nil {
+		return nil, err
+	}
+	job.PID = os.Getpid()
+	job.Process, _ = processStart(job.PID)
+	return job, job.Save()
+}
+
+// Load returns the job of the given ID. The Running jobs whose process is gone are returned as Failed, even if
+// their PID was given to another process since
+func Load(id string) (*Job, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, fmt.Errorf("invalid job ID %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(Dir(), id+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no job %q, see wget --jobs", id)
+	} else if err != nil {
+		return nil, err
+	}
+	job := &Job{}
+	if err = json.Unmarshal(data, job); err != nil {
+		return nil, fmt.Errorf("invalid state file of job %q: %w", id, err)
+	}
+	if job.State == Running && job.PID > 0 && !job.alive() {
+		job.State, job.Error = Failed, "the process exited unexpectedly"
+	}
+	return job, nil
+}
+
+// List returns all the jobs, running and finished, by the time they started
+func List() ([]*Job, error) {
+	names, err := filepath.Glob(filepath.Join(Dir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*Job, 0, len(names))
+	for _, name := range names {
+		job, err := Load(strings.TrimSuffix(filepath.Base(name), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Started.Before(jobs[j].Started) })
+	return jobs, nil
+}
+
+// Cancel stops the running job of the given ID gracefully, as an interrupt does: no new downloads are started,
+// and the partial files of the active ones are kept, to be continued with --continue. Cancel returns once the
+// job is signalled, the job then records its state as Cancelled
+func Cancel(id string) (*Job, error) {
+	job, err := Load(id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case job.State != Running:
+		return job, fmt.Errorf("job %s isn't running, it's %s", id, job.State)
+	case job.PID <= 0:
+		// signalling the PID 0 would signal our own process group
+		return job, fmt.Errorf("job %s is still starting, try again", id)
+	}
+	return job, syscall.Kill(job.PID, syscall.SIGTERM)
+}
+
+// Save writes the state file of the job. The file is replaced at once, such that it's never read half written
+func (j *Job) Save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileio.Replace(filepath.Join(Dir(), j.ID+".json"), data)
+}
+
+// Finish records that the job finished with the given error: Done if nil, Cancelled if the downloads were
+// stopped by the context, or Failed otherwise
+func (j *Job) Finish(err error) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	now := time.Now()
+	j.Finished, j.Status = &now, xerr.ExitStatus(err)
+	switch {
+	case err == nil:
+		j.State = Done
+	case errors.Is(err, context.Canceled):
+		j.State = Cancelled
+	default:
+		j.State, j.Error = Failed, err.Error()
+	}
+	return j.Save()
+}
+
+// update updates the job with the given function, and saves it, at most once a second, unless forced
+func (j *Job) update(f func(j *Job), force bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	f(j)
+	if now := time.Now(); force || now.Sub(j.saved) >= time.Second {
+		j.saved = now
+		// the progress is saved again soon enough, if the state file can't be written for now
+		_ = j.Save()
+	}
+}
+
+// Print writes the given jobs as a table, e.g.
+//
+//	ID        STATE    FILES  PROGRESS  DOWNLOADED  STARTED              URL
+//	3f9a2c1b  running  1/3    42%       1.20 MiB    2026-10-18 23:05:39  https://example.com/a.iso (+2)
+func Print(w io.Writer, jobs []*Job) error {
+	if len(jobs) == 0 {
+		_, err := fmt.Fprintln(w, "no background jobs, start one with -B")
+		return err
+	}
+	t := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(t, "ID\tSTATE\tFILES\tPROGRESS\tDOWNLOADED\tSTARTED\tURL")
+	for _, job := range jobs {
+		progress := "--"
+		if job.State == Done {
+			progress = "100%"
+		} else if job.Total > 0 {
+			progress = fmt.Sprintf("%d%%", min(job.Bytes*100/job.Total, 99))
+		}
+		_, _ = fmt.Fprintf(
+			t, "%s\t%s\t%d/%d\t%s\t%s\t%s\t%s\n", job.ID, job.State, job.Done, job.Files, progress,
+			globals.FormatSize(job.Bytes), job.Started.Format(time.DateTime), job.target(),
+		)
+	}
+	return t.Flush()
+}
+
+// target describes what the job downloads: its first URL, and the number of the others, or its input file
+func (j *Job) target() string {
+	switch {
+	case len(j.URLs) == 0:
+		return j.Input
+	case len(j.URLs) > 1 || j.Input != "":
+		return fmt.Sprintf("%s (+%d)", j.URLs[0], len(j.URLs)-1)
+	default:
+		return j.URLs[0]
+	}
+}
+
+// alive tells whether the process of the job is running, rather than a later process given the same PID
+func (j *Job) alive() bool {
+	if err := syscall.Kill(j.PID, 0); err != nil && !errors.Is(err, syscall.EPERM) {
+		return false
+	}
+	if j.Process == "" {
+		return true
+	}
+	process, err := processStart(j.PID)
+	return err == nil && process == j.Process
+}
@@ -0,0 +1,212 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"wget/progress"
+	"wget/xerr"
+)
+
+// TestMain keeps the state files of the tests out of the temporary directory of the machine
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "jobs")
+	if err != nil {
+		panic(err)
+	}
+	_ = os.Setenv("TMPDIR", dir)
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestNew(t *testing.T) {
+	job, err := New([]string{"https://example.com/a.txt"}, "", "wget-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.State != Running || loaded.URLs[0] != "https://example.com/a.txt" || !strings.HasSuffix(loaded.Log, "/wget-log") {
+		t.Errorf("Load() = %+v, want the running job", loaded)
+	}
+
+	list, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, j := range list {
+		found = found || j.ID == job.ID
+	}
+	if !found {
+		t.Errorf("List() = %v, want the job %s", list, job.ID)
+	}
+}
+
+func TestCurrent(t *testing.T) {
+	t.Setenv(Env, "")
+	if job, err := Current(); job != nil || err != nil {
+		t.Errorf("Current() = %v, %v, want no job", job, err)
+	}
+
+	created, _ := New([]string{"https://example.com/a.txt"}, "", "wget-log")
+	t.Setenv(Env, created.ID)
+	job, err := Current()
+	if err != nil || job.ID != created.ID || job.PID != os.Getpid() {
+		t.Errorf("Current() = %+v, %v, want the job of this process", job, err)
+	}
+	if runtime.GOOS == "linux" && job.Process == "" {
+		t.Errorf("Current() = %+v, want the process identified by its start time", job)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	for _, id := range []string{"", "../etc", "missing"} {
+		if _, err := Load(id); err == nil {
+			t.Errorf("Load(%q) error = nil", id)
+		}
+	}
+
+	// a job whose process is gone failed
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip(err)
+	}
+	job, _ := New(nil, "urls.txt", "wget-log")
+	job.PID = cmd.Process.Pid
+	_ = job.Save()
+	if loaded, _ := Load(job.ID); loaded.State != Failed {
+		t.Errorf("Load() state = %s, want %s", loaded.State, Failed)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skip(err)
+	}
+	job, _ := New([]string{"https://example.com/a.txt"}, "", "wget-log")
+	if _, err := Cancel(job.ID); err == nil || !strings.Contains(err.Error(), "starting") {
+		t.Errorf("Cancel() error = %v, want the job still starting", err)
+	}
+
+	job.PID = cmd.Process.Pid
+	_ = job.Save()
+	if _, err := Cancel(job.ID); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	var exit *exec.ExitError
+	if err := cmd.Wait(); !errors.As(err, &exit) || exit.Sys().(syscall.WaitStatus).Signal() != syscall.SIGTERM {
+		t.Errorf("expected the process to be terminated, got %v", err)
+	}
+
+	_ = job.Finish(fmt.Errorf("download interrupted: %w", context.Canceled))
+	if _, err := Cancel(job.ID); err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("Cancel() error = %v, want the job not running", err)
+	}
+}
+
+func TestCancel_ReusedPID(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skip(err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	// the process of the job crashed, and its PID was given to another process since
+	job, _ := New([]string{"https://example.com/a.txt"}, "", "wget-log")
+	job.PID, job.Process = cmd.Process.Pid, "1"
+	_ = job.Save()
+	if loaded, _ := Load(job.ID); loaded.State != Failed {
+		t.Errorf("Load() state = %s, want %s", loaded.State, Failed)
+	}
+	if _, err := Cancel(job.ID); err == nil {
+		t.Error("Cancel() error = nil, want the job not running")
+	}
+	if err := cmd.Process.Signal(syscall.Signal(0)); err != nil {
+		t.Errorf("expected the other process to be left running, got %v", err)
+	}
+}
+
+func TestJob_Finish(t *testing.T) {
+	tests := []struct {
+		err    error
+		state  string
+		status int
+	}{
+		{nil, Done, 0},
+		{context.Canceled, Cancelled, xerr.InterruptedStatus},
+		{xerr.WithStatus(xerr.ServerStatus, errors.New("404 Not Found")), Failed, xerr.ServerStatus},
+	}
+	for _, tt := range tests {
+		job, _ := New([]string{"https://example.com/a.txt"}, "", "wget-log")
+		if err := job.Finish(tt.err); err != nil {
+			t.Fatal(err)
+		}
+		loaded, _ := Load(job.ID)
+		if loaded.State != tt.state || loaded.Status != tt.status || loaded.Finished == nil {
+			t.Errorf("Finish(%v) = %+v, want %s, exit status %d", tt.err, loaded, tt.state, tt.status)
+		}
+	}
+}
+
+func TestJob_Reporter(t *testing.T) {
+	job, _ := New([]string{"https://example.com/a.txt", "https://example.com/b.txt"}, "", "wget-log")
+	r := job.Reporter(progress.New(progress.Options{Verbosity: progress.Quiet}))
+	a, b := r.Listener(0, "https://example.com/a.txt"), r.Listener(1, "https://example.com/b.txt")
+	a.OnContentLength(100)
+	b.OnContentLength(-1)
+	a.OnProgress(40, 100, -1)
+	a.OnProgress(-1, -1, 1000)
+	a.OnProgress(100, 100, -1)
+	a.OnDownloadFinished("https://example.com/a.txt", time.Now())
+	b.OnProgress(50, -1, -1)
+	// the length of b, unknown until it finishes
+	b.OnContentLength(50)
+
+	_ = job.Save()
+	loaded, _ := Load(job.ID)
+	if loaded.Files != 2 || loaded.Done != 1 || loaded.Bytes != 150 || loaded.Total != 150 {
+		t.Errorf("Reporter() recorded %+v, want 1/2 files, 150/150 bytes", loaded)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	var out strings.Builder
+	if err := Print(&out, nil); err != nil || !strings.Contains(out.String(), "no background jobs") {
+		t.Errorf("Print() = %q, %v, want no jobs", out.String(), err)
+	}
+
+	started := time.Date(2026, 10, 18, 23, 5, 39, 0, time.Local)
+	jobs := []*Job{
+		{
+			ID: "3f9a2c1b", State: Running, Files: 3, Done: 1, Bytes: 42 << 10, Total: 100 << 10, Started: started,
+			URLs: []string{"https://example.com/a.iso", "https://example.com/b.iso", "https://example.com/c.iso"},
+		},
+		{ID: "0b1c2d3e", State: Failed, Files: 1, Started: started, Input: "/home/user/urls.txt"},
+	}
+	out.Reset()
+	if err := Print(&out, jobs); err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"ID        STATE    FILES  PROGRESS  DOWNLOADED  STARTED              URL\n" +
+		"3f9a2c1b  running  1/3    42%       42.00 KiB   2026-10-18 23:05:39  https://example.com/a.iso (+2)\n" +
+		"0b1c2d3e  failed   0/1    --        0 B         2026-10-18 23:05:39  /home/user/urls.txt\n"
+	if out.String() != want {
+		t.Errorf("Print() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// processStart returns the time the process of the given ID started, in clock ticks since the boot, as read
+// from /proc/PID/stat. Along with the PID, it identifies the process, as PIDs are reused
+func processStart(pid int) (string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", err
+	}
+	// the name of the command, in parentheses, may hold spaces, and parentheses; the fields after it start
+	// with the state, the third field, up to the start time, the twenty-second
+	stat := strings.Fields(string(data[bytes.LastIndexByte(data, ')')+1:]))
+	if len(stat) < 20 {
+		return "", errors.New("invalid /proc stat")
+	}
+	return stat[19], nil
+}
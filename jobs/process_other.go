@@ -0,0 +1,12 @@
+//go:build !linux
+
+package jobs
+
+import "errors"
+
+// processStart returns the time the process of the given ID started, to identify the process along with its
+// PID, as PIDs are reused. It's only supported on Linux, elsewhere an error is returned, and the processes are
+// told by their PID alone
+func processStart(pid int) (string, error) {
+	return "", errors.ErrUnsupported
+}
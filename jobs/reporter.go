@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"time"
+	"wget/fetch"
+	"wget/progress"
+)
+
+// reporter records the progress of the downloads to the state file of its job, while reporting it to the
+// wrapped reporter
+type reporter struct {
+	progress.Reporter
+	job *Job
+}
+
+// Reporter returns a reporter recording the progress of the downloads to the state file of the job,
+// while reporting it to the given reporter
+func (j *Job) Reporter(r progress.Reporter) progress.Reporter {
+	return reporter{Reporter: r, job: j}
+}
+
+func (r reporter) Listener(n int, url string) fetch.AdvancedProgressListener {
+	l := r.Reporter.Listener(n, url)
+	r.job.update(func(j *Job) { j.Files++ }, true)
+
+	// the bytes downloaded, and the size, of this download, as counted in the totals of the job
+	var downloaded, size int64
+	onContentLength, onProgress, onDownloadFinished := l.OnContentLength, l.OnProgress, l.OnDownloadFinished
+	l.OnContentLength = func(length int64) {
+		r.job.update(func(j *Job) {
+			// the length is given again once the download finishes, if it wasn't known
+			j.Total += max(length, 0) - size
+			size = max(length, 0)
+		}, false)
+		onContentLength(length)
+	}
+	l.OnProgress = func(bytes, total int64, rate int32) {
+		// the bytes are only given along with a negative rate
+		if rate < 0 {
+			r.job.update(func(j *Job) {
+				j.Bytes += bytes - downloaded
+				downloaded = bytes
+			}, false)
+		}
+		onProgress(bytes, total, rate)
+	}
+	l.OnDownloadFinished = func(url string, t time.Time) {
+		r.job.update(func(j *Job) { j.Done++ }, true)
+		onDownloadFinished(url, t)
+	}
+	return l
+}
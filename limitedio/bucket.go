@@ -0,0 +1,157 @@
+package limitedio
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket, that is continuously refilled at `rate` tokens
+// (bytes) per second, upto a maximum of `burst` tokens. Readers reserve tokens
+// before they read, and wait for as long as it takes for the bucket to refill
+// the tokens they reserved. This spreads reads evenly over time, rather than
+// allowing a whole second's worth of reads at once, followed by a stall
+type bucket struct {
+	// m locks this instance, as it may be accessed by readers in different goroutines
+	m *sync.Mutex
+	// rate is the number of tokens added to the bucket every second
+	rate int32
+	// burst is the maximum number of tokens the bucket can hold
+	burst int64
+	// step, if > 0, derives the burst from the rate, as the number of tokens
+	// added to the bucket within a step; such that the burst follows rate changes
+	step time.Duration
+	// tokens is the number of tokens in the bucket right now. It may be negative,
+	// when readers have reserved more tokens than are available, in which case
+	// they wait for the bucket to be refilled before reading
+	tokens float64
+	// last is the time the tokens were last refilled
+	last time.Time
+}
+
+// newBucket creates a new full token bucket, refilled at `rate` tokens per
+// second, holding upto `burst` tokens. If the `rate` is <= 0, the bucket
+// never runs out of tokens, and if burst is <= 0, it holds one second's worth of tokens
+func newBucket(rate int32, burst int64) *bucket {
+	step := time.Duration(0)
+	if burst <= 0 {
+		step = time.Second
+	}
+	return newSteppedBucket(rate, burst, step)
+}
+
+// newSteppedBucket creates a new full token bucket, refilled at `rate` tokens
+// per second. If step > 0, the bucket holds as many tokens as are added within
+// the step, otherwise, it holds upto `burst` tokens
+func newSteppedBucket(rate int32, burst int64, step time.Duration) *bucket {
+	b := &bucket{
+		m:    &sync.Mutex{},
+		step: step,
+		last: time.Now(),
+	}
+	b.burst = max(burst, 1)
+	b.setRate(rate)
+	b.tokens = float64(b.burst)
+	return b
+}
+
+// unlimited returns true if this bucket never runs out of tokens. The caller must hold the lock
+func (b *bucket) unlimited() bool {
+	return b.rate == math.MaxInt32
+}
+
+// refill adds the tokens accumulated since the last refill, upto the burst size.
+// The caller must hold the lock
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	b.tokens = min(b.tokens+elapsed*float64(b.rate), float64(b.burst))
+}
+
+// reserve reserves upto n tokens, but never more than `limit` tokens at once.
+// Returns the number of tokens reserved, and how long the caller should wait
+// before the reserved tokens are available
+func (b *bucket) reserve(n, limit int64) (int64, time.Duration) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	if n <= 0 || b.unlimited() {
+		return n, 0
+	}
+
+	b.refill(time.Now())
+	n = min(n, max(limit, 1))
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return n, 0
+	}
+	return n, time.Duration(-b.tokens / float64(b.rate) * float64(time.Second))
+}
+
+// refund returns n reserved tokens, that were not used, back to the bucket
+func (b *bucket) refund(n int64) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	if n <= 0 || b.unlimited() {
+		return
+	}
+
+	b.tokens = min(b.tokens+float64(n), float64(b.burst))
+}
+
+// setBurst changes the maximum number of tokens the bucket can hold, the burst
+// will no longer follow changes to the rate. A full bucket remains full
+func (b *bucket) setBurst(burst int64) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.refill(time.Now())
+	b.step = 0
+	b.resize(burst)
+}
+
+// resize changes the maximum number of tokens the bucket can hold. A full
+// bucket remains full. The caller must hold the lock
+func (b *bucket) resize(burst int64) {
+	full := b.tokens >= float64(b.burst)
+	b.burst = max(burst, 1)
+	if full {
+		b.tokens = float64(b.burst)
+	}
+	b.tokens = min(b.tokens, float64(b.burst))
+}
+
+// SetRate changes the number of tokens added to the bucket every second. This
+// is safe to call while readers are waiting for tokens; the new rate applies to
+// tokens reserved after the call. If the `rate` is <= 0, the bucket never runs out of tokens
+func (b *bucket) SetRate(rate int32) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.refill(time.Now())
+	b.setRate(rate)
+}
+
+// setRate changes the rate of the bucket. The caller must hold the lock
+func (b *bucket) setRate(rate int32) {
+	if rate <= 0 {
+		rate = math.MaxInt32
+	}
+	b.rate = rate
+
+	if b.step > 0 {
+		b.resize(max(int64(float64(rate)*b.step.Seconds()), 1))
+	}
+}
+
+// Rate returns the number of tokens added to the bucket every second, or
+// math.MaxInt32 if the bucket never runs out of tokens
+func (b *bucket) Rate() int32 {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.rate
+}
+
+// maxBurst returns the maximum number of tokens the bucket can hold
+func (b *bucket) maxBurst() int64 {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.burst
+}
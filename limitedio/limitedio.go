@@ -6,7 +6,6 @@ import (
 	"io"
 	"math"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
@@ -14,41 +13,6 @@ import (
 // See https://golang.org/issues/8005#issuecomment-190753527 for details.
 type noCopy struct{}
 
-// waiters keeps track of whether there is some reader waiting to read from the channel, so we can write to it
-type waiters struct {
-	m    *sync.Mutex
-	some bool
-	// bidirectional channel to keep readers waiting for the next read allocation after the maximum reads for the
-	//current second have been depleted. The read allocator will replenish the reads every second
-	channel chan struct{}
-}
-
-// init properly initializes the target waiter, in place, with a new mutex and non-nil channel, overriding any values
-func (w *waiters) init() {
-	w.m = &sync.Mutex{}
-	w.channel = make(chan struct{})
-	w.some = false
-}
-
-// Receive registers a read waiter, who waits to read from the channel, thus,
-// writers can write to the channel; then, blocks until it receives some data from the channel
-func (w *waiters) Receive() {
-	w.m.Lock()
-	w.some = true
-	w.m.Unlock()
-
-	<-w.channel
-}
-
-// Send checks if there is some reader waiting to read from the channel, before sending some data to the channel
-func (w *waiters) Send() {
-	w.m.Lock()
-	defer w.m.Unlock()
-	if w.some {
-		w.channel <- struct{}{}
-	}
-}
-
 // SGReader defines the structure of an io.Reader interface, that limits the rate of allowed reads (
 // in bytes per second) on the underlying io.Reader. Always use NewSGReader to properly create instances of this struct
 type SGReader struct {
@@ -58,10 +22,17 @@ type SGReader struct {
 	speed int32
 	// pointer to wrapped closable io.Reader
 	reader *io.ReadCloser
-	// every second we can read a maximum of speed bytes from the wrapped reader.
-	//This keeps track of how many bytes can be read before the current second elapses
-	reads atomic.Int64
-	waiters
+	// bucket is continuously refilled with `speed` bytes every second,
+	//reads are only allowed after the bytes to be read have been taken from the bucket
+	bucket *bucket
+	// keeps track of how many bytes have been read within each second since the first read.
+	//The bytes are counted in the second they were allowed to be read, such that reads finishing just as
+	//the rate listener is called, are reported in the right second
+	reads map[int64]int64
+	// start is the time the first read started
+	start time.Time
+	// m locks reads, which is accessed by both the reading and the rate reporting goroutines
+	m *sync.Mutex
 	// has the Read function been called since the creation of this struct instance.
 	//This helps us to not start tick timers when we don't need them yet; i.e,
 	//it is not ideal to display read speed when we haven't even started any reads yet
@@ -70,10 +41,14 @@ type SGReader struct {
 	onClose func()
 	// see SetRateListener
 	rateListener func(speed int32)
+	// shared, if not nil, is the limiter whose bandwidth this reader shares with other readers
+	shared *SharedLimiter
 }
 
 // NewSGReader creates a new instance of a Speed Governed reader,
 // that will allow reads of upto `rate` bytes per second.
+// By default, the reads are spread out in steps of a tenth of a second, see SetBurst.
+// The rate may later be changed, even while reading, see SetRate.
 //
 // Note:
 //
@@ -92,46 +67,60 @@ func NewSGReader(rate int32, reader *io.ReadCloser) *SGReader {
 	sgr := SGReader{
 		speed:  rate,
 		reader: reader,
+		bucket: newSteppedBucket(rate, 0, time.Second/10),
+		reads:  make(map[int64]int64),
+		m:      &sync.Mutex{},
 	}
-	// start by allowing reads upto rate bytes
-	sgr.reads.Store(int64(rate))
-	sgr.waiters.init()
 	return &sgr
 }
 
+// SetBurst sets the maximum number of bytes that can be read at once, after the
+// reader has been idle for long enough. A smaller burst results in a smoother
+// read rate, at the cost of more, smaller reads from the underlying reader
+func (r *SGReader) SetBurst(burst int64) {
+	r.bucket.setBurst(burst)
+}
+
+// SetRate changes the maximum rate of reads to `rate` bytes per second. This is
+// safe to call while the reader is being read from, in which case, reads
+// following the call will honor the new rate. If the `rate` is <= 0, then there
+// will not be any speed limiting
+func (r *SGReader) SetRate(rate int32) {
+	r.bucket.SetRate(rate)
+}
+
 // SetRateListener sets a callback to be called every second, for the lifetime of this reader,
 // with the current read rate, in bytes/second
 func (r *SGReader) SetRateListener(rateListener func(rate int32)) {
 	r.rateListener = rateListener
 }
 
-// once must be called once for every instance of the SGReader to initialize a timer that ensures the read hard limit
-// is respected every second, and that the registered rateListener is properly updates every second
+// once must be called once for every instance of the SGReader to initialize a timer that ensures that the
+// registered rateListener is properly updated every second
 func (r *SGReader) once() {
 	// Create a context that can be canceled
 	ctx, cancel := context.WithCancel(context.Background())
+	// the ticker must be created after the start time, so that the ticks happen at
+	//no earlier than each whole second since the start
+	r.start = time.Now()
+	// Create a ticker that ticks every second
+	ticker := time.NewTicker(1 * time.Second)
+	// need to stop the timer,
+	//and cancel the go routine context when the underlying reader is just about to be closed
+	r.onClose = func() {
+		ticker.Stop()
+		cancel()
+	}
 
 	go func() {
-		// Create a ticker that ticks every second
-		ticker := time.NewTicker(1 * time.Second)
-		// need to stop the timer,
-		//and cancel the go routine context when the underlying reader is just about to be closed
-		r.onClose = func() {
-			ticker.Stop()
-			cancel()
-		}
-
 		for {
 			select {
-			case <-ticker.C:
-				// replenish the bytes that can be read every second
-				old := r.reads.Swap(int64(r.speed))
-				rate := int64(r.speed) - old
+			case now := <-ticker.C:
+				// report the bytes read within the second that just elapsed
+				rate := r.elapsed(int64(now.Sub(r.start) / time.Second))
 				if r.rateListener != nil {
-					r.rateListener(int32(rate))
+					r.rateListener(int32(min(rate, math.MaxInt32)))
 				}
-				// tell readers that we have allocated some more bandwidth
-				r.waiters.Send()
 			case <-ctx.Done():
 				return
 			}
@@ -147,7 +136,7 @@ func (r *SGReader) once() {
 // returns what is available instead of waiting for more.
 //
 // Note that for this SGReader,
-// calls to Read may block as more allocations are awaited suppose the read rate for an instance of a second have been
+// calls to Read may block as more bytes are awaited to be allowed to be read, suppose the read rate has been
 // exceeded.
 //
 // This implementation honors, the recommendations by the io.ReadCloser Read interface
@@ -158,25 +147,56 @@ func (r *SGReader) Read(p []byte) (n int, err error) {
 		r.once()
 	}
 
-	// read at most r.reads bytes
-	bytesToRead := min(int64(len(p)), r.reads.Load())
-	if len(p) != 0 && bytesToRead == 0 {
-		// There is no available read allocations, wait for the next round of allocation
-		r.waiters.Receive()
-		bytesToRead = min(int64(len(p)), r.reads.Load())
+	// reserve the bytes we are allowed to read, at most a burst at a time
+	reserved, wait := r.bucket.reserve(int64(len(p)), r.bucket.maxBurst())
+	bytesToRead := reserved
+
+	// the bandwidth may be shared with other readers, reserve our share of it
+	if r.shared != nil {
+		var sharedWait time.Duration
+		bytesToRead, sharedWait = r.shared.reserve(bytesToRead)
+		wait = max(wait, sharedWait)
 	}
 
-	// we are about to read some bytes of length `bytesToRead`,
-	//remove this from the current allocation
-	r.reads.Add(-bytesToRead)
+	// wait until the reserved bytes are allowed to be read
+	allowedAt := time.Now().Add(wait)
+	time.Sleep(wait)
 
 	// Read the specified bytes
 	reader := *r.reader
 	n, err = reader.Read(p[:bytesToRead])
+	r.count(allowedAt, int64(n))
+
+	// give back the bandwidth that we reserved but didn't use
+	r.bucket.refund(reserved - int64(n))
+	if r.shared != nil {
+		r.shared.refund(bytesToRead - int64(n))
+	}
 
 	return n, err
 }
 
+// count records that n bytes, which were allowed to be read at the given time, have been read
+func (r *SGReader) count(at time.Time, n int64) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.reads[int64(at.Sub(r.start)/time.Second)] += n
+}
+
+// elapsed returns the number of bytes read before the given second (since the
+// first read) elapsed, that have not been reported yet
+func (r *SGReader) elapsed(second int64) (n int64) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	for s, reads := range r.reads {
+		if s < second {
+			n += reads
+			delete(r.reads, s)
+		}
+	}
+	return
+}
+
 // Close performs cleanup on the SGReader, then closes the underlying closable io.Reader,
 // propagating errors as may occur
 func (r *SGReader) Close() error {
@@ -185,6 +205,11 @@ func (r *SGReader) Close() error {
 		r.onClose()
 	}
 
+	if r.shared != nil {
+		r.shared.release()
+		r.shared = nil
+	}
+
 	// Close the underlying reader too
 	reader := *r.reader
 	return reader.Close()
@@ -6,7 +6,9 @@ import (
 	"io"
 	"math"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 	"wget/fileio"
@@ -180,6 +182,7 @@ func TestSGReader_Read(t *testing.T) {
 
 	readStart := make(chan struct{})
 
+	var mutex sync.Mutex
 	var database []byte
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -204,7 +207,9 @@ func TestSGReader_Read(t *testing.T) {
 			select {
 			case <-ticker.C:
 				// Check that the database has exactly n, n-1, or n+1 bytes, (graceful test)
+				mutex.Lock()
 				l := len(database)
+				mutex.Unlock()
 				if !(l == n || l == n-1 || l == n+1) {
 					t.Error(
 						"[1] Database doesn't have the expected number of bytes; reads exceeding speed limit, " +
@@ -238,7 +243,9 @@ func TestSGReader_Read(t *testing.T) {
 			break
 		}
 
+		mutex.Lock()
 		database = append(database, buffer[:n]...)
+		mutex.Unlock()
 	}
 	endTime := time.Now()
 
@@ -270,3 +277,93 @@ func checkPanic(f func()) (panicked bool, message interface{}) {
 	f()
 	return
 }
+
+// readWindows reads from the given reader, in chunks of 8KiB, for the given
+// number of windows of the given duration; returns the number of bytes read
+// within each window
+func readWindows(reader io.Reader, window time.Duration, windows int) []int64 {
+	buffer := make([]byte, 8*1024)
+	totals := make([]int64, windows)
+	start := time.Now()
+	for {
+		n, err := reader.Read(buffer)
+		i := int(time.Since(start) / window)
+		if i >= windows || err != nil {
+			return totals
+		}
+		totals[i] += int64(n)
+	}
+}
+
+// TestSGReader_Smooth asserts that reads are spread evenly within each second,
+// rather than a whole second's worth of reads happening at once, followed by a stall
+func TestSGReader_Smooth(t *testing.T) {
+	const rate = 40_000
+	var reader io.ReadCloser = zeroReadCloser{}
+	sgReader := NewSGReader(rate, &reader)
+	defer fileio.Close(sgReader)
+
+	// every 100ms window, we expect to have read a tenth of the rate
+	windows := readWindows(sgReader, 100*time.Millisecond, 15)
+	for i, n := range windows {
+		if n < rate/10/2 || n > rate/10*2 {
+			t.Errorf("expected about %d bytes read in window %d, got %d; windows %v", rate/10, i, n, windows)
+		}
+	}
+}
+
+// TestSGReader_SetBurst asserts that a larger burst allows reading more bytes at
+// once, after the reader has been idle, but doesn't affect the long term rate
+func TestSGReader_SetBurst(t *testing.T) {
+	const rate = 10_000
+	var reader io.ReadCloser = zeroReadCloser{}
+	sgReader := NewSGReader(rate, &reader)
+	sgReader.SetBurst(rate / 2)
+	defer fileio.Close(sgReader)
+
+	windows := readWindows(sgReader, 250*time.Millisecond, 8)
+	// the first window includes the burst of half a second's worth of reads
+	if first := windows[0]; first != rate/2 {
+		t.Errorf("expected the burst to be read within the first window, got %d; windows %v", first, windows)
+	}
+	// the reads then continue at the rate, in steps of the burst
+	var rest int64
+	for _, n := range windows[1:] {
+		rest += n
+	}
+	if expected := int64(rate * 7 / 4); rest < expected-rate/2 || rest > expected+rate/2 {
+		t.Errorf("expected about %d bytes read after the burst, got %d; windows %v", expected, rest, windows)
+	}
+}
+
+// TestSGReader_RateListener asserts that the rate listener is called every
+// second with the number of bytes read within that second
+func TestSGReader_RateListener(t *testing.T) {
+	const rate = 20_000
+	var reader io.ReadCloser = zeroReadCloser{}
+	sgReader := NewSGReader(rate, &reader)
+	defer fileio.Close(sgReader)
+
+	// the listener is called by the goroutine of the reader
+	var mutex sync.Mutex
+	var rates []int32
+	sgReader.SetRateListener(func(rate int32) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		rates = append(rates, rate)
+	})
+	readWindows(sgReader, 2100*time.Millisecond, 1)
+
+	mutex.Lock()
+	rates = slices.Clone(rates)
+	mutex.Unlock()
+
+	if len(rates) != 2 {
+		t.Fatalf("expected the rate listener to be called twice, got %v", rates)
+	}
+	for _, r := range rates {
+		if r < rate*9/10 || r > rate*11/10 {
+			t.Errorf("expected rates of about %d bytes/second, got %v", rate, rates)
+		}
+	}
+}
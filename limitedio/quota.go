@@ -0,0 +1,80 @@
+package limitedio
+
+import (
+	"context"
+	"sync"
+)
+
+// Quota keeps the bytes read by concurrent downloads, as they're read, against a quota, as defined by --quota.
+// Once the quota is exceeded, no new downloads are started, and those in progress are stopped, but the one that
+// exceeded it, as GNU Wget completes the download that exceeds the quota. Always use NewQuota to properly
+// create instances of this struct
+type Quota struct {
+	// limit is the quota, in bytes; unlimited if <= 0
+	limit int64
+
+	mutex    sync.Mutex
+	bytes    int64
+	exceeded bool
+	// next is the id of the next download started
+	next int
+	// active stops the downloads in progress, by their id
+	active map[int]context.CancelFunc
+}
+
+// NewQuota creates a new quota of `limit` bytes, shared by all the downloads started from it.
+//
+// Note: If the `limit` is <= 0, then the bytes are only counted, the quota is never exceeded
+func NewQuota(limit int64) *Quota {
+	return &Quota{limit: limit, active: map[int]context.CancelFunc{}}
+}
+
+// Start returns the context of a new download, done once another download exceeds the quota, and the id the
+// download adds the bytes it reads by, see Add; or false, if the quota is already exceeded, and the download
+// mustn't start. Done must be called with the id, once the download is over
+func (q *Quota) Start(cx context.Context) (context.Context, int, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.exceeded {
+		return nil, 0, false
+	}
+	cx, cancel := context.WithCancel(cx)
+	id := q.next
+	q.next++
+	q.active[id] = cancel
+	return cx, id, true
+}
+
+// Add adds the given bytes, read by the download of the given id, stopping the other downloads once the quota
+// is exceeded
+func (q *Quota) Add(id int, bytes int64) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.bytes += bytes
+	if q.limit <= 0 || q.exceeded || q.bytes < q.limit {
+		return
+	}
+	q.exceeded = true
+	for other, cancel := range q.active {
+		if other != id {
+			cancel()
+		}
+	}
+}
+
+// Done releases the context of the download of the given id, once it's over
+func (q *Quota) Done(id int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if cancel, ok := q.active[id]; ok {
+		cancel()
+		delete(q.active, id)
+	}
+}
+
+// Downloaded returns the bytes read by all the downloads so far
+func (q *Quota) Downloaded() int64 {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.bytes
+}
@@ -0,0 +1,53 @@
+package limitedio
+
+import (
+	"context"
+	"testing"
+)
+
+func TestQuota(t *testing.T) {
+	q := NewQuota(100)
+	first, a, ok := q.Start(context.Background())
+	if !ok {
+		t.Fatalf("expected the first download to start")
+	}
+	second, b, ok := q.Start(context.Background())
+	if !ok || a == b {
+		t.Fatalf("expected the second download to start with an id of its own, got %d and %d", a, b)
+	}
+
+	q.Add(a, 60)
+	if first.Err() != nil || second.Err() != nil {
+		t.Fatalf("expected the downloads to go on within the quota")
+	}
+	// the download that exceeds the quota goes on, the other is stopped
+	q.Add(a, 60)
+	if first.Err() != nil || second.Err() == nil {
+		t.Errorf("expected only the other download to be stopped, got %v and %v", first.Err(), second.Err())
+	}
+	if _, _, ok = q.Start(context.Background()); ok {
+		t.Errorf("expected no new download to start once the quota is exceeded")
+	}
+	if got := q.Downloaded(); got != 120 {
+		t.Errorf("Downloaded() = %d, want 120", got)
+	}
+
+	q.Done(a)
+	q.Done(b)
+	if first.Err() == nil {
+		t.Errorf("expected the context of the download to be released once done")
+	}
+}
+
+func TestQuota_Unlimited(t *testing.T) {
+	q := NewQuota(0)
+	cx, id, ok := q.Start(context.Background())
+	if !ok {
+		t.Fatalf("expected the download to start")
+	}
+	defer q.Done(id)
+	q.Add(id, 1<<40)
+	if _, _, ok = q.Start(context.Background()); !ok || cx.Err() != nil {
+		t.Errorf("expected an unlimited quota never to be exceeded")
+	}
+}
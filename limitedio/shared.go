@@ -0,0 +1,108 @@
+package limitedio
+
+import (
+	"context"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// SharedLimiter is a token bucket, whose bandwidth is shared by all the
+// SGReader instances created from it, such that the total rate of reads across
+// all those readers never exceeds the rate of the limiter; no matter how many of
+// them are actively being read from. Always use NewSharedLimiter to properly
+// create instances of this struct
+type SharedLimiter struct {
+	// warn of unnecessary copy of this struct, instead, use pointers to access an instance
+	_ noCopy
+	// bucket holds the bandwidth shared by all readers
+	*bucket
+	// m locks active, as it is accessed by readers in different goroutines
+	m *sync.Mutex
+	// active keeps the count of readers that have not yet been closed, the
+	// bandwidth is split fairly between them
+	active int
+}
+
+// NewSharedLimiter creates a new limiter whose readers will, together, read
+// upto `rate` bytes per second. `burst` is the maximum number of bytes that may
+// be read at once, and defaults to one second's worth of reads if burst <= 0.
+// The rate may later be changed, even while readers are being read from, see SetRate.
+//
+// Note: If the `rate` is <= 0, then there will not be any speed limiting
+func NewSharedLimiter(rate int32, burst int64) *SharedLimiter {
+	return &SharedLimiter{
+		bucket: newBucket(rate, burst),
+		m:      &sync.Mutex{},
+	}
+}
+
+// NewReader creates a new speed governed reader, whose reads count towards the
+// bandwidth shared by this limiter. The `limit`, if > 0, overrides the
+// bandwidth available to this single reader, such that it never reads faster
+// than `limit` bytes per second, even when there's more shared bandwidth
+// available.
+//
+// Note: panics if the supplied reader is nil
+func (l *SharedLimiter) NewReader(limit int32, reader *io.ReadCloser) *SGReader {
+	r := NewSGReader(limit, reader)
+	r.shared = l
+
+	l.m.Lock()
+	l.active++
+	l.m.Unlock()
+	return r
+}
+
+// reserve reserves upto n bytes to be read; returns the number of bytes
+// reserved, and how long to wait before the reserved bytes may be read. A
+// single reader never reserves more than its fair share of the burst, so that
+// all active readers get to read at about the same rate
+func (l *SharedLimiter) reserve(n int64) (int64, time.Duration) {
+	l.m.Lock()
+	active := int64(max(l.active, 1))
+	l.m.Unlock()
+	return l.bucket.reserve(n, l.maxBurst()/active)
+}
+
+// release unregisters a closed reader, so that the bandwidth is split between
+// the remaining active readers
+func (l *SharedLimiter) release() {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.active = max(l.active-1, 0)
+}
+
+// Follow changes the rate of this limiter, as the time of the day changes,
+// until the given context is done. rateAt returns the rate for the given time
+// of the day; if it returns false, the limiter falls back to the given default rate
+func (l *SharedLimiter) Follow(ctx context.Context, rateAt func(t time.Time) (int32, bool), defaultRate int32) {
+	update := func(t time.Time) {
+		rate, ok := rateAt(t)
+		if !ok {
+			rate = defaultRate
+		}
+		if rate <= 0 {
+			rate = math.MaxInt32
+		}
+		if rate != l.Rate() {
+			l.SetRate(rate)
+		}
+	}
+	update(time.Now())
+
+	go func() {
+		// check every second, so that the rate changes within a second of the schedule moving to the next window
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case t := <-ticker.C:
+				update(t)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
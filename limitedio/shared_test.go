@@ -0,0 +1,168 @@
+package limitedio
+
+import (
+	"context"
+	"io"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// zeroReadCloser is an endless io.ReadCloser of zeroed bytes
+type zeroReadCloser struct{}
+
+func (zeroReadCloser) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func (zeroReadCloser) Close() error {
+	return nil
+}
+
+// readFor reads from the given reader, in chunks of 8KiB, until the duration
+// elapses; returns the total number of bytes read
+func readFor(reader io.Reader, duration time.Duration) (total int64) {
+	buffer := make([]byte, 8*1024)
+	deadline := time.Now().Add(duration)
+	for time.Now().Before(deadline) {
+		n, err := reader.Read(buffer)
+		total += int64(n)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// TestSharedLimiter asserts that concurrent readers of a shared limiter,
+// together, read no faster than the limiter's rate, and that the rate is split
+// fairly between them
+func TestSharedLimiter(t *testing.T) {
+	const rate, burst, readers = 20_000, 2_000, 4
+	const duration = 1500 * time.Millisecond
+	limiter := NewSharedLimiter(rate, burst)
+
+	var wg sync.WaitGroup
+	totals := make([]int64, readers)
+	for i := range readers {
+		var rc io.ReadCloser = zeroReadCloser{}
+		reader := limiter.NewReader(0, &rc)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer reader.Close()
+			totals[i] = readFor(reader, duration)
+		}()
+	}
+	wg.Wait()
+
+	var total int64
+	for _, n := range totals {
+		total += n
+	}
+
+	// we expect the readers to have read the burst, plus `rate` bytes every second
+	expected := burst + int64(rate*duration.Seconds())
+	if total > expected*115/100 {
+		t.Errorf("readers read faster than %d bytes/second -> %d bytes in %v", rate, total, duration)
+	} else if total < expected*85/100 {
+		t.Errorf("readers read slower than %d bytes/second -> %d bytes in %v", rate, total, duration)
+	}
+
+	for i, n := range totals {
+		if share := float64(n) / float64(total); share < 0.15 || share > 0.35 {
+			t.Errorf("reader %d read an unfair share %.2f of the bandwidth, %v", i, share, totals)
+		}
+	}
+}
+
+// TestSharedLimiter_Override asserts that a reader's own limit is honoured,
+// even when there is more shared bandwidth available
+func TestSharedLimiter_Override(t *testing.T) {
+	limiter := NewSharedLimiter(1_000_000, 0)
+	var rc io.ReadCloser = zeroReadCloser{}
+	reader := limiter.NewReader(1_000, &rc)
+	defer reader.Close()
+
+	// the reader can read a burst of 100 bytes immediately, then 1000 more bytes
+	// every second, the last read may block for a tenth of a second longer
+	total := readFor(reader, 1500*time.Millisecond)
+	if total > 1_700 {
+		t.Errorf("reader read faster than its 1000 bytes/second override -> %d bytes", total)
+	}
+}
+
+// TestSharedLimiter_Release asserts that closed readers no longer take a share of the bandwidth
+func TestSharedLimiter_Release(t *testing.T) {
+	limiter := NewSharedLimiter(1_000, 0)
+	var rc io.ReadCloser = zeroReadCloser{}
+	a, b := limiter.NewReader(0, &rc), limiter.NewReader(0, &rc)
+	if limiter.active != 2 {
+		t.Fatalf("expected 2 active readers, got %d", limiter.active)
+	}
+
+	_ = a.Close()
+	_ = b.Close()
+	if limiter.active != 0 {
+		t.Fatalf("expected no active readers, got %d", limiter.active)
+	}
+}
+
+// TestSGReader_SetRate asserts that the rate of a reader can be changed while it is being read from
+func TestSGReader_SetRate(t *testing.T) {
+	var rc io.ReadCloser = zeroReadCloser{}
+	reader := NewSGReader(10_000, &rc)
+	defer reader.Close()
+
+	// read at 10000 bytes/second, then at 40000 bytes/second
+	before := readFor(reader, 500*time.Millisecond)
+	reader.SetRate(40_000)
+	after := readFor(reader, 500*time.Millisecond)
+
+	if before < 4_000 || before > 7_000 {
+		t.Errorf("expected about 5000 bytes read before the rate changed, got %d", before)
+	}
+	if after < 16_000 || after > 24_000 {
+		t.Errorf("expected about 20000 bytes read after the rate changed, got %d", after)
+	}
+}
+
+// TestSharedLimiter_Follow asserts that a limiter follows the rate of the schedule
+func TestSharedLimiter_Follow(t *testing.T) {
+	limiter := NewSharedLimiter(1_000, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rate := int32(5_000)
+	var m sync.Mutex
+	rateAt := func(time.Time) (int32, bool) {
+		m.Lock()
+		defer m.Unlock()
+		return rate, rate >= 0
+	}
+
+	limiter.Follow(ctx, rateAt, 1_000)
+	if got := limiter.Rate(); got != 5_000 {
+		t.Fatalf("expected the limiter to follow the schedule immediately, got rate %d", got)
+	}
+
+	// outside the schedule, the limiter falls back to the default rate
+	m.Lock()
+	rate = -1
+	m.Unlock()
+	time.Sleep(1100 * time.Millisecond)
+	if got := limiter.Rate(); got != 1_000 {
+		t.Fatalf("expected the limiter to fall back to the default rate, got rate %d", got)
+	}
+
+	// a rate of 0 means no rate limiting
+	m.Lock()
+	rate = 0
+	m.Unlock()
+	time.Sleep(1100 * time.Millisecond)
+	if got := limiter.Rate(); got != math.MaxInt32 {
+		t.Fatalf("expected the limiter to stop limiting the rate, got rate %d", got)
+	}
+}
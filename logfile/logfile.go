@@ -0,0 +1,88 @@
+// Package logfile writes the log file of the program, as selected by -o and -a: all the messages to the user,
+// and, with --debug, the debug output of the log package, each line prefixed by the time it was written
+package logfile
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Debug logs the debug output of the downloads, e.g., the links found by the mirror. It is discarded, unless
+// the program is given --debug, such that programs embedding the downloads never see it, unless they ask to
+var Debug = log.New(io.Discard, "debug: ", 0)
+
+// Open opens the named log file for writing, creating it if it doesn't exist. The file is truncated,
+// as with -o, unless appending, as with -a
+func Open(name string, appending bool) (*os.File, error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appending {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	return os.OpenFile(name, flag, 0644)
+}
+
+// Writer writes whole lines to the underlying writer, each prefixed by the time it was written, e.g.,
+//
+//	2024-10-18 10:00:00 HTTP request sent, awaiting response... 200 OK
+//
+// Empty lines are written as is. Each line is written with a single write, such that the lines of concurrent
+// downloads never break one another; the bytes after the last newline are held until their line is complete,
+// or until the Writer is flushed
+type Writer struct {
+	out   io.Writer
+	mutex sync.Mutex
+	// line is the incomplete line, held until its newline is written
+	line []byte
+	// now returns the current time, the lines are prefixed with
+	now func() time.Time
+}
+
+// NewWriter returns a Writer writing the lines to the given writer
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: out, now: time.Now}
+}
+
+// Write writes the complete lines in p, prefixed by the current time, holding any incomplete line
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			if len(w.line) == 0 {
+				w.line = nil
+			}
+			return len(p), nil
+		}
+		if err := w.write(w.line[:i+1]); err != nil {
+			return 0, err
+		}
+		w.line = w.line[i+1:]
+	}
+}
+
+// Flush writes the incomplete line, if any, as a complete line
+func (w *Writer) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.line) == 0 {
+		return nil
+	}
+	line := append(w.line, '\n')
+	w.line = nil
+	return w.write(line)
+}
+
+// write writes the given line, prefixed by the current time unless empty; the caller must hold the mutex
+func (w *Writer) write(line []byte) error {
+	if len(line) > 1 {
+		line = append([]byte(w.now().Format("2006-01-02 15:04:05 ")), line...)
+	}
+	_, err := w.out.Write(line)
+	return err
+}
@@ -0,0 +1,92 @@
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newWriter returns a Writer to the given builder, stamping the lines with a fixed time
+func newWriter(out *strings.Builder) *Writer {
+	w := NewWriter(out)
+	w.now = func() time.Time { return time.Date(2024, 10, 18, 10, 0, 0, 0, time.UTC) }
+	return w
+}
+
+func TestWriter(t *testing.T) {
+	out := &strings.Builder{}
+	w := newWriter(out)
+	_, _ = fmt.Fprintf(w, "Length: 100\nSaving to: ‘a.txt’\n\n")
+	_, _ = fmt.Fprintf(w, "incomplete")
+	if got, want := out.String(), "2024-10-18 10:00:00 Length: 100\n2024-10-18 10:00:00 Saving to: ‘a.txt’\n\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	_, _ = fmt.Fprintf(w, " line\nlast")
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "2024-10-18 10:00:00 Length: 100\n2024-10-18 10:00:00 Saving to: ‘a.txt’\n\n" +
+		"2024-10-18 10:00:00 incomplete line\n2024-10-18 10:00:00 last\n"
+	if got := out.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := w.Flush(); err != nil || out.String() != want {
+		t.Errorf("expected flushing nothing to write nothing, got %q", out.String())
+	}
+}
+
+func TestWriter_Concurrent(t *testing.T) {
+	out := &strings.Builder{}
+	w := newWriter(out)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = fmt.Fprintf(w, "download %d: row %d\n", i, j)
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 800 {
+		t.Fatalf("expected 800 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		var i, j int
+		if n, _ := fmt.Sscanf(line, "2024-10-18 10:00:00 download %d: row %d", &i, &j); n != 2 {
+			t.Errorf("broken line %q", line)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "wget.log")
+	write := func(appending bool, text string) {
+		file, err := Open(name, appending)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if _, err = file.WriteString(text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(false, "first\n")
+	write(true, "appended\n")
+	if data, _ := os.ReadFile(name); string(data) != "first\nappended\n" {
+		t.Errorf("expected -a to append to the log, got %q", data)
+	}
+	write(false, "truncated\n")
+	if data, _ := os.ReadFile(name); string(data) != "truncated\n" {
+		t.Errorf("expected -o to truncate the log, got %q", data)
+	}
+}
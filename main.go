@@ -1,17 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
 	"os"
-	"path"
+	"os/signal"
 	"runtime"
+	"sync"
+	"syscall"
+	"wget/ctx"
+	"wget/daemon"
 	"wget/fileio"
-	"wget/temp"
+	"wget/jobs"
+	"wget/logfile"
+	"wget/progress"
 
 	"wget/args"
-	"wget/downloader"
 	"wget/help"
+	"wget/wget"
 
 	"wget/syscheck"
 	"wget/xerr"
@@ -32,46 +39,89 @@ func main() {
 		return
 	}
 
-	// configure file logging to temporary application logger file
-	logger, err := os.OpenFile(path.Join(temp.Dir(), "logger.log"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		log.Printf("failed to setup file logging: logging to stderr instead: %v\n", err)
-	}
-	log.SetOutput(logger)
-	defer fileio.Close(logger)
-
 	// check command-line args and download the defined files
 	ctx := args.DownloadContext(arguments)
-	log.Printf("Args context: %#v\n", ctx)
-	// Check for invalid commandline args combinations
-	{
-		die := func(msg string) {
-			xerr.WriteError(
-				msg,
-				1,
-				true)
-		}
+	closeLog := setupLog(ctx)
+	defer closeLog()
+	logfile.Debug.Printf("Args context: %#v\n", ctx)
 
-		if ctx.ConvertLinks && !ctx.Mirror {
-			die("bad format: option --convert-links is on but --mirror is off")
-			return
-		}
+	// the first interrupt stops the downloads gracefully, keeping the partial files for --continue
+	cx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go interrupt(signals, cancel, os.Exit)
 
-		if (len(ctx.Exclude) != 0 || len(ctx.Rejects) != 0) && !ctx.Mirror {
-			die("bad format: options [--exclude short hand -X; --reject short hand -R] " +
-				"are only valid in --mirror mode")
-			return
-		}
+	// a background process started by -B records its progress for --jobs
+	job, err := jobs.Current()
+	if err != nil {
+		xerr.WriteError(fmt.Sprintf("failed to record the background job: %v", err), 1, false)
+	}
+	var track func(progress.Reporter) progress.Reporter
+	if job != nil {
+		track = job.Reporter
+	}
 
-		if ctx.Mirror && ctx.OutputFile != "" {
-			die("bad format: option --mirror with -O specified is ambiguous")
-			return
-		}
+	if ctx.Daemon {
+		err = daemon.Run(cx, ctx)
+	} else {
+		err = wget.Run(cx, ctx, track)
+	}
+	// once interrupted, the program exits with the status of the signal, see xerr.SignalError
+	err = xerr.Interrupted(err, context.Cause(cx))
+	if job != nil {
+		_ = job.Finish(err)
+	}
+	closeLog()
+	if err != nil {
+		// exit with the status of the most severe failure, as GNU Wget does
+		os.Exit(xerr.ExitStatus(err))
+	}
+}
+
+// setupLog writes the messages to the user to the log file given to -o, or -a, if any; and the debug output,
+// with --debug, to the log file, or stderr. Returns the function that flushes, and closes, the log file
+func setupLog(c ctx.Context) (closeLog func()) {
+	closeLog = func() {}
+	debug := io.Discard
+	if c.Debug {
+		debug = logfile.NewWriter(os.Stderr)
+	}
 
-		if len(ctx.Links) > 1 && ctx.OutputFile != "" {
-			die("bad format: many URLs to download but -O is specified, this is ambiguous")
+	if c.LogFile != "" {
+		file, err := logfile.Open(c.LogFile, c.AppendOutput)
+		if err != nil {
+			xerr.WriteError(fmt.Sprintf("failed to open the log file: %v", err), 1, true)
 			return
 		}
+		w := logfile.NewWriter(file)
+		syscheck.Out = w
+		if c.Debug {
+			debug = w
+		}
+		closeLog = sync.OnceFunc(func() {
+			_ = w.Flush()
+			fileio.Close(file)
+		})
 	}
-	downloader.Get(ctx)
+
+	// the lines are already prefixed by the time they were written
+	logfile.Debug.SetOutput(debug)
+	return
+}
+
+// interrupt waits for SIGINT or SIGTERM on the given channel, then calls stop with the signal, see xerr.SignalError,
+// to stop starting new downloads, and to checkpoint the active downloads. A second signal aborts the program
+// immediately, calling exit with the exit status of the signal, i.e., 128 + its number
+func interrupt(signals <-chan os.Signal, stop context.CancelCauseFunc, exit func(int)) {
+	received := <-signals
+	// the downloads may take a moment to stop, restore the terminal right away
+	syscheck.ShowCursor()
+	_, _ = fmt.Fprintln(os.Stderr, "\ninterrupted: stopping the downloads, interrupt again to abort immediately")
+	stop(&xerr.SignalError{Signal: received})
+
+	received = <-signals
+	syscheck.ShowCursor()
+	_, _ = fmt.Fprintln(os.Stderr, "\naborted")
+	exit((&xerr.SignalError{Signal: received}).Status())
 }
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"wget/syscheck"
+	"wget/xerr"
+)
+
+func TestInterrupt(t *testing.T) {
+	defer func(out io.Writer) { syscheck.Out = out }(syscheck.Out)
+	syscheck.Out = io.Discard
+
+	signals := make(chan os.Signal, 2)
+	cx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	exited := make(chan int, 1)
+	go interrupt(signals, cancel, func(status int) { exited <- status })
+
+	// the first signal stops the downloads gracefully, the program then exits with the status of the signal
+	signals <- syscall.SIGTERM
+	<-cx.Done()
+	err := xerr.Interrupted(fmt.Errorf("download interrupted: %w", cx.Err()), context.Cause(cx))
+	if status := xerr.ExitStatus(err); status != 128+int(syscall.SIGTERM) {
+		t.Errorf("expected the graceful stop to exit with the status of SIGTERM, got %d", status)
+	}
+
+	// the second signal aborts the program right away
+	signals <- os.Interrupt
+	if status := <-exited; status != xerr.InterruptedStatus {
+		t.Errorf("expected the program to be aborted with the status of SIGINT, got %d", status)
+	}
+}
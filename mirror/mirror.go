@@ -2,16 +2,17 @@
 package mirror
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"golang.org/x/net/html"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"sync"
 	"time"
 	"wget/convertlinks"
@@ -20,15 +21,20 @@ import (
 	"wget/fileio"
 	"wget/globals"
 	"wget/httpx"
+	"wget/limitedio"
+	"wget/logfile"
 	"wget/mirror/links"
 	"wget/mirror/xurl"
-	"wget/syscheck"
+	"wget/pace"
+	"wget/progress"
 	"wget/temp"
+	"wget/xerr"
 )
 
 // map of content types to preferred file extensions
 var contentTypeExtensions = map[string]string{
 	"text/html":              "html",
+	"application/xhtml+xml":  "html",
 	"text/plain":             "txt",
 	"text/css":               "css",
 	"text/javascript":        "js",
@@ -65,12 +71,35 @@ type arg struct {
 	rejectPatterns []*regexp.Regexp
 	// excludePatterns keeps a list of regex patterns to match directories to be rejected for download
 	excludePatterns []*regexp.Regexp
-	// d keeps the download id of the current mirror URL. Since, we are downloading
-	// recursively, this integer keeps the number of mirrored URLS, thus, can be used
-	// to assign unique integer IDs to each downloads
+	// d keeps the number of mirrored URLs, counted as their downloads start. The downloads are
+	// numbered across the mirrors of all the websites of the run instead, see Shared
 	d int
-	// df records the total number of bytes downloaded in the process of this mirror
+	// df records the total number of bytes downloaded in the process of this mirror, not counting those of the
+	// partial files continued
 	df int64
+	// quotaExceeded records whether some downloads were not started, as the --quota was exceeded, see Shared
+	quotaExceeded bool
+	// naming decides the paths the mirrored URLs are saved to
+	naming Naming
+	// policy decides what happens to existing files, when downloads are saved under their names
+	policy fileio.Policy
+	// paths maps the paths claimed by the mirrored URLs to the URLs, such that different URLs, saved to
+	// the same path as their directories are omitted, e.g., by --no-directories, are told apart
+	paths map[string]string
+	// noSpace records that the mirror was stopped, as the disk space fell below --min-free-space
+	noSpace bool
+	// tooLarge counts the files that were not downloaded, as they were larger than --max-filesize
+	tooLarge int
+	// limiter limits the total bandwidth of the mirror, as defined by --rate-limit
+	limiter *limitedio.SharedLimiter
+	// pacer paces the requests sent to the mirrored host, as defined by --wait and --random-wait
+	pacer *pace.Pacer
+	// reporter reports the progress of the downloads, as selected by --progress, --quiet, etc.
+	reporter progress.Reporter
+	// shared is shared with the mirrors of the other websites of the run, see SiteWith
+	shared *Shared
+	// failures keeps the errors of the linked URLs that failed to be mirrored, see arg.fail
+	failures []error
 }
 
 // UrlDownloadInfo keeps the results of downloading a given URL,
@@ -84,14 +113,79 @@ type UrlDownloadInfo struct {
 
 var ErrFileAlreadyDownloaded = errors.New("file already downloaded")
 
+// Shared is shared by the mirrors of all the websites of a run, one after the other, such that the run is
+// reported as a whole, see SiteWith
+type Shared struct {
+	// Reporter reports the progress of the downloads of all the mirrors. It is started before the first mirror,
+	// and finished after the last one, by the caller
+	Reporter progress.Reporter
+	// Limiter limits the total bandwidth of all the mirrors, as defined by --rate-limit
+	Limiter *limitedio.SharedLimiter
+	// Pacer paces the requests sent to the mirrored hosts, as defined by --wait and --random-wait
+	Pacer *pace.Pacer
+	// Quota keeps the bytes downloaded by all the mirrors against the --quota
+	Quota *limitedio.Quota
+	// n numbers the downloads of all the mirrors, as given to the Reporter
+	n int
+}
+
 // Site downloads the entire website being possible to use "part" of the website offline.
-// If no scheme is detected in the mirror URL, then, the HTTP scheme is assumed
-func Site(cxt ctx.Context, mirrorUrl string) error {
+// If no scheme is detected in the mirror URL, then, the HTTP scheme is assumed.
+// Once the given context is done, no new downloads are started, the active download is stopped,
+// keeping its partial file, and the returned error wraps the context's error
+func Site(cx context.Context, cxt ctx.Context, mirrorUrl string) error {
+	reporter := progress.New(progress.Options{
+		Format:         cxt.OutputFormat,
+		Verbosity:      cxt.Verbosity,
+		Progress:       cxt.Progress,
+		Log:            cxt.LogFile != "",
+		ServerResponse: cxt.ServerResponse,
+	})
+	shared := &Shared{
+		Reporter: reporter,
+		Limiter:  limitedio.NewSharedLimiter(int32(cxt.RateLimitValue), cxt.RateBurstValue),
+		Pacer:    pace.New(cxt.Wait, cxt.RandomWait),
+		Quota:    limitedio.NewQuota(cxt.QuotaValue),
+	}
+	if len(cxt.RateScheduleValue) != 0 {
+		// change the rate limit as the schedule moves to the next time window
+		stop, cancel := context.WithCancel(cx)
+		defer cancel()
+		rateAt := func(t time.Time) (int32, bool) {
+			rate, ok := cxt.RateScheduleValue.RateAt(t)
+			return int32(rate), ok
+		}
+		shared.Limiter.Follow(stop, rateAt, int32(cxt.RateLimitValue))
+	}
+	reporter.Start()
+	defer reporter.Finish()
+	return SiteWith(cx, cxt, mirrorUrl, shared)
+}
+
+// SiteWith mirrors the website as Site does, but with the reporter, the rate limit, the pacing, and the quota of
+// the given Shared, rather than those defined by the download context. The reporter is neither started, nor finished
+func SiteWith(cx context.Context, cxt ctx.Context, mirrorUrl string, shared *Shared) error {
 	m := &arg{
 		Context:         &cxt,
 		downloaded:      make(map[string]bool),
+		paths:           make(map[string]string),
 		mutex:           &sync.Mutex{},
 		urlDownloadInfo: make(map[string]UrlDownloadInfo),
+		limiter:         shared.Limiter,
+		pacer:           shared.Pacer,
+		reporter:        shared.Reporter,
+		shared:          shared,
+		// unless specified, mirroring a website again updates the existing files
+		policy: fileio.NewPolicy(cxt.Clobber, cxt.Backups, fileio.Overwrite),
+		naming: Naming{
+			Restrict:            cxt.RestrictFileNamesValue,
+			AdjustExtension:     cxt.AdjustExtension,
+			IgnoreQueryParams:   cxt.IgnoreQueryParams,
+			NoDirectories:       cxt.NoDirectories,
+			NoHostDirectories:   cxt.NoHostDirectories,
+			ProtocolDirectories: cxt.ProtocolDirectories,
+			CutDirs:             cxt.CutDirs,
+		},
 	}
 	parse, err := url.Parse(mirrorUrl)
 	if err != nil {
@@ -104,17 +198,13 @@ func Site(cxt ctx.Context, mirrorUrl string) error {
 		parse.Scheme = "http"
 	}
 
-	syscheck.MoveCursor(1)
-	syscheck.ClearScreen()
-	syscheck.HideCursor()
-	defer syscheck.ShowCursor() // Ensure cursor is shown again when done
-
 	m.init()
+
 	startTime := time.Now()
-	_, err = m.Site(parse.String())
+	_, err = m.Site(cx, parse.String())
 	endTime := time.Now()
 	duration := endTime.Sub(startTime).Truncate(time.Second)
-	fmt.Printf("\n\nFINISHED --%s--\n"+
+	m.reporter.Printf("\n\nFINISHED --%s--\n"+
 		"Total wall clock time: %s\n"+
 		"Downloaded: %d files, %s in %s\n",
 		time.Now().Format("2006-01-02 15:04:05"),
@@ -123,7 +213,31 @@ func Site(cxt ctx.Context, mirrorUrl string) error {
 		globals.FormatSize(m.df),
 		duration.String(),
 	)
-	return err
+
+	if cx.Err() != nil {
+		m.reporter.Printf("Interrupted: the mirror is incomplete, run again with --continue to pick up where it stopped\n")
+		return fmt.Errorf("mirror interrupted: %w", cx.Err())
+	}
+	if m.tooLarge > 0 {
+		m.reporter.Printf("Skipped: %d files larger than %s\n", m.tooLarge, globals.FormatSize(cxt.MaxFileSizeValue))
+	}
+
+	// the program exits with the status of the most severe failure, see xerr.ExitStatus
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, m.failures...)
+	if m.noSpace {
+		m.reporter.Printf("Stopped: less than %s of free disk space left\n", globals.FormatSize(cxt.MinFreeSpaceValue))
+		errs = append(errs, fmt.Errorf("%w: downloaded %s", xerr.ErrInsufficientSpace, globals.FormatSize(m.df)))
+	} else if m.quotaExceeded {
+		m.reporter.Printf("Download quota of %s EXCEEDED!\n", globals.FormatSize(cxt.QuotaValue))
+		errs = append(errs, fmt.Errorf("%w: downloaded %s", xerr.ErrQuotaExceeded, globals.FormatSize(m.df)))
+	} else if m.tooLarge > 0 && err == nil {
+		errs = append(errs, fmt.Errorf("%w: skipped %d files", xerr.ErrFileTooLarge, m.tooLarge))
+	}
+	return xerr.Join(fmt.Sprintf("%d pages of %s failed", len(m.failures), mirrorUrl), errs...)
 }
 
 // init is called once, when the struct instance is created, to initialize various fields to their usable values
@@ -132,16 +246,63 @@ func (a *arg) init() {
 	a.initExclude()
 }
 
+// fail records the failure to mirror the given linked URL, unless it was merely skipped, e.g., as it was mirrored
+// already, or rejected; or unless it is summed up by the mirror anyway, e.g., as the --quota was exceeded
+func (a *arg) fail(linkUrl string, err error) {
+	for _, skipped := range []error{
+		ErrFileAlreadyDownloaded, fetch.ErrSkipped, fileio.ErrFileExists,
+		xerr.ErrQuotaExceeded, xerr.ErrInsufficientSpace, xerr.ErrFileTooLarge, context.Canceled,
+	} {
+		if errors.Is(err, skipped) {
+			return
+		}
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.failures = append(a.failures, fmt.Errorf("%s: %w", linkUrl, err))
+}
+
 // GetFile returns a writable file, where the downloaded file will be written into,
 // or an error if it fails. GetFile honours the current download context as specified by this instance
-func (a *arg) GetFile(downloadUrl string, header http.Header) (*os.File, error) {
-	downloadPath := GetFile(downloadUrl, header, a.SavePath)
-	log.Printf("downloading url %q -> %q\n", downloadUrl, downloadPath)
-	err := ForceMkdirAll(downloadPath)
+func (a *arg) GetFile(downloadUrl string, header http.Header) (io.WriteCloser, error) {
+	downloadPath, err := a.policy.Resolve(a.Path(downloadUrl, header))
 	if err != nil {
 		return nil, err
 	}
-	return os.OpenFile(downloadPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
+	logfile.Debug.Printf("downloading url %q -> %q\n", downloadUrl, downloadPath)
+	err = ForceMkdirAll(downloadPath)
+	if err != nil {
+		return nil, err
+	}
+	return fileio.OpenPart(downloadPath)
+}
+
+// ResumeFrom returns the size of the partial file left behind by an interrupted download of the given URL,
+// at the path of the URL, and its headers, as the file would be saved by GetFile
+func (a *arg) ResumeFrom(downloadUrl string, header http.Header) int64 {
+	return fileio.PartSize(a.Path(downloadUrl, header))
+}
+
+// Path returns the path, within the save path, where the contents of the given URL are saved, see Naming.Path.
+// If the path was claimed by a different URL already, as the naming merges the paths of different URLs, the
+// path is numbered, e.g., index(1).html, such that the contents of the other URL aren't overwritten
+func (a *arg) Path(downloadUrl string, header http.Header) string {
+	name := a.naming.Path(downloadUrl, header, a.SavePath)
+	if !a.naming.Merges() || name == "" {
+		return name
+	}
+
+	canonical := a.naming.Canonical(downloadUrl)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	claimed := name
+	for n := 1; ; n++ {
+		if owner, ok := a.paths[claimed]; !ok || owner == canonical {
+			a.paths[claimed] = canonical
+			return claimed
+		}
+		claimed = fileio.NumberedName(name, n)
+	}
 }
 
 // ShouldDownload will be called to validate whether the file from the given url
@@ -168,9 +329,14 @@ func (a *arg) ShouldDownload(mirrorUrl string, header http.Header) bool {
 // Site downloads the entire website being possible to use "part" of the website offline,
 // respecting the download context defined by the given instance.
 // If no scheme is detected in the mirror URL, then, the HTTP scheme is assumed
-func (a *arg) Site(mirrorUrl string) (info fetch.FileInfo, err error) {
-	log.Printf("[1] Fetching >> %q\n", mirrorUrl)
-	defer log.Printf("[1] Done\n")
+func (a *arg) Site(cx context.Context, mirrorUrl string) (info fetch.FileInfo, err error) {
+	// the same resource may be linked to by different URLs, e.g., with fragments, or ignored query parameters
+	mirrorUrl = a.naming.Canonical(mirrorUrl)
+	logfile.Debug.Printf("[1] Fetching >> %q\n", mirrorUrl)
+	defer logfile.Debug.Printf("[1] Done\n")
+	// dcx is the context of the download, done once another download exceeds the quota, see Shared
+	var dcx context.Context
+	var quotaId int
 	// check if the given URL has already been downloaded by this instance
 	err = func() error {
 		a.mutex.Lock()
@@ -179,6 +345,20 @@ func (a *arg) Site(mirrorUrl string) (info fetch.FileInfo, err error) {
 			// skip, already downloaded or in the download queue
 			return ErrFileAlreadyDownloaded
 		}
+		if a.noSpace {
+			// the disk is (almost) full, don't start any new downloads
+			return xerr.ErrInsufficientSpace
+		}
+		if cx.Err() != nil {
+			// the mirror was interrupted, don't start any new downloads
+			return cx.Err()
+		}
+		var ok bool
+		if dcx, quotaId, ok = a.shared.Quota.Start(cx); !ok {
+			// don't start any new downloads once the quota is exceeded
+			a.quotaExceeded = true
+			return xerr.ErrQuotaExceeded
+		}
 		a.downloaded[mirrorUrl] = true
 		return nil
 	}()
@@ -187,30 +367,33 @@ func (a *arg) Site(mirrorUrl string) (info fetch.FileInfo, err error) {
 		return
 	}
 
-	// define a download status listener for the current mirror URL
-	status := fetch.DownloadStatus{}
-	status.OnUpdate = func(status *fetch.DownloadStatus, hint int) {
-		// whenever the status of this download has changed, we print the progress to its
-		// respective position in the terminal
-		globals.PrintLines(
-			(a.d-1)*8, []string{
-				status.Start,
-				status.Status,
-				status.ContentLength,
-				status.SavePath,
-				status.Progress,
-				status.Finished,
-			},
-		)
-	}
-
-	// configure an Advanced Progress Listener for the GET request
-	advancedProgressListener := *status.ProgressListener()
+	// configure an Advanced Progress Listener for the GET request. The mirrors download one URL at
+	// a time, thus, this download is numbered after those of all the mirrors, counted once it actually starts
+	advancedProgressListener := a.reporter.Listener(a.shared.n, mirrorUrl)
+	// downloaded keeps the bytes downloaded, added to the quota as they're read, and to the total once the
+	// download finishes; offset keeps those of the partial file continued, read by an earlier run
+	var downloaded, offset int64
+	{
+		originalOnResume := advancedProgressListener.OnResume
+		advancedProgressListener.OnResume = func(resumed int64) {
+			downloaded, offset = resumed, resumed
+			originalOnResume(resumed)
+		}
+		originalOnProgress := advancedProgressListener.OnProgress
+		advancedProgressListener.OnProgress = func(bytes, total int64, rate int32) {
+			if rate < 0 {
+				a.shared.Quota.Add(quotaId, bytes-downloaded)
+				downloaded = bytes
+			}
+			originalOnProgress(bytes, total, rate)
+		}
+	}
 	{
 		// need to increment the ID when the download actually starts, inject an onstart listener
 		originalOnStart := advancedProgressListener.OnStart
 		advancedProgressListener.OnStart = func(time time.Time) {
 			a.d++
+			a.shared.n++
 			originalOnStart(time)
 		}
 	}
@@ -220,25 +403,63 @@ func (a *arg) Site(mirrorUrl string) (info fetch.FileInfo, err error) {
 		advancedProgressListener.OnDownloadFinished = func(url string, time time.Time) {
 			originalOnDownloadFinished(url, time)
 			a.mutex.Lock()
-			a.df += status.Downloaded
+			a.df += downloaded - offset
 			a.mutex.Unlock()
 		}
 	}
 
+	var resumeFrom func(url string, header http.Header) int64
+	if a.Continue {
+		resumeFrom = a.ResumeFrom
+	}
 	info, err = fetch.URL(
+		dcx,
 		mirrorUrl,
 		fetch.Config{
 			GetFile:                  a.GetFile,
+			ResumeFrom:               resumeFrom,
+			KeepPartial:              a.KeepPartial || a.Continue,
 			ShouldDownload:           a.ShouldDownload,
-			Limit:                    int32(a.RateLimitValue),
+			Limit:                    int32(a.FileRateLimitValue),
+			Limiter:                  a.limiter,
+			MaxFileSize:              a.MaxFileSizeValue,
+			MinFreeSpace:             a.MinFreeSpaceValue,
+			Pacer:                    a.pacer,
 			ProgressListener:         nil,
 			RateListener:             nil,
 			Body:                     nil,
 			Method:                   "GET",
 			AllowedStatusCodes:       []int{http.StatusOK},
+			SaveHeaders:              a.SaveHeaders == fetch.HeadersInline,
 			AdvancedProgressListener: advancedProgressListener,
 		},
 	)
+	if err != nil && cx.Err() == nil && dcx.Err() != nil {
+		// another download, e.g., of a concurrent mirror, exceeded the quota; the partial file is kept for --continue
+		a.mutex.Lock()
+		a.quotaExceeded = true
+		a.mutex.Unlock()
+		err = fmt.Errorf("stopped: %w", xerr.ErrQuotaExceeded)
+	}
+	a.shared.Quota.Done(quotaId)
+	if errors.Is(err, xerr.ErrFileTooLarge) {
+		a.mutex.Lock()
+		a.tooLarge++
+		a.mutex.Unlock()
+	}
+	if errors.Is(err, xerr.ErrInsufficientSpace) && a.MinFreeSpaceValue > 0 {
+		// the --min-free-space margin applies to the whole mirror, stop mirroring
+		a.mutex.Lock()
+		a.noSpace = true
+		a.mutex.Unlock()
+	}
+	// with --no-clobber, the existing file is kept, but its links are still followed
+	existing := errors.Is(err, fileio.ErrFileExists)
+	if existing {
+		logfile.Debug.Printf("keeping existing file of url %q\n", mirrorUrl)
+		info.Name = a.Path(mirrorUrl, info.Headers)
+		err = nil
+	}
 	if err != nil {
 		return
 	}
@@ -250,15 +471,19 @@ func (a *arg) Site(mirrorUrl string) (info fetch.FileInfo, err error) {
 		error:    err,
 	}
 
-	if !a.ShouldDownload(mirrorUrl, http.Header{}) {
+	if !existing && !a.ShouldDownload(mirrorUrl, http.Header{}) {
 		defer func(name string) {
 			_ = os.Remove(name)
 		}(info.Name)
+	} else if !existing && a.SaveHeaders == fetch.HeadersSidecar {
+		if err := info.SaveHeaders(); err != nil {
+			logfile.Debug.Printf("failed to save the headers of url %q: %v\n", mirrorUrl, err)
+		}
 	}
 
 	contentType := httpx.ExtractMimeType(info.Headers)
 	if contentType == "text/css" {
-		a.FetchCss(mirrorUrl, info.Name)
+		a.FetchCss(cx, mirrorUrl, info.Name)
 		return
 	} else if contentType != "text/html" {
 		// Not a html file, done downloading
@@ -278,7 +503,7 @@ func (a *arg) Site(mirrorUrl string) (info fetch.FileInfo, err error) {
 	}
 
 	linkedUrls := links.FromHtml(doc)
-	log.Printf("Found linkedUrls: %v\n", linkedUrls)
+	logfile.Debug.Printf("Found linkedUrls: %v\n", linkedUrls)
 	if len(linkedUrls) == 0 {
 		// No more links to download
 		return
@@ -289,28 +514,29 @@ func (a *arg) Site(mirrorUrl string) (info fetch.FileInfo, err error) {
 	for _, link := range linkedUrls {
 		linkUrl, err := xurl.AbsoluteUrl(mirrorUrl, link)
 		if err != nil {
-			log.Println(err)
+			logfile.Debug.Println(err)
 			continue
 		} else if !xurl.SameHost(mirrorUrl, linkUrl) {
 			continue
 		}
 
-		linkInfo, err := a.Site(linkUrl)
-		if _, ok := a.urlDownloadInfo[link]; errors.Is(err, ErrFileAlreadyDownloaded) && ok {
-			linkInfo = a.urlDownloadInfo[link].FileInfo
+		linkInfo, err := a.Site(cx, linkUrl)
+		if downloaded, ok := a.urlDownloadInfo[a.naming.Canonical(linkUrl)]; errors.Is(err, ErrFileAlreadyDownloaded) && ok {
+			linkInfo = downloaded.FileInfo
 			err = nil
 		}
 
 		if err != nil {
-			log.Println(err)
+			logfile.Debug.Println(err)
+			a.fail(linkUrl, err)
 		} else {
-			log.Printf("saved to -> %s\n", linkInfo.Name)
-			log.Printf("parent: %s -> relative: %s\n", info.Name, linkInfo.Name)
-			convertUrls[link], _ = relativePath(info.Name, linkInfo.Name)
+			logfile.Debug.Printf("saved to -> %s\n", linkInfo.Name)
+			logfile.Debug.Printf("parent: %s -> relative: %s\n", info.Name, linkInfo.Name)
+			convertUrls[link], _ = localLink(info.Name, linkInfo.Name, linkUrl)
 		}
 	}
 
-	log.Printf("Converter Map >> %v\n", convertUrls)
+	logfile.Debug.Printf("Converter Map >> %v\n", convertUrls)
 	convertLinks := func() {
 		linkConverter := func(url string, isA bool) string {
 			if toUrl, ok := convertUrls[url]; ok {
@@ -323,14 +549,14 @@ func (a *arg) Site(mirrorUrl string) (info fetch.FileInfo, err error) {
 		// Write the new doc `html.Node` to a new temporary file
 		convertHtmlFile, err := temp.File()
 		if err != nil {
-			log.Println(err)
+			logfile.Debug.Println(err)
 			return
 		}
 		defer fileio.Close(convertHtmlFile)
 
 		err = html.Render(convertHtmlFile, doc)
 		if err != nil {
-			log.Println(err)
+			logfile.Debug.Println(err)
 			return
 		}
 
@@ -340,7 +566,7 @@ func (a *arg) Site(mirrorUrl string) (info fetch.FileInfo, err error) {
 		fileio.Close(convertHtmlFile)
 		err = os.Rename(convertHtmlFile.Name(), htmlFile.Name())
 		if err != nil {
-			log.Println(err)
+			logfile.Debug.Println(err)
 			return
 		}
 	}
@@ -355,7 +581,7 @@ func (a *arg) Site(mirrorUrl string) (info fetch.FileInfo, err error) {
 // FetchCss assumes the file of the given filename, is a CSS file and thus,
 // extracts all linked URLs, downloads the linked resources, then optionally
 // converting the links defined in the CSS file to local filesystem based paths
-func (a *arg) FetchCss(mirrorUrl, fileName string) {
+func (a *arg) FetchCss(cx context.Context, mirrorUrl, fileName string) {
 	// the downloaded file is CSS; attempt to extract linked url resources
 	cssFile, err := os.Open(fileName)
 	if err != nil {
@@ -372,27 +598,28 @@ func (a *arg) FetchCss(mirrorUrl, fileName string) {
 	for _, link := range linkedUrls {
 		linkedUrl, err := xurl.AbsoluteUrl(mirrorUrl, link)
 		if err != nil {
-			log.Println(err)
+			logfile.Debug.Println(err)
 			continue
 		} else if !xurl.SameHost(mirrorUrl, linkedUrl) {
 			continue
 		}
 
-		linkInfo, err := a.Site(linkedUrl)
-		if _, ok := a.urlDownloadInfo[linkedUrl]; errors.Is(err, ErrFileAlreadyDownloaded) && ok {
-			linkInfo = a.urlDownloadInfo[linkedUrl].FileInfo
+		linkInfo, err := a.Site(cx, linkedUrl)
+		if downloaded, ok := a.urlDownloadInfo[a.naming.Canonical(linkedUrl)]; errors.Is(err, ErrFileAlreadyDownloaded) && ok {
+			linkInfo = downloaded.FileInfo
 			err = nil
 		}
 
 		if err != nil {
-			log.Println(err)
+			logfile.Debug.Println(err)
+			a.fail(linkedUrl, err)
 			continue
 		}
-		log.Printf("saved to -> %s\n", linkInfo.Name)
-		log.Printf("parent: %s -> relative: %s\n", fileName, linkInfo.Name)
+		logfile.Debug.Printf("saved to -> %s\n", linkInfo.Name)
+		logfile.Debug.Printf("parent: %s -> relative: %s\n", fileName, linkInfo.Name)
 
 		if a.ConvertLinks {
-			convertUrls[linkedUrl], _ = relativePath(fileName, linkInfo.Name)
+			convertUrls[linkedUrl], _ = localLink(fileName, linkInfo.Name, linkedUrl)
 		}
 	}
 
@@ -409,14 +636,14 @@ func (a *arg) FetchCss(mirrorUrl, fileName string) {
 		// write the new CSS to a new temporary file
 		convertCssFile, err := temp.File()
 		if err != nil {
-			log.Println(err)
+			logfile.Debug.Println(err)
 			return
 		}
 		defer fileio.Close(convertCssFile)
 
 		_, err = convertCssFile.WriteString(newCss)
 		if err != nil {
-			log.Println(err)
+			logfile.Debug.Println(err)
 			return
 		}
 
@@ -426,7 +653,7 @@ func (a *arg) FetchCss(mirrorUrl, fileName string) {
 		fileio.Close(convertCssFile)
 		err = os.Rename(convertCssFile.Name(), cssFile.Name())
 		if err != nil {
-			log.Println(err)
+			logfile.Debug.Println(err)
 			return
 		}
 	}
@@ -457,3 +684,28 @@ func relativePath(file1, file2 string) (string, error) {
 
 	return relPath, nil
 }
+
+// localLink returns the link to the local file `to`, relative to the local file `from`, where `to` holds the
+// contents of the given URL. Each element of the link is percent-escaped, such that names containing `?`, `#` or `%`
+// are not mistaken for a query or fragment, and the fragment of the URL, if any, is kept
+func localLink(from, to, linkUrl string) (string, error) {
+	relPath, err := relativePath(from, to)
+	if err != nil {
+		return "", err
+	}
+
+	elements := strings.Split(filepath.ToSlash(relPath), "/")
+	for i, element := range elements {
+		elements[i] = url.PathEscape(element)
+	}
+	link := strings.Join(elements, "/")
+	if first, _, _ := strings.Cut(link, "/"); strings.Contains(first, ":") {
+		// the link would be mistaken for an absolute URL, of the scheme before the `:`
+		link = "./" + link
+	}
+
+	if u, err := url.Parse(linkUrl); err == nil && u.Fragment != "" {
+		link += "#" + u.EscapedFragment()
+	}
+	return link, nil
+}
@@ -0,0 +1,371 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"wget/ctx"
+	"wget/progress"
+	"wget/xerr"
+)
+
+func TestSite_Clobber(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/":
+					w.Header().Set("Content-Type", "text/html")
+					_, _ = fmt.Fprint(w, `<html><body><a href="/a.txt">a</a></body></html>`)
+				case "/a.txt":
+					w.Header().Set("Content-Type", "text/plain")
+					_, _ = fmt.Fprint(w, "new")
+				default:
+					http.NotFound(w, r)
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	read := func(name string) string {
+		contents, _ := os.ReadFile(name)
+		return string(contents)
+	}
+
+	tests := []struct {
+		clobber string
+		backups int
+		// want maps the files expected in the host's directory to their contents
+		want map[string]string
+	}{
+		{"", 0, map[string]string{"a.txt": "new"}},
+		{"overwrite", 0, map[string]string{"a.txt": "new"}},
+		{"no-clobber", 0, map[string]string{"a.txt": "old"}},
+		{"backups", 1, map[string]string{"a.txt": "new", "a.txt.1": "old"}},
+		{"unique-names", 0, map[string]string{"a.txt": "old", "a(1).txt": "new"}},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.clobber, func(t *testing.T) {
+				savePath := t.TempDir()
+				hostDir := filepath.Join(savePath, "127.0.0.1")
+				if err := os.MkdirAll(hostDir, 0775); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(hostDir, "a.txt"), []byte("old"), 0664); err != nil {
+					t.Fatal(err)
+				}
+
+				cxt := ctx.Context{SavePath: savePath, Clobber: tt.clobber, Backups: tt.backups}
+				if err := Site(context.Background(), cxt, server.URL); err != nil {
+					t.Fatalf("Site() error = %v", err)
+				}
+
+				for name, contents := range tt.want {
+					if got := read(filepath.Join(hostDir, name)); got != contents {
+						t.Errorf("expected %s to hold %q, got %q", name, contents, got)
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestSite_QueryStrings(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				requests = append(requests, r.URL.RequestURI())
+				switch r.URL.Path {
+				case "/":
+					w.Header().Set("Content-Type", "text/html")
+					_, _ = fmt.Fprint(
+						w, `<html><body>`+
+							`<a href="/page.php?id=1">1</a>`+
+							`<a href="/page.php?id=2">2</a>`+
+							`<a href="/page.php?id=1&utm_source=mail#top">1</a>`+
+							`<a href="/page.php?q=why?">why</a>`+
+							`<a href="/c%23.txt">c#</a>`+
+							`</body></html>`,
+					)
+				default:
+					w.Header().Set("Content-Type", "text/plain")
+					_, _ = fmt.Fprint(w, r.URL.RawQuery)
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	savePath := t.TempDir()
+	cxt := ctx.Context{SavePath: savePath, ConvertLinks: true, IgnoreQueryParams: []string{"utm_*"}}
+	if err := Site(context.Background(), cxt, server.URL); err != nil {
+		t.Fatalf("Site() error = %v", err)
+	}
+
+	hostDir := filepath.Join(savePath, "127.0.0.1")
+	want := map[string]string{
+		"page.php@id=1":   "id=1",
+		"page.php@id=2":   "id=2",
+		"page.php@q=why?": "q=why?",
+		"c#.txt":          "",
+	}
+	for name, contents := range want {
+		if got, err := os.ReadFile(filepath.Join(hostDir, name)); err != nil || string(got) != contents {
+			t.Errorf("expected %s to hold %q, got %q, %v", name, contents, got, err)
+		}
+	}
+
+	// the tracking parameters were ignored, thus, the page was requested once
+	for _, request := range requests {
+		if strings.Contains(request, "utm_source") {
+			t.Errorf("expected the ignored query parameters not to be requested, got %q", request)
+		}
+	}
+
+	index, _ := os.ReadFile(filepath.Join(hostDir, "index.html"))
+	for _, link := range []string{
+		`href="page.php@id=1"`,
+		`href="page.php@id=2"`,
+		`href="page.php@id=1#top"`,
+		`href="page.php@q=why%3F"`,
+		`href="c%23.txt"`,
+	} {
+		if !strings.Contains(string(index), link) {
+			t.Errorf("expected the converted index.html to contain %s, got %s", link, index)
+		}
+	}
+}
+
+func TestSite_AdjustExtension(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/":
+					w.Header().Set("Content-Type", "text/html")
+					_, _ = fmt.Fprint(
+						w, `<html><head><link rel="stylesheet" href="/style.php"></head><body>`+
+							`<a href="/page.php?id=1#top">1</a>`+
+							`<a href="/about.htm">about</a>`+
+							`</body></html>`,
+					)
+				case "/style.php":
+					w.Header().Set("Content-Type", "text/css")
+					_, _ = fmt.Fprint(w, "body {}")
+				default:
+					w.Header().Set("Content-Type", "text/html")
+					_, _ = fmt.Fprint(w, "<html></html>")
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	savePath := t.TempDir()
+	cxt := ctx.Context{SavePath: savePath, ConvertLinks: true, AdjustExtension: true}
+	if err := Site(context.Background(), cxt, server.URL); err != nil {
+		t.Fatalf("Site() error = %v", err)
+	}
+
+	hostDir := filepath.Join(savePath, "127.0.0.1")
+	for _, name := range []string{"index.html", "style.php.css", "page.php@id=1.html", "about.htm"} {
+		if _, err := os.Stat(filepath.Join(hostDir, name)); err != nil {
+			t.Errorf("expected %s to be saved, got %v", name, err)
+		}
+	}
+
+	index, _ := os.ReadFile(filepath.Join(hostDir, "index.html"))
+	for _, link := range []string{
+		`href="style.php.css"`,
+		`href="page.php@id=1.html#top"`,
+		`href="about.htm"`,
+	} {
+		if !strings.Contains(string(index), link) {
+			t.Errorf("expected the converted index.html to contain %s, got %s", link, index)
+		}
+	}
+}
+
+func TestSite_ContinueAdjustExtension(t *testing.T) {
+	style := strings.Repeat("body {}\n", 100)
+	var ranges []string
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/":
+					w.Header().Set("Content-Type", "text/html")
+					_, _ = fmt.Fprint(w, `<html><head><link rel="stylesheet" href="/style.php"></head></html>`)
+				case "/style.php":
+					ranges = append(ranges, r.Header.Get("Range"))
+					w.Header().Set("Content-Type", "text/css")
+					http.ServeContent(w, r, "", time.Time{}, strings.NewReader(style))
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	// a previous mirror was interrupted halfway through the stylesheet, saved as style.php.css
+	savePath := t.TempDir()
+	hostDir := filepath.Join(savePath, "127.0.0.1")
+	if err := os.MkdirAll(hostDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(hostDir, "style.php.css.part"), []byte(style[:300]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cxt := ctx.Context{SavePath: savePath, AdjustExtension: true, Continue: true}
+	if err := Site(context.Background(), cxt, server.URL); err != nil {
+		t.Fatalf("Site() error = %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(hostDir, "style.php.css")); string(got) != style {
+		t.Errorf("expected the stylesheet to be continued, got %d bytes", len(got))
+	}
+	if len(ranges) == 0 || ranges[len(ranges)-1] != "bytes=300-" {
+		t.Errorf("expected the stylesheet to be requested from its 300th byte, got the ranges %q", ranges)
+	}
+}
+
+func TestSite_Layout(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/", "/docs/":
+					w.Header().Set("Content-Type", "text/html")
+					_, _ = fmt.Fprint(
+						w, `<html><body>`+
+							`<a href="/docs/">docs</a>`+
+							`<a href="/v1/lib/a.txt">v1</a>`+
+							`<a href="/v2/lib/a.txt#top">v2</a>`+
+							`</body></html>`,
+					)
+				default:
+					w.Header().Set("Content-Type", "text/plain")
+					_, _ = fmt.Fprint(w, r.URL.Path)
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		cxt  ctx.Context
+		// want maps the files expected in the save path to their contents, empty contents aren't compared
+		want map[string]string
+		// index is the path of the mirrored page, and links lists the links expected in it, once converted
+		index string
+		links []string
+	}{
+		{
+			name: "no directories",
+			cxt:  ctx.Context{NoDirectories: true},
+			want: map[string]string{"a.txt": "/v1/lib/a.txt", "a(1).txt": "/v2/lib/a.txt", "index(1).html": ""},
+			// the first page saved as index.html is the mirrored page, the second, /docs/
+			index: "index.html",
+			links: []string{`href="index%281%29.html"`, `href="a.txt"`, `href="a%281%29.txt#top"`},
+		},
+		{
+			name:  "no host directories",
+			cxt:   ctx.Context{NoHostDirectories: true},
+			want:  map[string]string{"v1/lib/a.txt": "/v1/lib/a.txt", "v2/lib/a.txt": "/v2/lib/a.txt"},
+			index: "index.html",
+			links: []string{`href="docs/index.html"`, `href="v1/lib/a.txt"`, `href="v2/lib/a.txt#top"`},
+		},
+		{
+			name:  "cut dirs",
+			cxt:   ctx.Context{NoHostDirectories: true, CutDirs: 1},
+			want:  map[string]string{"lib/a.txt": "/v1/lib/a.txt", "lib/a(1).txt": "/v2/lib/a.txt"},
+			index: "index.html",
+			links: []string{`href="index%281%29.html"`, `href="lib/a.txt"`, `href="lib/a%281%29.txt#top"`},
+		},
+		{
+			name:  "protocol directories",
+			cxt:   ctx.Context{ProtocolDirectories: true},
+			want:  map[string]string{"http/127.0.0.1/v1/lib/a.txt": "/v1/lib/a.txt"},
+			index: "http/127.0.0.1/index.html",
+			links: []string{`href="docs/index.html"`, `href="v1/lib/a.txt"`, `href="v2/lib/a.txt#top"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				savePath := t.TempDir()
+				tt.cxt.SavePath = savePath
+				tt.cxt.ConvertLinks = true
+				if err := Site(context.Background(), tt.cxt, server.URL); err != nil {
+					t.Fatalf("Site() error = %v", err)
+				}
+
+				for name, contents := range tt.want {
+					got, err := os.ReadFile(filepath.Join(savePath, name))
+					if err != nil || (contents != "" && string(got) != contents) {
+						t.Errorf("expected %s to hold %q, got %q, %v", name, contents, got, err)
+					}
+				}
+
+				index, _ := os.ReadFile(filepath.Join(savePath, tt.index))
+				for _, link := range tt.links {
+					if !strings.Contains(string(index), link) {
+						t.Errorf("expected the converted %s to contain %s, got %s", tt.index, link, index)
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestSite_Failures(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/":
+					w.Header().Set("Content-Type", "text/html")
+					_, _ = fmt.Fprint(
+						w, `<html><body>`+
+							`<a href="/ok.txt">ok</a>`+
+							`<a href="/missing.txt">missing</a>`+
+							`<a href="/private.txt">private</a>`+
+							`<a href="/skipped.zip">skipped</a>`+
+							`</body></html>`,
+					)
+				case "/missing.txt":
+					w.WriteHeader(http.StatusNotFound)
+				case "/private.txt":
+					w.WriteHeader(http.StatusUnauthorized)
+				default:
+					w.Header().Set("Content-Type", "text/plain")
+					_, _ = fmt.Fprint(w, r.URL.Path)
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	cxt := ctx.Context{SavePath: t.TempDir(), Verbosity: progress.Quiet, Rejects: []string{"*.zip"}}
+	err := Site(context.Background(), cxt, server.URL)
+	if err == nil {
+		t.Fatalf("expected the failed links to fail the mirror")
+	}
+	// the rejected link isn't a failure, and the authentication failure is more severe than the missing page
+	if !strings.Contains(err.Error(), "2 pages") || xerr.ExitStatus(err) != xerr.AuthStatus {
+		t.Errorf("unexpected error %v, of exit status %d", err, xerr.ExitStatus(err))
+	}
+	if _, statErr := os.Stat(filepath.Join(cxt.SavePath, "127.0.0.1", "ok.txt")); statErr != nil {
+		t.Errorf("expected the other links to be mirrored, got %v", statErr)
+	}
+}
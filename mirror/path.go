@@ -2,6 +2,7 @@ package mirror
 
 import (
 	"fmt"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
@@ -12,16 +13,120 @@ import (
 	"wget/fileio"
 	"wget/httpx"
 	"wget/mirror/xurl"
+	"wget/restrict"
 	"wget/temp"
 )
 
+// adjustedContentTypes lists the content types, whose files are only opened properly by browsers,
+// offline, if they have the right extension
+var adjustedContentTypes = []string{"text/html", "application/xhtml+xml", "text/css"}
+
+// AdjustExtension returns the given filename, with the extension of its content type, as in the given headers,
+// appended, if the content type is HTML or CSS, and the filename doesn't have a matching extension already.
+// For example, page.php served as text/html, becomes page.php.html
+func AdjustExtension(filename string, header http.Header) string {
+	contentType := httpx.ExtractMimeType(header)
+	if !slices.Contains(adjustedContentTypes, contentType) {
+		return filename
+	}
+
+	extension := filepath.Ext(filename)
+	want := "." + contentTypeExtensions[contentType]
+	if strings.EqualFold(extension, want) {
+		return filename
+	}
+	if extensionType, _, err := mime.ParseMediaType(mime.TypeByExtension(extension)); err == nil && extensionType == contentType {
+		// e.g., .htm files are HTML files as well
+		return filename
+	}
+	return filename + want
+}
+
+// Naming decides the paths that the contents of mirrored URLs are written into
+type Naming struct {
+	// Restrict selects how the names of the files, and folders, taken from the URLs, are sanitized
+	Restrict restrict.Mode
+	// AdjustExtension appends the extension of the content type to the names of HTML and CSS files, that
+	// don't have the extension already, see AdjustExtension
+	AdjustExtension bool
+	// IgnoreQueryParams lists the glob patterns, as in path.Match, of the query parameters
+	// to ignore, e.g. `utm_*`, such that they neither tell files apart, nor are requested
+	IgnoreQueryParams []string
+	// NoDirectories saves all files directly into the parent folder, without any host, or path, directories
+	NoDirectories bool
+	// NoHostDirectories omits the host directory, e.g., example.com/a/b.txt is saved as a/b.txt
+	NoHostDirectories bool
+	// ProtocolDirectories nests the host directory in a directory named after the scheme, e.g., https/example.com
+	ProtocolDirectories bool
+	// CutDirs omits as many leading directories of the URL path, e.g., with 1, /a/b/c.txt is saved as b/c.txt
+	CutDirs int
+}
+
+// Merges reports whether different URLs may be saved to the same path, as some of the directories
+// of their URLs are omitted, e.g., with NoDirectories, both /a/index.html and /b/index.html are
+// saved as index.html
+func (n Naming) Merges() bool {
+	return n.NoDirectories || n.NoHostDirectories || n.CutDirs > 0
+}
+
+// Canonical returns the given URL without its fragment, and without the ignored query parameters,
+// such that the URLs of the same resource, e.g., page?utm_source=a and page#top, are downloaded once
+func (n Naming) Canonical(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	u.Fragment, u.RawFragment = "", ""
+	u.RawQuery = n.query(u.RawQuery)
+	return u.String()
+}
+
+// query returns the given raw query, without the ignored query parameters
+func (n Naming) query(rawQuery string) string {
+	if rawQuery == "" || len(n.IgnoreQueryParams) == 0 {
+		return rawQuery
+	}
+
+	var kept []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if param != "" && !n.ignores(name) {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// ignores reports whether the named query parameter is ignored
+func (n Naming) ignores(name string) bool {
+	for _, pattern := range n.IgnoreQueryParams {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // GetFile returns the file path where the contents of the provided URL to be mirrored will be written into,
 // honoring the given parent folder. The downloaded file will be stored in a directory structure that reflects the
-// mirrored URL, with the given parent directory.
+// mirrored URL, with the given parent directory. See Naming.Path, GetFile uses the default Naming
 //
 // The function assumes that the provided downloadUrl is a valid URL and that
 // the parent folder (if specified) is a writable directory.
 func GetFile(downloadUrl string, header http.Header, parentFolder string) string {
+	return Naming{}.Path(downloadUrl, header, parentFolder)
+}
+
+// Path returns the file path where the contents of the provided URL to be mirrored will be written into,
+// honoring the given parent folder. The downloaded file will be stored in a directory structure that reflects the
+// mirrored URL, with the given parent directory. The names of the host, folders and file are sanitized, such that
+// the returned path is always within the parent folder.
+//
+// The query of the URL, if any, is encoded into the name of the file, e.g., page.php?id=1 is written into page.php@id=1
+func (n Naming) Path(downloadUrl string, header http.Header, parentFolder string) string {
 	u, err := url.Parse(downloadUrl)
 	if err != nil {
 		return ""
@@ -31,10 +136,8 @@ func GetFile(downloadUrl string, header http.Header, parentFolder string) string
 		return ""
 	}
 
-	folder := filepath.Dir(u.Path)
-	filename := strings.TrimPrefix(u.Path, folder)
-
-	if filename == "" || filename == "/" {
+	folder, filename := path.Split(u.Path)
+	if filename == "" {
 		contentType := httpx.ExtractMimeType(header)
 		if ext, ok := contentTypeExtensions[contentType]; ok {
 			filename = fmt.Sprintf("index.%s", ext)
@@ -43,18 +146,41 @@ func GetFile(downloadUrl string, header http.Header, parentFolder string) string
 		}
 	}
 
+	if query := n.query(u.RawQuery); query != "" {
+		// tell apart the files of the same path, but different queries, e.g., page.php?id=1 and page.php?id=2
+		filename += "@" + query
+	}
+
 	if disposition, err := httpx.FilenameFromContentDisposition(header); err == nil {
 		filename = disposition
 	}
 
-	host := u.Hostname()
-	if host != "" {
-		host += "/"
+	if n.AdjustExtension {
+		filename = AdjustExtension(filename, header)
+	}
+
+	var dirs []string
+	if !n.NoDirectories {
+		if n.ProtocolDirectories {
+			dirs = append(dirs, n.Restrict.Name(u.Scheme))
+		}
+		if !n.NoHostDirectories {
+			// include the hostname to the folder name, e.g., google.com/folder
+			dirs = append(dirs, n.Restrict.Name(u.Hostname()))
+		}
+		dirs = append(dirs, n.Restrict.Path(cutDirs(folder, n.CutDirs)))
+	}
+	return filepath.Join(parentFolder, filepath.Join(dirs...), n.Restrict.Name(filename))
+}
+
+// cutDirs returns the given slash separated folder, without its first n directories. The folder is
+// cleaned first, such that `.` and `..` elements aren't counted as directories
+func cutDirs(folder string, n int) string {
+	folder = strings.Trim(path.Clean("/"+folder), "/")
+	for ; n > 0 && folder != ""; n-- {
+		_, folder, _ = strings.Cut(folder, "/")
 	}
-	// host => google.com/
-	// include the hostname to the folder name
-	folder = host + folder
-	return path.Join(parentFolder, folder, filename)
+	return folder
 }
 
 // FolderStructure returns all parent folders necessary for the given filepath to exist.
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"reflect"
 	"testing"
+
+	"wget/restrict"
 )
 
 func TestGetFile(t *testing.T) {
@@ -154,7 +156,7 @@ func TestGetFile(t *testing.T) {
 				header:       headerText,
 				parentFolder: "/downloads",
 			},
-			want: "/downloads/example.com/resource/file.txt",
+			want: "/downloads/example.com/resource/file.txt@version=1",
 		},
 		{
 			name: "URL with special characters",
@@ -450,3 +452,97 @@ func ExampleFolderStructure() {
 	// /a/b
 	// /a
 }
+
+func TestNaming_Path(t *testing.T) {
+	disposition := func(filename string) http.Header {
+		header := http.Header{}
+		header.Set("Content-Disposition", "attachment; filename="+filename)
+		return header
+	}
+
+	tests := []struct {
+		name   string
+		naming Naming
+		url    string
+		header http.Header
+		want   string
+	}{
+		{"traversal", Naming{}, "http://example.com/../../etc/passwd", http.Header{}, "/downloads/example.com/etc/passwd"},
+		{"escaped traversal", Naming{}, "http://example.com/a/..%2F..%2Fb", http.Header{}, "/downloads/example.com/b"},
+		{"disposition traversal", Naming{}, "http://example.com/a/", disposition(`"../../.bashrc"`), "/downloads/example.com/a/.bashrc"},
+		{"disposition parent", Naming{}, "http://example.com/a/", disposition(`".."`), "/downloads/example.com/a/index.html"},
+		{"windows", Naming{Restrict: restrict.Windows}, "http://example.com/what:is/it*.txt", http.Header{}, "/downloads/example.com/what%3Ais/it%2A.txt"},
+		{"lowercase", Naming{Restrict: restrict.Unix | restrict.Lowercase}, "http://Example.com/Docs/A.TXT", http.Header{}, "/downloads/example.com/docs/a.txt"},
+		{"ascii", Naming{Restrict: restrict.Unix | restrict.ASCII}, "http://example.com/caf%C3%A9/", http.Header{}, "/downloads/example.com/caf%C3%A9/index.html"},
+		{"no directories", Naming{NoDirectories: true}, "http://example.com/a/b/c.txt", http.Header{}, "/downloads/c.txt"},
+		{"no directories, protocol", Naming{NoDirectories: true, ProtocolDirectories: true}, "https://example.com/a/c.txt", http.Header{}, "/downloads/c.txt"},
+		{"no host directories", Naming{NoHostDirectories: true}, "http://example.com/a/b/c.txt", http.Header{}, "/downloads/a/b/c.txt"},
+		{"protocol directories", Naming{ProtocolDirectories: true}, "https://example.com/a/c.txt", http.Header{}, "/downloads/https/example.com/a/c.txt"},
+		{"protocol, no host", Naming{ProtocolDirectories: true, NoHostDirectories: true}, "https://example.com/a/c.txt", http.Header{}, "/downloads/https/a/c.txt"},
+		{"cut dirs", Naming{CutDirs: 1}, "http://example.com/a/b/c.txt", http.Header{}, "/downloads/example.com/b/c.txt"},
+		{"cut more dirs than present", Naming{CutDirs: 5}, "http://example.com/a/b/c.txt", http.Header{}, "/downloads/example.com/c.txt"},
+		{"cut dirs, no host", Naming{CutDirs: 2, NoHostDirectories: true}, "http://example.com/a/b/", http.Header{}, "/downloads/index.html"},
+		{"cut dirs, traversal", Naming{CutDirs: 1}, "http://example.com/a/../b/c/d.txt", http.Header{}, "/downloads/example.com/c/d.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := tt.naming.Path(tt.url, tt.header, "/downloads"); got != tt.want {
+					t.Errorf("Path(%q) = %q, want %q", tt.url, got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestAdjustExtension(t *testing.T) {
+	contentType := func(contentType string) http.Header {
+		header := http.Header{}
+		header.Set("Content-Type", contentType)
+		return header
+	}
+
+	tests := []struct {
+		filename    string
+		contentType string
+		want        string
+	}{
+		{"page.php", "text/html; charset=utf-8", "page.php.html"},
+		{"page.php@id=1", "text/html", "page.php@id=1.html"},
+		{"index.html", "text/html", "index.html"},
+		{"INDEX.HTML", "text/html", "INDEX.HTML"},
+		{"page.htm", "text/html", "page.htm"},
+		{"page.xhtml", "application/xhtml+xml", "page.xhtml"},
+		{"style", "text/css", "style.css"},
+		{"style.css", "text/css", "style.css"},
+		{"style.php", "text/css", "style.php.css"},
+		{"image", "image/png", "image"},
+		{"data.json", "application/json", "data.json"},
+		{"page", "", "page"},
+	}
+	for _, tt := range tests {
+		if got := AdjustExtension(tt.filename, contentType(tt.contentType)); got != tt.want {
+			t.Errorf("AdjustExtension(%q, %q) = %q, want %q", tt.filename, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestNaming_Canonical(t *testing.T) {
+	naming := Naming{IgnoreQueryParams: []string{"utm_*", "sessionid"}}
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://example.com/page.php?id=1", "http://example.com/page.php?id=1"},
+		{"http://example.com/page.php?id=1#top", "http://example.com/page.php?id=1"},
+		{"http://example.com/page.php?utm_source=a&id=1&utm_medium=b", "http://example.com/page.php?id=1"},
+		{"http://example.com/page.php?sessionid=42", "http://example.com/page.php"},
+		{"http://example.com/page.php?sessionid2=42", "http://example.com/page.php?sessionid2=42"},
+		{"http://example.com/page.php?utm%5Fsource=a", "http://example.com/page.php"},
+	}
+	for _, tt := range tests {
+		if got := naming.Canonical(tt.url); got != tt.want {
+			t.Errorf("Canonical(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
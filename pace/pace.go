@@ -0,0 +1,176 @@
+// Package pace paces the requests sent to the same host, so that downloading
+// many files, or mirroring a website, doesn't hammer web servers with requests
+// sent back to back
+package pace
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// MinBackoff is the smallest delay between requests to a host that asked us
+	// to slow down, used when no --wait was specified by the user
+	MinBackoff = 1 * time.Second
+	// MaxBackoff is the largest factor by which the delay between requests to a
+	// host will be multiplied, when the host keeps asking us to slow down
+	MaxBackoff = 64
+	// MaxDelay caps the delay between two requests to the same host
+	MaxDelay = 5 * time.Minute
+)
+
+// host keeps the pacing state of requests sent to a single host
+type host struct {
+	// next is the earliest time the next request to this host may be sent
+	next time.Time
+	// backoff multiplies the base wait when the host asks us to slow down,
+	// e.g., by responding with `429 Too Many Requests`; 1 means no backoff
+	backoff float64
+}
+
+// Pacer enforces a delay between consecutive requests sent to the same host.
+// A Pacer is safe for concurrent use, thus, a single instance should be shared
+// by all downloads that may run at once, so that the delay is respected per
+// host, rather than per download. Always use New to create instances of this
+// struct. A nil *Pacer is valid and does no pacing at all
+type Pacer struct {
+	// wait is the base delay between consecutive requests to the same host
+	wait time.Duration
+	// random randomizes the delay to between 0.5 and 1.5 times the base delay,
+	// as GNU wget does with --random-wait
+	random bool
+	// m locks hosts, which is accessed by different goroutines
+	m *sync.Mutex
+	// hosts maps a host (as in url.URL.Host) to its pacing state
+	hosts map[string]*host
+	// sleep blocks for the given duration, or until the context is done; it is replaced in tests
+	sleep func(cx context.Context, d time.Duration) error
+	// now returns the current time; it is replaced in tests
+	now func() time.Time
+}
+
+// New creates a new Pacer that waits for `wait` between consecutive requests
+// to the same host. If random is true, every delay will be a random duration
+// between 0.5 and 1.5 times `wait`
+func New(wait time.Duration, random bool) *Pacer {
+	return &Pacer{
+		wait:   max(wait, 0),
+		random: random,
+		m:      &sync.Mutex{},
+		hosts:  make(map[string]*host),
+		sleep:  sleep,
+		now:    time.Now,
+	}
+}
+
+// Wait blocks until a request may be sent to the given host, then reserves the
+// current slot, such that the next caller, requesting for the same host, will
+// have to wait for the configured delay. Returns the context's error if the
+// context is done before the request may be sent
+func (p *Pacer) Wait(cx context.Context, hostname string) error {
+	if p == nil {
+		return cx.Err()
+	}
+
+	p.m.Lock()
+	h := p.host(hostname)
+	now := p.now()
+	start := now
+	if h.next.After(now) {
+		start = h.next
+	}
+	h.next = start.Add(p.delay(h))
+	p.m.Unlock()
+
+	if d := start.Sub(now); d > 0 {
+		return p.sleep(cx, d)
+	}
+	return cx.Err()
+}
+
+// Adapt adjusts the pacing of requests to the given host, based on the
+// response the host sent. A `429 Too Many Requests` or a `503 Service
+// Unavailable` response doubles the delay between requests to the host, and
+// honors the `Retry-After` header if sent; any other successful response
+// gradually reduces the delay back to the configured wait
+func (p *Pacer) Adapt(hostname string, statusCode int, header http.Header) {
+	if p == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+	h := p.host(hostname)
+	switch {
+	case statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable:
+		h.backoff = min(h.backoff*2, MaxBackoff)
+		next := p.now().Add(p.delay(h))
+		if after, ok := retryAfter(header, p.now()); ok {
+			next = p.now().Add(min(after, MaxDelay))
+		}
+		if next.After(h.next) {
+			h.next = next
+		}
+	case statusCode < http.StatusBadRequest:
+		h.backoff = max(h.backoff/2, 1)
+	}
+}
+
+// host returns the pacing state of the given host, creating it if it doesn't
+// exist. The caller must hold the lock
+func (p *Pacer) host(hostname string) *host {
+	h, ok := p.hosts[hostname]
+	if !ok {
+		h = &host{backoff: 1}
+		p.hosts[hostname] = h
+	}
+	return h
+}
+
+// delay returns the time to wait after a request to the given host, before the
+// next request to the same host may be sent
+func (p *Pacer) delay(h *host) time.Duration {
+	d := p.wait
+	if h.backoff > 1 {
+		d = time.Duration(float64(max(d, MinBackoff)) * h.backoff)
+	}
+	if p.random && d > 0 {
+		d = time.Duration(float64(d) * (0.5 + rand.Float64()))
+	}
+	return min(d, MaxDelay)
+}
+
+// retryAfter extracts the delay requested by the server in the `Retry-After`
+// header, which may either be a number of seconds or an HTTP date
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
+// sleep blocks for the given duration, or until the given context is done, whichever happens first
+func sleep(cx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-cx.Done():
+		return cx.Err()
+	}
+}
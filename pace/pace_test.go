@@ -0,0 +1,144 @@
+package pace
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock whose time only moves forward when something sleeps
+type fakeClock struct {
+	m     sync.Mutex
+	t     time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) sleep(_ context.Context, d time.Duration) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.slept = append(c.slept, d)
+	return nil
+}
+
+// newTestPacer creates a pacer whose clock doesn't move unless the test moves it
+func newTestPacer(wait time.Duration, random bool) (*Pacer, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	p := New(wait, random)
+	p.now = clock.now
+	p.sleep = clock.sleep
+	return p, clock
+}
+
+func TestPacer_Wait(t *testing.T) {
+	p, clock := newTestPacer(2*time.Second, false)
+
+	// The first request to a host is never delayed
+	p.Wait(context.Background(), "example.com")
+	if len(clock.slept) != 0 {
+		t.Fatalf("expected the first request not to wait, waited %v", clock.slept)
+	}
+
+	// Requests to the same host are delayed one after another
+	p.Wait(context.Background(), "example.com")
+	p.Wait(context.Background(), "example.com")
+	want := []time.Duration{2 * time.Second, 4 * time.Second}
+	if len(clock.slept) != 2 || clock.slept[0] != want[0] || clock.slept[1] != want[1] {
+		t.Fatalf("expected waits %v, got %v", want, clock.slept)
+	}
+
+	// Other hosts are paced independently
+	p.Wait(context.Background(), "example.org")
+	if len(clock.slept) != 2 {
+		t.Fatalf("expected a request to another host not to wait, waited %v", clock.slept[2:])
+	}
+}
+
+func TestPacer_RandomWait(t *testing.T) {
+	p, clock := newTestPacer(10*time.Second, true)
+	for i := 0; i < 100; i++ {
+		p.Wait(context.Background(), "example.com")
+		clock.t = clock.t.Add(time.Hour)
+		if d := p.hosts["example.com"].next.Sub(clock.t.Add(-time.Hour)); d < 5*time.Second || d >= 15*time.Second {
+			t.Fatalf("expected a random wait between 5s and 15s, got %v", d)
+		}
+	}
+}
+
+func TestPacer_Adapt(t *testing.T) {
+	p, clock := newTestPacer(0, false)
+
+	// Without --wait, requests aren't paced, until the server asks us to slow down
+	p.Wait(context.Background(), "example.com")
+	p.Adapt("example.com", http.StatusTooManyRequests, http.Header{})
+	p.Wait(context.Background(), "example.com")
+	if len(clock.slept) != 1 || clock.slept[0] != 2*MinBackoff {
+		t.Fatalf("expected to back off for %v, got %v", 2*MinBackoff, clock.slept)
+	}
+
+	// Repeated requests to slow down keep doubling the delay
+	clock.t = clock.t.Add(time.Hour)
+	p.Adapt("example.com", http.StatusServiceUnavailable, http.Header{})
+	if got := p.hosts["example.com"].backoff; got != 4 {
+		t.Fatalf("expected backoff factor 4, got %v", got)
+	}
+
+	// Successful responses bring the delay back down
+	p.Adapt("example.com", http.StatusOK, http.Header{})
+	p.Adapt("example.com", http.StatusOK, http.Header{})
+	if got := p.hosts["example.com"].backoff; got != 1 {
+		t.Fatalf("expected backoff factor 1, got %v", got)
+	}
+}
+
+func TestPacer_RetryAfter(t *testing.T) {
+	p, clock := newTestPacer(time.Second, false)
+	header := http.Header{}
+	header.Set("Retry-After", "30")
+
+	p.Wait(context.Background(), "example.com")
+	p.Adapt("example.com", http.StatusTooManyRequests, header)
+	p.Wait(context.Background(), "example.com")
+	if len(clock.slept) != 1 || clock.slept[0] != 30*time.Second {
+		t.Fatalf("expected to honor Retry-After of 30s, got %v", clock.slept)
+	}
+}
+
+func TestPacer_Nil(t *testing.T) {
+	var p *Pacer
+	// a nil pacer does no pacing, and must not panic
+	p.Wait(context.Background(), "example.com")
+	p.Adapt("example.com", http.StatusTooManyRequests, http.Header{})
+}
+
+func TestPacer_WaitCanceled(t *testing.T) {
+	p := New(time.Hour, false)
+	if err := p.Wait(context.Background(), "example.com"); err != nil {
+		t.Fatalf("expected the first request not to wait, got %v", err)
+	}
+
+	// the second request would wait an hour, unless the wait is canceled
+	cx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := p.Wait(cx, "example.com"); err != context.DeadlineExceeded {
+		t.Errorf("Wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("expected Wait() to return as soon as the context was done, waited %v", waited)
+	}
+
+	var nilPacer *Pacer
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := nilPacer.Wait(canceled, "example.com"); err != context.Canceled {
+		t.Errorf("Wait() on a nil pacer error = %v, want %v", err, context.Canceled)
+	}
+}
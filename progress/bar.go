@@ -0,0 +1,86 @@
+package progress
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"wget/fetch"
+	"wget/globals"
+	"wget/syscheck"
+)
+
+// rows is the number of terminal rows each download is drawn in, by the bar
+const rows = 9
+
+// bar is the Reporter that draws the progress of each download in its own rows of the terminal,
+// moving the terminal cursor around, see globals.PrintLines
+type bar struct {
+	mutex sync.Mutex
+	// drawn is the number of downloads drawn so far, i.e., one more than the greatest n drawn
+	drawn int
+	// printed records that the cursor was moved below the progress, to print the messages, see below
+	printed bool
+}
+
+func (b *bar) Start() {
+	syscheck.MoveCursor(1)
+	syscheck.ClearScreen()
+	syscheck.HideCursor()
+}
+
+func (b *bar) Listener(n int, url string) fetch.AdvancedProgressListener {
+	b.draw(n)
+	status := fetch.DownloadStatus{}
+	status.OnUpdate = func(status *fetch.DownloadStatus, hint int) {
+		// whenever the status of this download has changed, we print the progress to its
+		// respective position in the terminal
+		globals.PrintLines((n*rows)+hint, []string{status.GetField(hint)})
+	}
+	globals.PrintLines(n*rows, globals.StringTimes("\033[38;5;208m···\033[0m", rows-1))
+	listener := *status.ProgressListener()
+	// the rows draw neither the responses, nor the bytes downloaded before a download is continued
+	listener.OnResponse, listener.OnResume = func(*http.Response) {}, func(int64) {}
+	return listener
+}
+
+func (b *bar) Error(n int, url string, err error) {
+	b.draw(n)
+	errString := fmt.Sprintf("error: %s: %v", url, err)
+	errString = strings.Replace(errString, "\n", " : ", -1)
+	globals.PrintLines((n*rows)+5, []string{errString})
+}
+
+func (b *bar) Printf(format string, a ...any) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.below()
+	_, _ = fmt.Fprintf(syscheck.Output(), format, a...)
+}
+
+func (b *bar) Finish() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.below()
+	syscheck.ShowCursor()
+}
+
+// below moves the cursor to the terminal row after the last progress, once, such that the messages are printed
+// below the progress of the downloads, one after the other. The caller must hold the lock
+func (b *bar) below() {
+	if b.drawn != 0 && !b.printed {
+		syscheck.MoveCursor(b.drawn * rows)
+	}
+	b.printed = true
+}
+
+// draw records that the n-th download is drawn
+func (b *bar) draw(n int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if n >= b.drawn {
+		// the messages after are printed below this download
+		b.drawn, b.printed = n+1, false
+	}
+}
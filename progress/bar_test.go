@@ -0,0 +1,42 @@
+package progress
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"wget/syscheck"
+)
+
+func TestBar(t *testing.T) {
+	defer func(out io.Writer) { syscheck.Out = out }(syscheck.Out)
+	out := &bytes.Buffer{}
+	syscheck.Out = out
+
+	b := &bar{}
+	b.Start()
+	l := b.Listener(1, "https://example.com/file.zip")
+	l.OnStart(time.Now())
+	b.Error(2, "https://example.com/missing", errors.New("failed to download file\nconnection reset"))
+	b.Printf("\nSkipped: %d files\n", 1)
+	b.Finish()
+
+	got := out.String()
+	for _, want := range []string{
+		"\033[?25l",
+		// the rows of the second download
+		fmt.Sprintf("\033[%d;0H", rows+1),
+		// the error of the third download, on a single line
+		fmt.Sprintf("\033[%d;0H\033[Kerror: https://example.com/missing: failed to download file : connection reset", 2*rows+6),
+		// the cursor is moved after the rows of all the downloads, for the messages, then shown again
+		fmt.Sprintf("\033[%d;0H\nSkipped: 1 files\n\033[?25h", 3*rows),
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected the bar to draw %q, got %q", want, got)
+		}
+	}
+}
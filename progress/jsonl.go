@@ -0,0 +1,248 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"wget/fetch"
+)
+
+// The kinds of events reported by --output-format=jsonl, see Event
+const (
+	// EventStart is reported as the n-th download starts, see fetch.AdvancedProgressListener.OnStart
+	EventStart = "start"
+	// EventResponse is reported with each response, including those redirecting to the next request, with the
+	// URL requested, and the headers of the response, as with --server-response; not reported otherwise
+	EventResponse = "response"
+	// EventStatus is reported as the response status arrives, see fetch.AdvancedProgressListener.OnStatus
+	EventStatus = "status"
+	// EventContentLength is reported as the size of the download is known, its Total is -1 if unspecified
+	EventContentLength = "content_length"
+	// EventFile is reported as the file, the download is saved to, is decided
+	EventFile = "file"
+	// EventProgress is reported as more bytes are downloaded, or the download rate is updated
+	EventProgress = "progress"
+	// EventFinished is reported once the download is done, successfully if OK
+	EventFinished = "finished"
+	// EventError is reported as the download failed, or was skipped, with the Error
+	EventError = "error"
+	// EventMessage is reported for any other message, such as the messages about the downloads skipped
+	EventMessage = "message"
+	// EventSummary is the last event, reported once per run, after all the downloads are done, and all the
+	// messages about them are reported
+	EventSummary = "summary"
+)
+
+// Event is a JSON object reported by --output-format=jsonl, on a line of its own. All events have the Event kind
+// and the Time; the other fields are only set as relevant to the kind, and omitted otherwise, e.g.,
+//
+//	{"event":"start","time":"2024-10-18T10:00:00Z","id":0,"url":"https://example.com/file.zip"}
+//	{"event":"status","time":"2024-10-18T10:00:00Z","id":0,"url":"https://example.com/file.zip","status":"200 OK","code":200}
+//	{"event":"finished","time":"2024-10-18T10:00:01Z","id":0,"url":"https://example.com/file.zip","path":"file.zip","downloaded":76800,"total":76800,"ok":true}
+//	{"event":"summary","time":"2024-10-18T10:00:01Z","downloads":1,"succeeded":1,"failed":0,"skipped":0,"bytes":76800,"seconds":1.02}
+type Event struct {
+	// Event is the kind of the event, e.g., EventStart
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	// ID is the number of the download, starting from 0, as given to Reporter.Listener; of the download events
+	ID *int `json:"id,omitempty"`
+	// URL is the URL of the download, of the download events
+	URL string `json:"url,omitempty"`
+	// Path is the file the download is saved to, of the EventFile and EventFinished events
+	Path string `json:"path,omitempty"`
+	// Status and Code are the response status, e.g., `200 OK`, of the EventStatus and EventResponse events
+	Status string `json:"status,omitempty"`
+	Code   int    `json:"code,omitempty"`
+	// Request is the URL requested, after any redirects so far, and Headers are the headers of the response,
+	// of the EventResponse events
+	Request string      `json:"request,omitempty"`
+	Headers http.Header `json:"headers,omitempty"`
+	// Downloaded and Total are the bytes downloaded, and expected, so far; Total is -1 if unknown
+	Downloaded *int64 `json:"downloaded,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+	// Rate is the download rate, in bytes/second, of the EventProgress events, once known
+	Rate *int32 `json:"rate,omitempty"`
+	// OK tells whether the download succeeded, of the EventFinished events
+	OK *bool `json:"ok,omitempty"`
+	// Error is the error of the EventError events
+	Error string `json:"error,omitempty"`
+	// Message is the message of the EventMessage events
+	Message string `json:"message,omitempty"`
+	// Summary is the summary of all the downloads, of the EventSummary event
+	*Summary
+}
+
+// Summary sums up all the downloads, in the EventSummary event
+type Summary struct {
+	// Downloads is the number of downloads started, i.e., those that succeeded, or failed
+	Downloads int `json:"downloads"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// Skipped is the number of downloads never started, e.g., once the --quota was exceeded
+	Skipped int `json:"skipped"`
+	// Bytes is the number of bytes downloaded by all the downloads, not counting those downloaded before, by the
+	// runs that left the partial files continued
+	Bytes int64 `json:"bytes"`
+	// Seconds is the time taken by all the downloads
+	Seconds float64 `json:"seconds"`
+}
+
+// jsonl is the Reporter that reports each event of the downloads as an Event, on a line of its own, for
+// tools wrapping this program. Each line is written whole, such that the events of concurrent downloads
+// never break one another
+type jsonl struct {
+	out   io.Writer
+	mutex sync.Mutex
+	// start is the time the downloads started
+	start time.Time
+	// listened records the downloads that were given a listener, i.e., that were started
+	listened map[int]bool
+	summary  Summary
+	// responses reports the EventResponse events, as with --server-response
+	responses bool
+}
+
+func newJSONL(out io.Writer) *jsonl {
+	return &jsonl{out: out, listened: map[int]bool{}}
+}
+
+func (j *jsonl) Start() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.start = time.Now()
+}
+
+func (j *jsonl) Listener(n int, url string) fetch.AdvancedProgressListener {
+	j.mutex.Lock()
+	j.listened[n] = true
+	j.mutex.Unlock()
+
+	// path, downloaded, and total are kept for the EventFinished event, and offset is the number of bytes
+	// downloaded before, of a continued download; only accessed with the mutex held
+	var path string
+	var downloaded, total, offset int64 = 0, -1, 0
+	event := func(kind string) Event {
+		return Event{Event: kind, Time: time.Now(), ID: &n, URL: url}
+	}
+
+	listener := listener()
+	listener.OnStart = func(t time.Time) {
+		e := event(EventStart)
+		e.Time = t
+		j.emit(e)
+	}
+	listener.OnResponse = func(response *http.Response) {
+		if !j.responses {
+			return
+		}
+		e := event(EventResponse)
+		e.Request, e.Headers = response.Request.URL.String(), response.Header
+		e.Status, e.Code = response.Status, response.StatusCode
+		j.emit(e)
+	}
+	listener.OnStatus = func(status string, code int) {
+		if status == "" {
+			return
+		}
+		e := event(EventStatus)
+		e.Status, e.Code = status, code
+		j.emit(e)
+	}
+	listener.OnContentLength = func(length int64) {
+		e := event(EventContentLength)
+		j.mutex.Lock()
+		total = length
+		j.mutex.Unlock()
+		e.Total = &length
+		j.emit(e)
+	}
+	listener.OnGetFile = func(filename string) {
+		e := event(EventFile)
+		j.mutex.Lock()
+		path = filename
+		j.mutex.Unlock()
+		e.Path = filename
+		j.emit(e)
+	}
+	listener.OnResume = func(resumed int64) {
+		j.mutex.Lock()
+		downloaded, offset = resumed, resumed
+		j.mutex.Unlock()
+	}
+	listener.OnProgress = func(bytes, size int64, rate int32) {
+		e := event(EventProgress)
+		if rate >= 0 {
+			e.Rate = &rate
+		}
+		j.mutex.Lock()
+		if rate < 0 {
+			downloaded = bytes
+		}
+		e.Downloaded, e.Total = ptr(downloaded), ptr(total)
+		j.mutex.Unlock()
+		j.emit(e)
+	}
+	listener.OnDownloadFinished = func(finalUrl string, t time.Time) {
+		// the URL is empty if the download failed, see Error
+		ok := finalUrl != ""
+		e := event(EventFinished)
+		e.Time, e.OK = t, &ok
+		j.mutex.Lock()
+		e.Path, e.Downloaded, e.Total = path, ptr(downloaded), ptr(total)
+		if ok {
+			j.summary.Succeeded++
+		} else {
+			j.summary.Failed++
+		}
+		j.summary.Downloads++
+		j.summary.Bytes += downloaded - offset
+		j.mutex.Unlock()
+		j.emit(e)
+	}
+	return listener
+}
+
+func (j *jsonl) Error(n int, url string, err error) {
+	j.mutex.Lock()
+	if !j.listened[n] {
+		j.summary.Skipped++
+	}
+	j.mutex.Unlock()
+	j.emit(Event{Event: EventError, Time: time.Now(), ID: &n, URL: url, Error: err.Error()})
+}
+
+func (j *jsonl) Printf(format string, a ...any) {
+	if message := strings.TrimSpace(fmt.Sprintf(format, a...)); message != "" {
+		j.emit(Event{Event: EventMessage, Time: time.Now(), Message: message})
+	}
+}
+
+func (j *jsonl) Finish() {
+	j.mutex.Lock()
+	summary := j.summary
+	j.mutex.Unlock()
+	now := time.Now()
+	summary.Seconds = now.Sub(j.start).Seconds()
+	j.emit(Event{Event: EventSummary, Time: now, Summary: &summary})
+}
+
+// emit writes the given event as a line of JSON, as a whole
+func (j *jsonl) emit(e Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	_, _ = j.out.Write(append(data, '\n'))
+}
+
+// ptr returns a pointer to a copy of the given value
+func ptr[T any](v T) *T {
+	return &v
+}
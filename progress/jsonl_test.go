@@ -0,0 +1,167 @@
+package progress
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// events decodes the events written by the jsonl reporter, one per line
+func events(t *testing.T, out string) []Event {
+	t.Helper()
+	var events []Event
+	for _, line := range strings.SplitAfter(out, "\n") {
+		if line == "" {
+			continue
+		}
+		if !strings.HasSuffix(line, "\n") {
+			t.Fatalf("expected each event on a line of its own, got %q", line)
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid event %q: %v", line, err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestJSONL(t *testing.T) {
+	out := &strings.Builder{}
+	r := newJSONL(out)
+	r.Start()
+	simulate(r, "https://example.com/file.zip", 1000, 400)
+	r.Error(1, "https://example.com/big.iso", errors.New("skipped: download quota exceeded"))
+	r.Printf("\nDownload quota of %s EXCEEDED! Skipped %d files\n", "1 KiB", 1)
+	r.Finish()
+
+	got := events(t, out.String())
+	var kinds []string
+	for _, e := range got {
+		kinds = append(kinds, e.Event)
+	}
+	want := []string{
+		EventStart, EventStatus, EventContentLength, EventFile,
+		EventProgress, EventProgress, EventProgress, EventProgress,
+		EventFinished, EventError, EventMessage, EventSummary,
+	}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Fatalf("got the events %v, want %v", kinds, want)
+	}
+
+	if e := got[1]; e.Status != "200 OK" || e.Code != 200 || *e.ID != 0 || e.URL != "https://example.com/file.zip" {
+		t.Errorf("unexpected status event %+v", e)
+	}
+	if e := got[2]; *e.Total != 1000 {
+		t.Errorf("unexpected content length event %+v", e)
+	}
+	if e := got[3]; e.Path != "file.zip" {
+		t.Errorf("unexpected file event %+v", e)
+	}
+	if e := got[4]; e.Rate == nil || *e.Rate != 2048 {
+		t.Errorf("expected the rate in the first progress event, got %+v", e)
+	}
+	if e := got[5]; e.Rate != nil || *e.Downloaded != 400 || *e.Total != 1000 {
+		t.Errorf("unexpected progress event %+v", e)
+	}
+	if e := got[8]; !*e.OK || e.Path != "file.zip" || *e.Downloaded != 1000 || *e.Total != 1000 {
+		t.Errorf("unexpected finished event %+v", e)
+	}
+	if e := got[9]; *e.ID != 1 || e.Error != "skipped: download quota exceeded" {
+		t.Errorf("unexpected error event %+v", e)
+	}
+	if e := got[10]; e.Message != "Download quota of 1 KiB EXCEEDED! Skipped 1 files" {
+		t.Errorf("unexpected message event %+v", e)
+	}
+
+	summary := got[11]
+	if summary.ID != nil || summary.Summary == nil {
+		t.Fatalf("unexpected summary event %+v", summary)
+	}
+	s := *summary.Summary
+	s.Seconds = 0
+	if (s != Summary{Downloads: 1, Succeeded: 1, Skipped: 1, Bytes: 1000}) {
+		t.Errorf("unexpected summary %+v", s)
+	}
+}
+
+func TestJSONL_Failed(t *testing.T) {
+	out := &strings.Builder{}
+	r := newJSONL(out)
+	r.Start()
+	l := r.Listener(0, "https://example.com/missing")
+	l.OnStatus("404 Not Found", 404)
+	l.OnDownloadFinished("", time.Now())
+	r.Error(0, "https://example.com/missing", errors.New("bad status code: 404 Not Found"))
+	r.Finish()
+
+	got := events(t, out.String())
+	if len(got) != 4 {
+		t.Fatalf("expected 4 events, got %d:\n%s", len(got), out.String())
+	}
+	if e := got[1]; e.Event != EventFinished || *e.OK {
+		t.Errorf("expected the download to finish unsuccessfully, got %+v", e)
+	}
+	if s := got[3].Summary; s == nil || s.Downloads != 1 || s.Failed != 1 || s.Skipped != 0 {
+		t.Errorf("expected a failed, not skipped, download, got %+v", s)
+	}
+	if !strings.Contains(out.String(), `"ok":false`) {
+		t.Errorf("expected the unsuccessful downloads to be reported as not ok, got\n%s", out.String())
+	}
+}
+
+func TestJSONL_Resumed(t *testing.T) {
+	out := &strings.Builder{}
+	r := newJSONL(out)
+	r.Start()
+	// the first 600 bytes were downloaded by the run that left the partial file
+	l := r.Listener(0, "https://example.com/file.zip")
+	l.OnContentLength(1000)
+	l.OnGetFile("file.zip")
+	l.OnResume(600)
+	l.OnProgress(1000, 1000, -1)
+	l.OnDownloadFinished("https://example.com/file.zip", time.Now())
+	// the partial file held the whole file already
+	l = r.Listener(1, "https://example.com/done.zip")
+	l.OnContentLength(500)
+	l.OnResume(500)
+	l.OnDownloadFinished("https://example.com/done.zip", time.Now())
+	r.Finish()
+
+	got := events(t, out.String())
+	if e := got[3]; e.Event != EventFinished || *e.Downloaded != 1000 {
+		t.Errorf("expected the whole file to be reported as downloaded, got %+v", e)
+	}
+	if s := got[len(got)-1].Summary; s == nil || s.Bytes != 400 || s.Succeeded != 2 {
+		t.Errorf("expected only the bytes downloaded by this run to be summed up, got %+v", s)
+	}
+}
+
+func TestJSONL_ServerResponse(t *testing.T) {
+	out := &strings.Builder{}
+	r := newJSONL(out)
+	l := r.Listener(0, "https://example.com/latest")
+	request, _ := http.NewRequest(http.MethodGet, "https://example.com/latest", nil)
+	response := &http.Response{Status: "302 Found", StatusCode: 302, Header: http.Header{"Location": {"/file.zip"}}}
+	response.Request = request
+
+	l.OnResponse(response)
+	if out.Len() != 0 {
+		t.Errorf("expected no response events without --server-response, got\n%s", out.String())
+	}
+
+	r.responses = true
+	l.OnResponse(response)
+	got := events(t, out.String())
+	if len(got) != 1 {
+		t.Fatalf("expected a single event, got %d", len(got))
+	}
+	e := got[0]
+	if e.Event != EventResponse || e.Request != "https://example.com/latest" || e.Code != 302 ||
+		e.Headers.Get("Location") != "/file.zip" {
+		t.Errorf("unexpected response event %+v", e)
+	}
+}
@@ -0,0 +1,260 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"wget/fetch"
+	"wget/globals"
+)
+
+// lines is the Reporter that reports the downloads as plain lines of text, without any escape codes, for outputs
+// that aren't terminals, e.g., log files. Each line is written whole, such that the lines of concurrent downloads
+// never break one another, e.g.,
+//
+//	--2024-10-18 10:00:00--  https://example.com/file.zip
+//	HTTP request sent, awaiting response... 200 OK
+//	Length: 76800 (75.00 KiB)
+//	Saving to: ‘file.zip’
+//
+//	     0K .......... .......... .......... .......... ..........  66% 1.20 MiB/s
+//	    50K .......... .......... .....                            100% 1.40 MiB/s
+//
+//	2024-10-18 10:00:00 (1.30 MiB/s) - ‘file.zip’ saved [76800/76800]
+type lines struct {
+	out   io.Writer
+	mutex sync.Mutex
+	// verbose reports the requests, and responses, of each download, otherwise, a single line per download
+	verbose bool
+	// dots, if not nil, is the layout of the rows of dots reporting the progress of each download
+	dots *Dots
+	// log prefixes the lines about a download with its URL, e.g., `[https://example.com/file.zip] Length: 100`,
+	// for log files, where the lines of concurrent downloads are interleaved; the time isn't repeated either
+	log bool
+	// responses reports the status line, and the headers, of each response, indented, as with --server-response
+	responses bool
+}
+
+// download is the state of a download reported by lines
+type download struct {
+	url, name string
+	// start is the time the download started
+	start time.Time
+	// downloaded and total are the bytes downloaded, and expected, so far; total is -1 if unknown
+	downloaded, total int64
+	// rate is the last reported download rate, in bytes/second
+	rate int32
+	// dots is the number of dots in all the rows of dots, including those not printed yet
+	dots int64
+	// row is the row of dots that is not printed yet
+	row strings.Builder
+}
+
+func (l *lines) Start() {}
+
+func (l *lines) Listener(n int, url string) fetch.AdvancedProgressListener {
+	d := &download{url: url, total: -1}
+
+	listener := listener()
+	listener.OnStart = func(t time.Time) {
+		l.mutex.Lock()
+		d.start = t
+		l.mutex.Unlock()
+		switch {
+		case l.verbose && l.log:
+			// the line is already prefixed by the time it was written, see stamp
+			l.println("--  %s", url)
+		case l.verbose:
+			l.println("--%s--  %s", format(t), url)
+		}
+	}
+	listener.OnResponse = func(response *http.Response) {
+		if l.responses {
+			l.response(url, response)
+		}
+	}
+	listener.OnStatus = func(status string, _ int) {
+		if l.verbose && status != "" {
+			l.println("%sHTTP request sent, awaiting response... %s", l.tag(url), status)
+		}
+	}
+	listener.OnContentLength = func(length int64) {
+		l.mutex.Lock()
+		d.total = length
+		l.mutex.Unlock()
+		if !l.verbose {
+			return
+		}
+		if length < 0 {
+			l.println("%sLength: unspecified", l.tag(url))
+		} else {
+			l.println("%sLength: %d (%s)", l.tag(url), length, globals.FormatSize(length))
+		}
+	}
+	listener.OnGetFile = func(filename string) {
+		l.mutex.Lock()
+		d.name = filename
+		l.mutex.Unlock()
+		if l.verbose {
+			l.println("%sSaving to: ‘%s’\n", l.tag(url), filename)
+		}
+	}
+	listener.OnProgress = func(downloaded, total int64, rate int32) {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		if rate >= 0 {
+			d.rate = rate
+			return
+		}
+		d.downloaded = downloaded
+		if l.verbose && l.dots != nil {
+			l.dot(d, false)
+		}
+	}
+	listener.OnDownloadFinished = func(url string, t time.Time) {
+		if url == "" {
+			// the download failed, see Error
+			return
+		}
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		size := fmt.Sprintf("%d/%d", d.downloaded, d.total)
+		if d.total < 0 {
+			size = fmt.Sprintf("%d", d.downloaded)
+		}
+
+		if !l.verbose {
+			l.write("%sURL:%s [%s] -> \"%s\"\n", l.stamp(t), d.url, size, d.name)
+			return
+		}
+		if l.dots != nil {
+			l.dot(d, true)
+		}
+		rate := d.downloaded
+		if seconds := t.Sub(d.start).Seconds(); seconds >= 1 {
+			rate = int64(float64(d.downloaded) / seconds)
+		}
+		l.write("\n%s%s(%s/s) - ‘%s’ saved [%s]\n\n", l.tag(d.url), l.stamp(t), globals.FormatSize(rate), d.name, size)
+	}
+	return listener
+}
+
+func (l *lines) Error(n int, url string, err error) {
+	l.println("%s: %v", url, err)
+}
+
+func (l *lines) Printf(format string, a ...any) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.write(format, a...)
+}
+
+func (l *lines) Finish() {}
+
+// response prints the status line, and the headers, of the given response to the request of the download of
+// the given URL, indented, as a whole, e.g.,
+//
+//	HTTP/1.1 200 OK
+//	Content-Length: 76800
+func (l *lines) response(url string, response *http.Response) {
+	var text strings.Builder
+	_, _ = fmt.Fprintf(&text, "%s  %s %s\n", l.tag(url), response.Proto, response.Status)
+	keys := make([]string, 0, len(response.Header))
+	for key := range response.Header {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		for _, value := range response.Header[key] {
+			_, _ = fmt.Fprintf(&text, "%s  %s: %s\n", l.tag(url), key, value)
+		}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.write("%s", text.String())
+}
+
+// tag returns the prefix of the lines about the download of the given URL, see lines.log
+func (l *lines) tag(url string) string {
+	if !l.log {
+		return ""
+	}
+	return "[" + url + "] "
+}
+
+// stamp returns the given time, as the lines about downloads start with, or nothing for log files, whose lines
+// are already prefixed by the time they were written, see logfile.Writer
+func (l *lines) stamp(t time.Time) string {
+	if l.log {
+		return ""
+	}
+	return format(t) + " "
+}
+
+// println writes the formatted line, as a whole
+func (l *lines) println(format string, a ...any) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.write(format+"\n", a...)
+}
+
+// write writes the formatted text, the caller must hold the mutex
+func (l *lines) write(format string, a ...any) {
+	_, _ = fmt.Fprintf(l.out, format, a...)
+}
+
+// dot adds the dots of the bytes downloaded since the last call to the row of dots, and prints the complete
+// rows. Once finished, the last row of dots is printed, even if incomplete. The caller must hold the mutex
+func (l *lines) dot(d *download, finished bool) {
+	dots := d.downloaded / l.dots.Size
+	row := int64(l.dots.Row)
+	if d.dots == 0 && dots > row {
+		// the download was continued, skip the rows of the bytes downloaded before
+		d.dots = dots / row * row
+	}
+
+	for ; d.dots < dots; d.dots++ {
+		if d.dots%row == 0 {
+			d.row.Reset()
+			_, _ = fmt.Fprintf(&d.row, "%6dK", d.dots*l.dots.Size/1024)
+		}
+		if d.dots%int64(l.dots.Cluster) == 0 {
+			d.row.WriteByte(' ')
+		}
+		d.row.WriteByte('.')
+		if (d.dots+1)%row == 0 {
+			l.row(d)
+		}
+	}
+
+	if finished && (d.dots%row != 0 || d.dots == 0) {
+		if d.dots == 0 {
+			// no dots at all, e.g., a file smaller than a dot
+			d.row.Reset()
+			_, _ = fmt.Fprintf(&d.row, "%6dK", d.dots*l.dots.Size/1024)
+		}
+		l.row(d)
+	}
+}
+
+// row prints the row of dots of the download, padded to the width of a complete row, followed by the
+// percentage of the download, and the download rate, once known
+func (l *lines) row(d *download) {
+	width := 7 + l.dots.Row + (l.dots.Row+l.dots.Cluster-1)/l.dots.Cluster
+	text := d.row.String()
+	text += strings.Repeat(" ", max(width-len(text), 0))
+	if d.total > 0 {
+		text += fmt.Sprintf(" %3d%%", d.downloaded*100/d.total)
+	}
+	if d.rate > 0 {
+		text += fmt.Sprintf(" %s/s", globals.FormatSize(int64(d.rate)))
+	}
+	l.write("%s%s\n", l.tag(d.url), text)
+	d.row.Reset()
+}
@@ -0,0 +1,131 @@
+package progress
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// simulate reports a download of the given size, in chunks of the given size, to the given reporter
+func simulate(r Reporter, url string, size, chunk int64) {
+	l := r.Listener(0, url)
+	start := time.Date(2024, 10, 18, 10, 0, 0, 0, time.UTC)
+	l.OnStart(start)
+	l.OnStatus("", -1)
+	l.OnStatus("200 OK", 200)
+	l.OnContentLength(size)
+	l.OnGetFile("file.zip")
+	l.OnProgress(-1, -1, 2048)
+	for downloaded := min(chunk, size); ; downloaded = min(downloaded+chunk, size) {
+		l.OnProgress(downloaded, size, -1)
+		if downloaded == size {
+			break
+		}
+	}
+	l.OnDownloadFinished(url, start.Add(2*time.Second))
+}
+
+func TestLines_Dots(t *testing.T) {
+	out := &strings.Builder{}
+	r := &lines{out: out, verbose: true, dots: &DefaultDots}
+	simulate(r, "https://example.com/file.zip", 75*1024, 8*1024)
+
+	want := "--2024-10-18 10:00:00--  https://example.com/file.zip\n" +
+		"HTTP request sent, awaiting response... 200 OK\n" +
+		"Length: 76800 (75.00 KiB)\n" +
+		"Saving to: ‘file.zip’\n" +
+		"\n" +
+		"     0K .......... .......... .......... .......... ..........  74% 2.00 KiB/s\n" +
+		"    50K .......... .......... .....                            100% 2.00 KiB/s\n" +
+		"\n" +
+		"2024-10-18 10:00:02 (37.50 KiB/s) - ‘file.zip’ saved [76800/76800]\n\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+	if strings.Contains(out.String(), "\033") {
+		t.Errorf("expected no escape codes")
+	}
+}
+
+func TestLines_SmallFile(t *testing.T) {
+	out := &strings.Builder{}
+	r := &lines{out: out, verbose: true, dots: &MegaDots}
+	simulate(r, "https://example.com/file.zip", 100, 100)
+
+	if !strings.Contains(out.String(), "     0K"+strings.Repeat(" ", 54)+" 100% 2.00 KiB/s\n") {
+		t.Errorf("expected a single row without dots, got\n%s", out.String())
+	}
+}
+
+func TestLines_Resumed(t *testing.T) {
+	out := &strings.Builder{}
+	r := &lines{out: out, verbose: true, dots: &DefaultDots}
+	l := r.Listener(0, "https://example.com/file.zip")
+	l.OnContentLength(200 * 1024)
+	// the first 128KiB were downloaded before, the rows before the row they end in aren't drawn
+	l.OnProgress(128*1024, 200*1024, -1)
+	l.OnProgress(150*1024, 200*1024, -1)
+
+	if got := out.String(); !strings.HasPrefix(got, "Length: 204800 (200.00 KiB)\n   100K .......... ..........") {
+		t.Errorf("expected the rows of the bytes downloaded before to be skipped, got\n%s", got)
+	}
+}
+
+func TestLines_NoVerbose(t *testing.T) {
+	out := &strings.Builder{}
+	r := &lines{out: out}
+	simulate(r, "https://example.com/file.zip", 1000, 100)
+	r.Error(1, "https://example.com/missing", errors.New("bad status code: 404 Not Found"))
+
+	want := "2024-10-18 10:00:02 URL:https://example.com/file.zip [1000/1000] -> \"file.zip\"\n" +
+		"https://example.com/missing: bad status code: 404 Not Found\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestLines_Log(t *testing.T) {
+	out := &strings.Builder{}
+	r := &lines{out: out, verbose: true, dots: &DefaultDots, log: true}
+	simulate(r, "https://example.com/file.zip", 1000, 1000)
+
+	want := "--  https://example.com/file.zip\n" +
+		"[https://example.com/file.zip] HTTP request sent, awaiting response... 200 OK\n" +
+		"[https://example.com/file.zip] Length: 1000 (1000 B)\n" +
+		"[https://example.com/file.zip] Saving to: ‘file.zip’\n" +
+		"\n" +
+		"[https://example.com/file.zip]      0K" + strings.Repeat(" ", 55) + " 100% 2.00 KiB/s\n" +
+		"\n" +
+		"[https://example.com/file.zip] (500 B/s) - ‘file.zip’ saved [1000/1000]\n\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestLines_ServerResponse(t *testing.T) {
+	out := &strings.Builder{}
+	r := &lines{out: out, responses: true, log: true}
+	l := r.Listener(0, "https://example.com/latest")
+	l.OnResponse(&http.Response{
+		Proto:  "HTTP/1.1",
+		Status: "302 Found",
+		Header: http.Header{"Location": {"/file.zip"}, "Cache-Control": {"no-cache", "private"}},
+	})
+
+	want := "[https://example.com/latest]   HTTP/1.1 302 Found\n" +
+		"[https://example.com/latest]   Cache-Control: no-cache\n" +
+		"[https://example.com/latest]   Cache-Control: private\n" +
+		"[https://example.com/latest]   Location: /file.zip\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	r.responses = false
+	l.OnResponse(&http.Response{Proto: "HTTP/1.1", Status: "200 OK"})
+	if out.Len() != 0 {
+		t.Errorf("expected no headers without --server-response, got\n%s", out.String())
+	}
+}
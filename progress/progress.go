@@ -0,0 +1,202 @@
+// Package progress reports the progress of downloads to the user, drawn as selected by --progress,
+// and as verbose as selected by --quiet, --no-verbose and --verbose.
+//
+// Progress bars are only drawn on terminals; when the output is redirected, e.g., to a CI log, or to the
+// log file of -B, the progress is reported as plain lines of text instead. For tools wrapping this program,
+// --output-format=jsonl reports the progress as JSON events instead, see Event
+package progress
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"wget/fetch"
+	"wget/syscheck"
+)
+
+// The verbosity levels, as selected by --quiet, --no-verbose and --verbose
+const (
+	// Quiet reports nothing at all
+	Quiet = "quiet"
+	// NoVerbose reports a single line per download, and errors
+	NoVerbose = "no-verbose"
+	// Verbose reports the requests, the responses, and the progress of the downloads; the default
+	Verbose = "verbose"
+)
+
+// The output formats, as selected by --output-format
+const (
+	// Text reports the progress to humans, as selected by the verbosity and the Style; the default
+	Text = "text"
+	// JSONL reports each event of the downloads as a JSON object on a line of its own, see Event
+	JSONL = "jsonl"
+)
+
+// The kinds of progress indicators, as selected by --progress
+const (
+	// Bar draws a progress bar per download, moving the terminal cursor around
+	Bar = "bar"
+	// Dot prints rows of dots, a dot per downloaded chunk of bytes
+	Dot = "dot"
+	// None doesn't report the progress of downloads, but still reports the downloads
+	None = "none"
+)
+
+// Reporter reports the progress of downloads. A Reporter may be used by concurrent downloads
+type Reporter interface {
+	// Start is called once, before any download starts
+	Start()
+	// Listener returns the listener reporting the progress of the n-th download, starting from 0, of the
+	// given URL. All the callbacks of the returned listener are set
+	Listener(n int, url string) fetch.AdvancedProgressListener
+	// Error reports that the n-th download, of the given URL, failed, or was skipped, with the given error
+	Error(n int, url string, err error)
+	// Printf reports a message, such as the summary of the downloads
+	Printf(format string, a ...any)
+	// Finish is called once, after all downloads are done, and all the messages about them are reported, to
+	// leave the output ready for whatever is written after
+	Finish()
+}
+
+// Style is a progress indicator, as selected by --progress, e.g., `dot:mega`
+type Style struct {
+	// Kind is the kind of indicator, i.e., Bar, Dot, or None
+	Kind string
+	// Force draws the Bar even when the output isn't a terminal, as with `bar:force`
+	Force bool
+	// Dots is the layout of the dots, of the Dot kind
+	Dots Dots
+}
+
+// Dots is the layout of the rows of dots reporting the progress of a download
+type Dots struct {
+	// Size is the number of bytes a dot stands for
+	Size int64
+	// Cluster is the number of dots in each space separated cluster of dots
+	Cluster int
+	// Row is the number of dots in each row
+	Row int
+}
+
+var (
+	// DefaultDots is the layout of `dot` and `dot:default`: 1KiB a dot, 50KiB a row
+	DefaultDots = Dots{Size: 1 << 10, Cluster: 10, Row: 50}
+	// MegaDots is the layout of `dot:mega`, for large files: 64KiB a dot, 3MiB a row
+	MegaDots = Dots{Size: 64 << 10, Cluster: 8, Row: 48}
+)
+
+// ParseStyle parses the progress indicator given to --progress, i.e., one of `bar`, `bar:force`, `dot`,
+// `dot:default`, `dot:mega` or `none`
+func ParseStyle(spec string) (Style, error) {
+	kind, param, _ := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+	switch {
+	case kind == Bar && (param == "" || param == "force"):
+		return Style{Kind: Bar, Force: param == "force"}, nil
+	case kind == Dot && (param == "" || param == "default"):
+		return Style{Kind: Dot, Dots: DefaultDots}, nil
+	case kind == Dot && param == "mega":
+		return Style{Kind: Dot, Dots: MegaDots}, nil
+	case kind == None && param == "":
+		return Style{Kind: None}, nil
+	default:
+		return Style{}, fmt.Errorf("invalid --progress %q, want bar[:force], dot[:mega] or none", spec)
+	}
+}
+
+// Options selects the Reporter returned by New
+type Options struct {
+	// Format is the output format, i.e., Text (the default, if empty) or JSONL
+	Format string
+	// Verbosity is the verbosity of the Text format, i.e., Quiet, NoVerbose, or Verbose (the default, if empty)
+	Verbosity string
+	// Progress is the progress indicator of the Text format, as given to --progress, see ParseStyle;
+	// the Bar by default
+	Progress string
+	// Log tells that the output is a log file, as given to -o, or -a, thus, the Bar is never drawn, and the
+	// lines about each download are prefixed with its URL
+	Log bool
+	// ServerResponse reports the status line, and the headers, of each response, as with --server-response;
+	// the Bar is then never drawn, as the headers are printed as lines
+	ServerResponse bool
+	// Out is where the reports are written to, instead of the terminal output, e.g., by programs embedding
+	// the downloads; the Bar is then never drawn, as it draws on the terminal
+	Out io.Writer
+}
+
+// New returns the Reporter selected by the given options. The reporter writes to the terminal output, see
+// syscheck.Output, unless Out is given; if that's not a terminal, the Bar falls back to the Dot
+func New(options Options) Reporter {
+	out := syscheck.Output()
+	if options.Out != nil {
+		out = options.Out
+	}
+	if options.Format == JSONL {
+		j := newJSONL(out)
+		j.responses = options.ServerResponse
+		return j
+	}
+
+	style, err := ParseStyle(options.Progress)
+	if err != nil {
+		style = Style{Kind: Bar}
+	}
+
+	switch options.Verbosity {
+	case Quiet:
+		return quiet{}
+	case NoVerbose:
+		return &lines{out: out, log: options.Log, responses: options.ServerResponse}
+	}
+
+	// the bar is only drawn on terminals, and never along the lines of the headers, or to log files
+	lined := options.Log || options.ServerResponse || options.Out != nil
+	if style.Kind == Bar {
+		if file, ok := out.(*os.File); !lined && (style.Force || ok && syscheck.IsTerminal(file)) {
+			return &bar{}
+		}
+		style = Style{Kind: Dot, Dots: DefaultDots}
+	}
+	l := &lines{out: out, verbose: true, log: options.Log, responses: options.ServerResponse}
+	if style.Kind == Dot {
+		l.dots = &style.Dots
+	}
+	return l
+}
+
+// quiet is the Reporter that reports nothing
+type quiet struct{}
+
+func (quiet) Start() {}
+
+func (quiet) Listener(int, string) fetch.AdvancedProgressListener {
+	return listener()
+}
+
+func (quiet) Error(int, string, error) {}
+
+func (quiet) Printf(string, ...any) {}
+
+func (quiet) Finish() {}
+
+// listener returns a listener whose callbacks do nothing, to be overridden as needed
+func listener() fetch.AdvancedProgressListener {
+	return fetch.AdvancedProgressListener{
+		OnStart:            func(time.Time) {},
+		OnResponse:         func(*http.Response) {},
+		OnStatus:           func(string, int) {},
+		OnContentLength:    func(int64) {},
+		OnGetFile:          func(string) {},
+		OnResume:           func(int64) {},
+		OnProgress:         func(int64, int64, int32) {},
+		OnDownloadFinished: func(string, time.Time) {},
+	}
+}
+
+// format formats the given time, as printed in the reports
+func format(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05")
+}
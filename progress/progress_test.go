@@ -0,0 +1,101 @@
+package progress
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"wget/syscheck"
+)
+
+func TestParseStyle(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Style
+		wantErr bool
+	}{
+		{"bar", Style{Kind: Bar}, false},
+		{"bar:force", Style{Kind: Bar, Force: true}, false},
+		{"dot", Style{Kind: Dot, Dots: DefaultDots}, false},
+		{"dot:default", Style{Kind: Dot, Dots: DefaultDots}, false},
+		{"DOT:MEGA", Style{Kind: Dot, Dots: MegaDots}, false},
+		{"none", Style{Kind: None}, false},
+		{"dot:giga", Style{}, true},
+		{"none:force", Style{}, true},
+		{"spinner", Style{}, true},
+		{"", Style{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseStyle(tt.spec)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseStyle(%q) = %+v, %v, want %+v, error %v", tt.spec, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	defer func(out io.Writer) { syscheck.Out = out }(syscheck.Out)
+	// a regular file is never a terminal
+	file, err := os.Create(filepath.Join(t.TempDir(), "wget-log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	syscheck.Out = file
+
+	isLines := func(r Reporter, verbose bool, dots *Dots) bool {
+		l, ok := r.(*lines)
+		if !ok || l.verbose != verbose || (l.dots == nil) != (dots == nil) {
+			return false
+		}
+		return dots == nil || *l.dots == *dots
+	}
+
+	if r := New(Options{Verbosity: Quiet, Progress: "bar:force"}); r != (quiet{}) {
+		t.Errorf("expected --quiet to report nothing, got %T", r)
+	}
+	if r := New(Options{Verbosity: NoVerbose, Progress: "bar"}); !isLines(r, false, nil) {
+		t.Errorf("expected --no-verbose to report lines, got %#v", r)
+	}
+	if r := New(Options{}); !isLines(r, true, &DefaultDots) {
+		t.Errorf("expected the bar to fall back to dots when the output isn't a terminal, got %#v", r)
+	}
+	if r, ok := New(Options{Verbosity: Verbose, Progress: "bar:force"}).(*bar); !ok {
+		t.Errorf("expected bar:force to draw the bar, got %T", r)
+	}
+	if r := New(Options{Verbosity: Verbose, Progress: "dot:mega"}); !isLines(r, true, &MegaDots) {
+		t.Errorf("expected dot:mega to report mega dots, got %#v", r)
+	}
+	if r := New(Options{Verbosity: Verbose, Progress: "none"}); !isLines(r, true, nil) {
+		t.Errorf("expected none to report no dots, got %#v", r)
+	}
+
+	// any other writer isn't a terminal either
+	syscheck.Out = &bytes.Buffer{}
+	if r := New(Options{Verbosity: Verbose, Progress: "bar"}); !isLines(r, true, &DefaultDots) {
+		t.Errorf("expected the bar to fall back to dots when the output isn't a terminal, got %#v", r)
+	}
+	if r := New(Options{Verbosity: Verbose, Progress: "bar:force", Log: true}); !isLines(r, true, &DefaultDots) {
+		t.Errorf("expected the bar never to be drawn to log files, got %#v", r)
+	}
+	if r := New(Options{Verbosity: NoVerbose, Log: true}); !r.(*lines).log {
+		t.Errorf("expected the lines of log files to be prefixed with the URL, got %#v", r)
+	}
+	if r := New(Options{Progress: "bar:force", ServerResponse: true}); !isLines(r, true, &DefaultDots) {
+		t.Errorf("expected the bar never to be drawn along the headers, got %#v", r)
+	}
+	if r, ok := New(Options{Format: JSONL, Verbosity: Quiet}).(*jsonl); !ok {
+		t.Errorf("expected jsonl to report JSON events, even if quiet, got %T", r)
+	}
+
+	// the reports written elsewhere never draw the bar, even to a terminal
+	out := &bytes.Buffer{}
+	if r := New(Options{Progress: "bar:force", Out: out}); !isLines(r, true, &DefaultDots) || r.(*lines).out != out {
+		t.Errorf("expected the bar never to be drawn to Out, got %#v", r)
+	}
+	if r := New(Options{Format: JSONL, Out: out}).(*jsonl); r.out != out {
+		t.Errorf("expected jsonl to write to Out, got %#v", r)
+	}
+}
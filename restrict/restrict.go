@@ -0,0 +1,169 @@
+// Package restrict sanitizes the names of downloaded files, such that names taken from URLs, or from the
+// headers sent by servers, are safe to use in the local filesystem, as selected by --restrict-file-names.
+//
+// The ctx package depends on this package, thus, this package must never import ctx
+package restrict
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mode selects which characters are escaped from filenames. Modes may be combined, e.g., Unix | ASCII.
+// A Mode that includes neither Unix nor Windows, e.g., the zero Mode, includes the Default of the two
+type Mode uint
+
+const (
+	// Unix escapes the '/' character and the control characters
+	Unix Mode = 1 << iota
+	// Windows escapes the characters that are not allowed in Windows filenames, i.e., `\|/:?"*<>`, and
+	// the control characters
+	Windows
+	// NoControl keeps the control characters, which are escaped otherwise
+	NoControl
+	// ASCII escapes all bytes outside the ASCII range
+	ASCII
+	// Lowercase converts all characters to lowercase
+	Lowercase
+)
+
+// MaxLength is the maximum length of a filename, in bytes, on most filesystems. Longer names
+// are shortened, keeping a hash of the whole name to tell apart names sharing a prefix
+const MaxLength = 255
+
+// maxExtension is the longest extension kept when shortening a long filename
+const maxExtension = 16
+
+// Default is the Mode used when --restrict-file-names isn't specified, i.e., Windows on Windows, or Unix
+var Default = func() Mode {
+	if runtime.GOOS == "windows" {
+		return Windows
+	}
+	return Unix
+}()
+
+// modes maps the names of the modes, as given to --restrict-file-names, to the Mode
+var modes = map[string]Mode{
+	"unix":      Unix,
+	"windows":   Windows,
+	"nocontrol": NoControl,
+	"ascii":     ASCII,
+	"lowercase": Lowercase,
+}
+
+// Parse parses the comma separated list of modes given to --restrict-file-names, e.g., "windows,ascii".
+// If neither unix nor windows is listed, the Default of the two is assumed
+func Parse(spec string) (Mode, error) {
+	var m Mode
+	for _, name := range strings.Split(spec, ",") {
+		mode, ok := modes[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return 0, fmt.Errorf("invalid --restrict-file-names mode %q, want unix, windows, nocontrol, ascii or lowercase", name)
+		}
+		m |= mode
+	}
+
+	if m&Unix != 0 && m&Windows != 0 {
+		return 0, fmt.Errorf("invalid --restrict-file-names %q: unix and windows are mutually exclusive", spec)
+	}
+	if m&(Unix|Windows) == 0 {
+		m |= Default
+	}
+	return m, nil
+}
+
+// Name sanitizes a single filename, such that it names a file within the directory it is joined to:
+// the characters selected by the mode are escaped as %XX, along with '%' itself, such that escaped names
+// never collide with the names holding %XX already, the names "." and ".." are escaped, and names longer
+// than MaxLength bytes are shortened
+func (m Mode) Name(name string) string {
+	if m&(Unix|Windows) == 0 {
+		m |= Default
+	}
+	if m&Lowercase != 0 {
+		name = strings.ToLower(name)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; m.escapes(c) {
+			_, _ = fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	name = b.String()
+
+	switch name {
+	case ".":
+		// never refer to the directory itself, nor its parent
+		name = "%2E"
+	case "..":
+		name = "%2E%2E"
+	}
+
+	return shorten(name)
+}
+
+// Path sanitizes a slash separated, relative path, e.g., the path of a URL. The path is cleaned, such that
+// it can't refer to anything outside the directory it is joined to, then, each of its elements is
+// sanitized, see Mode.Name. The returned path uses the OS specific separator
+func (m Mode) Path(p string) string {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return ""
+	}
+
+	elements := strings.Split(p, "/")
+	for i, element := range elements {
+		elements[i] = m.Name(element)
+	}
+	return filepath.Join(elements...)
+}
+
+// escapes reports whether the given byte should be escaped from filenames in this mode
+func (m Mode) escapes(c byte) bool {
+	switch {
+	case c == '/' || c == '%':
+		return true
+	case c < 32 || c == 127:
+		return m&NoControl == 0
+	case c >= utf8.RuneSelf:
+		return m&ASCII != 0
+	case m&Windows != 0:
+		return strings.IndexByte(`\|:?"*<>`, c) >= 0
+	default:
+		return false
+	}
+}
+
+// shorten cuts names longer than MaxLength bytes down to MaxLength bytes, replacing
+// their tail with a hash of the whole name, and keeping their extension, if short
+func shorten(name string) string {
+	if len(name) <= MaxLength {
+		return name
+	}
+
+	extension := filepath.Ext(name)
+	if len(extension) > maxExtension {
+		extension = ""
+	}
+	hash := fmt.Sprintf("-%x", sha256.Sum256([]byte(name)))[:9]
+
+	prefix := name[:MaxLength-len(hash)-len(extension)]
+	// don't split a multibyte character, drop the bytes of the last one if it was cut short
+	for i := len(prefix) - 1; i >= max(len(prefix)-utf8.UTFMax, 0); i-- {
+		if utf8.RuneStart(prefix[i]) {
+			if !utf8.FullRuneInString(prefix[i:]) {
+				prefix = prefix[:i]
+			}
+			break
+		}
+	}
+	return prefix + hash + extension
+}
@@ -0,0 +1,106 @@
+package restrict
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Mode
+		wantErr bool
+	}{
+		{"unix", Unix, false},
+		{"windows", Windows, false},
+		{"windows,ascii", Windows | ASCII, false},
+		{"unix, nocontrol", Unix | NoControl, false},
+		{"lowercase", Default | Lowercase, false},
+		{"ascii,lowercase", Default | ASCII | Lowercase, false},
+		{"unix,windows", 0, true},
+		{"dos", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.spec)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Parse(%q) = %v, %v, want %v, error %v", tt.spec, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMode_Name(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		name string
+		want string
+	}{
+		{Unix, "file.txt", "file.txt"},
+		{Unix, "../../.bashrc", "..%2F..%2F.bashrc"},
+		{Unix, "..%2F..%2F.bashrc", "..%252F..%252F.bashrc"},
+		{Unix, "100%.txt", "100%25.txt"},
+		{Unix, "..", "%2E%2E"},
+		{Unix, ".", "%2E"},
+		{Unix, "bell\a.txt", "bell%07.txt"},
+		{Unix | NoControl, "bell\a.txt", "bell\a.txt"},
+		{Unix, `what?.txt`, `what?.txt`},
+		{Windows, `what?.txt`, `what%3F.txt`},
+		{Windows, `a\b:c*d"e<f>g|h`, `a%5Cb%3Ac%2Ad%22e%3Cf%3Eg%7Ch`},
+		{Unix, "naïve.txt", "naïve.txt"},
+		{Unix | ASCII, "naïve.txt", "na%C3%AFve.txt"},
+		{Unix | Lowercase, "README.MD", "readme.md"},
+		{Unix | ASCII | Lowercase, "NAÏVE", "na%C3%AFve"},
+	}
+	for _, tt := range tests {
+		if got := tt.mode.Name(tt.name); got != tt.want {
+			t.Errorf("Mode(%v).Name(%q) = %q, want %q", tt.mode, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMode_NameLength(t *testing.T) {
+	long := strings.Repeat("a", 300) + ".tar.gz"
+	got := Unix.Name(long)
+	if len(got) != MaxLength {
+		t.Errorf("expected the name to be shortened to %d bytes, got %d", MaxLength, len(got))
+	}
+	if !strings.HasSuffix(got, ".gz") || !strings.HasPrefix(got, "aaaa") {
+		t.Errorf("expected the shortened name to keep its prefix and extension, got %q", got)
+	}
+	// names sharing a long prefix are still told apart
+	if other := Unix.Name(strings.Repeat("a", 301) + ".tar.gz"); other == got {
+		t.Errorf("expected different long names to be shortened differently, got %q", got)
+	}
+	// multibyte characters are never split
+	if got := Unix.Name(strings.Repeat("é", 200)); len(got) > MaxLength || !utf8.ValidString(got) {
+		t.Errorf("expected a valid name of at most %d bytes, got %d bytes %q", MaxLength, len(got), got)
+	}
+	// the invalid bytes of the name are kept, only the character cut short is dropped
+	invalid := "\xff" + strings.Repeat("é", 200)
+	if got := Unix.Name(invalid); !strings.HasPrefix(got, "\xffé") || len(got) < MaxLength-1 {
+		t.Errorf("expected the name to keep its prefix, got %d bytes %q", len(got), got)
+	}
+}
+
+func TestMode_Path(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		path string
+		want string
+	}{
+		{Unix, "/a/b/file.txt", "a/b/file.txt"},
+		{Unix, "a/b/", "a/b"},
+		{Unix, "/../../etc/passwd", "etc/passwd"},
+		{Unix, "/a/../../b", "b"},
+		{Unix, "/", ""},
+		{Windows, "/docs/what?/file:1", "docs/what%3F/file%3A1"},
+		{Unix | Lowercase, "/Docs/File.TXT", "docs/file.txt"},
+	}
+	for _, tt := range tests {
+		if got := tt.mode.Path(tt.path); got != filepath.FromSlash(tt.want) {
+			t.Errorf("Mode(%v).Path(%q) = %q, want %q", tt.mode, tt.path, got, tt.want)
+		}
+	}
+}
@@ -3,20 +3,48 @@ package syscheck
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sync/atomic"
 	"syscall"
 	"unsafe"
 )
 
 var (
 	// HideCursor hides the terminal cursor to avoid it from blinking
-	HideCursor = from("\033[?25l")
-	// ShowCursor makes the cursor visible again
-	ShowCursor = from("\033[?25h")
+	HideCursor = func() {
+		cursorHidden.Store(true)
+		hideCursor()
+	}
+	// ShowCursor makes the cursor visible again, if it was hidden by HideCursor; otherwise, it does
+	// nothing, such that no escape codes are written to outputs that aren't terminals, e.g., log files
+	ShowCursor = func() {
+		if cursorHidden.Swap(false) {
+			showCursor()
+		}
+	}
 	// ClearScreen clears the terminal screen.
 	ClearScreen = from("\033[2J")
 	// MoveCursor moves the terminal cursor to the specified row, we don't need columns here
 	MoveCursor func(row int)
+	// Out is where the terminal output, e.g., the progress of the downloads, is written to; nil means os.Stdout.
+	// Set it to os.Stderr when stdout carries the downloaded contents, as with -O=-
+	Out io.Writer
+)
+
+// Output returns the writer the terminal output is written to, i.e., Out, or os.Stdout if Out is nil
+func Output() io.Writer {
+	if Out == nil {
+		return os.Stdout
+	}
+	return Out
+}
+
+var (
+	hideCursor = from("\033[?25l")
+	showCursor = from("\033[?25h")
+	// cursorHidden records whether the cursor was hidden by HideCursor
+	cursorHidden atomic.Bool
 )
 
 func init() {
@@ -52,6 +80,9 @@ type terminal struct {
 // if the syscall fails we return an error
 func GetTerminalWidth() int {
 	fd := os.Stdout.Fd()
+	if out, ok := Output().(*os.File); ok {
+		fd = out.Fd()
+	}
 	ws := &terminal{}
 	_, _, _ = syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(ws)))
 	width := int(ws.Col)
@@ -61,14 +92,21 @@ func GetTerminalWidth() int {
 	return width
 }
 
+// IsTerminal reports whether the given file is a terminal, rather than, e.g., a pipe, or a log file
+func IsTerminal(file *os.File) bool {
+	ws := &terminal{}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(ws)))
+	return errno == 0
+}
+
 func from(format string) func() {
 	return func() {
-		fmt.Printf(format)
+		_, _ = fmt.Fprint(Output(), format)
 	}
 }
 
 func fromArg(format string) func(int) {
 	return func(arg int) {
-		fmt.Printf(format, arg)
+		_, _ = fmt.Fprintf(Output(), format, arg)
 	}
 }
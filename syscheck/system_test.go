@@ -2,6 +2,7 @@ package syscheck
 
 import (
 	"os"
+	"strings"
 	"testing"
 	"wget/temp"
 )
@@ -59,3 +60,43 @@ func Test_fromArg(t *testing.T) {
 	// Output:
 	// row 10
 }
+
+func TestOutput(t *testing.T) {
+	defer func() { Out = nil }()
+
+	if Output() != os.Stdout {
+		t.Errorf("expected the terminal output to default to stdout")
+	}
+	Out = os.Stderr
+	if Output() != os.Stderr {
+		t.Errorf("expected the terminal output to be redirected to stderr")
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	file, err := temp.File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	if IsTerminal(file) {
+		t.Errorf("expected a regular file not to be a terminal")
+	}
+}
+
+func TestShowCursor(t *testing.T) {
+	defer func() { Out = nil }()
+	out := &strings.Builder{}
+	Out = out
+
+	ShowCursor()
+	if out.Len() != 0 {
+		t.Errorf("expected the cursor not to be shown, unless hidden, got %q", out.String())
+	}
+	HideCursor()
+	ShowCursor()
+	if out.String() != "\033[?25l\033[?25h" {
+		t.Errorf("expected the cursor to be hidden, then shown, got %q", out.String())
+	}
+}
@@ -0,0 +1,152 @@
+package wget
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"wget/fetch"
+	"wget/progress"
+)
+
+// Result is the result of a download
+type Result struct {
+	// URL is the URL downloaded
+	URL string
+	// Path is the file the download was saved to; empty if it wasn't saved, or was written to a writer
+	Path string
+	// Status and StatusCode are the status of the response, e.g., "200 OK" and 200, after any redirects
+	Status     string
+	StatusCode int
+	// Size is the number of bytes downloaded, and Total the size of the file, -1 if unknown
+	Size  int64
+	Total int64
+	// Start and End are the times the download started, and finished
+	Start time.Time
+	End   time.Time
+	// OK tells whether the download finished successfully
+	OK bool
+	// Err is the error the download failed with, or was skipped with, if known; the errors of the mirrored
+	// pages are only returned by Client.Mirror
+	Err error
+}
+
+// MirrorResult is the result of a mirror
+type MirrorResult struct {
+	// URL is the URL of the website mirrored, and Dir the directory it was mirrored into
+	URL string
+	Dir string
+	// Files are the results of the files mirrored, in the order they were downloaded
+	Files []Result
+	// Bytes is the number of bytes downloaded by the mirror
+	Bytes int64
+	// Failed is the number of files that failed to download
+	Failed int
+}
+
+// recorder records the Result of each download, while reporting their progress to the given reporter
+type recorder struct {
+	progress.Reporter
+	mutex sync.Mutex
+	// recorded maps the numbers of the downloads, as given to Listener, to their results
+	recorded map[int]*Result
+}
+
+func newRecorder(reporter progress.Reporter) *recorder {
+	return &recorder{Reporter: reporter, recorded: map[int]*Result{}}
+}
+
+func (r *recorder) Listener(n int, url string) fetch.AdvancedProgressListener {
+	l := r.Reporter.Listener(n, url)
+	record := func(update func(result *Result)) {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		update(r.result(n, url))
+	}
+	record(func(*Result) {})
+
+	onStart, onStatus, onContentLength := l.OnStart, l.OnStatus, l.OnContentLength
+	onGetFile, onProgress, onDownloadFinished := l.OnGetFile, l.OnProgress, l.OnDownloadFinished
+	l.OnStart = func(t time.Time) {
+		record(func(result *Result) { result.Start = t })
+		call(onStart, t)
+	}
+	l.OnStatus = func(status string, code int) {
+		record(func(result *Result) { result.Status, result.StatusCode = status, code })
+		if onStatus != nil {
+			onStatus(status, code)
+		}
+	}
+	l.OnContentLength = func(length int64) {
+		record(func(result *Result) { result.Total = length })
+		call(onContentLength, length)
+	}
+	l.OnGetFile = func(filename string) {
+		record(func(result *Result) {
+			if filename != "STDOUT" {
+				result.Path = filename
+			}
+		})
+		call(onGetFile, filename)
+	}
+	l.OnProgress = func(bytes, total int64, rate int32) {
+		if rate < 0 {
+			record(func(result *Result) { result.Size = bytes })
+		}
+		if onProgress != nil {
+			onProgress(bytes, total, rate)
+		}
+	}
+	l.OnDownloadFinished = func(finalUrl string, t time.Time) {
+		// the URL is empty if the download failed
+		record(func(result *Result) { result.End, result.OK = t, finalUrl != "" })
+		if onDownloadFinished != nil {
+			onDownloadFinished(finalUrl, t)
+		}
+	}
+	if l.OnResponse == nil {
+		l.OnResponse = func(*http.Response) {}
+	}
+	return l
+}
+
+func (r *recorder) Error(n int, url string, err error) {
+	r.mutex.Lock()
+	result := r.result(n, url)
+	result.Err, result.OK = err, false
+	r.mutex.Unlock()
+	r.Reporter.Error(n, url, err)
+}
+
+// result returns the result of the n-th download, of the given URL; the caller must hold the mutex
+func (r *recorder) result(n int, url string) *Result {
+	result, ok := r.recorded[n]
+	if !ok {
+		result = &Result{URL: url, Total: -1}
+		r.recorded[n] = result
+	}
+	return result
+}
+
+// results returns the results recorded, by the order of the downloads
+func (r *recorder) results() []Result {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	ids := make([]int, 0, len(r.recorded))
+	for n := range r.recorded {
+		ids = append(ids, n)
+	}
+	sort.Ints(ids)
+	results := make([]Result, len(ids))
+	for i, n := range ids {
+		results[i] = *r.recorded[n]
+	}
+	return results
+}
+
+// call calls the given callback of a listener, with the given value, unless nil
+func call[T any](callback func(T), value T) {
+	if callback != nil {
+		callback(value)
+	}
+}
@@ -0,0 +1,252 @@
+// Package wget is the library of the downloads, for the Go programs embedding them: a Client downloads files,
+// and mirrors websites, as the command line does, reporting their progress to a pluggable progress.Reporter,
+// and returning the results of each download.
+//
+// The calls of a Client share its rate limit, its quota, and the wait between requests to the same host, as the
+// URLs given to a single command line do.
+//
+// The package never writes to stdout, nor exits the program; the failures are returned as errors, whose exit
+// status, as of the command line, is given by xerr.ExitStatus. For example:
+//
+//	client := wget.New(wget.Options{RateLimit: 500_000, Continue: true})
+//	result, err := client.Download(ctx, "https://example.com/file.zip", "downloads/")
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println("saved", result.Path, result.Size)
+package wget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"wget/ctx"
+	"wget/downloader"
+	"wget/limitedio"
+	"wget/pace"
+	"wget/progress"
+	"wget/restrict"
+	"wget/xurl"
+)
+
+// Run downloads the files, or mirrors the websites, of the given download context, as parsed from the command
+// line by args.Parse, reporting their progress to the terminal, as selected by the context, e.g., by --quiet.
+// Unlike the Client, Run writes to the terminal, and to stdout with -O=-: it's what the command line runs.
+// The reporters are wrapped by track, unless nil, e.g., to record the progress of a background job
+func Run(cx context.Context, settings ctx.Context, track func(progress.Reporter) progress.Reporter) error {
+	return downloader.Get(cx, settings, track)
+}
+
+// Options are the options of a Client, as the flags of the command line of the same names
+type Options struct {
+	// RateLimit limits the bandwidth of all the calls, in bytes/second; unlimited if 0. RateBurst bytes may be
+	// downloaded at once, above the rate limit, after being idle; defaults to the rate limit
+	RateLimit int64
+	RateBurst int64
+	// FileRateLimit limits the bandwidth of each file, in bytes/second, within the RateLimit; unlimited if 0
+	FileRateLimit int64
+	// Wait is the delay between requests to the same host; randomized to between 0.5 and 1.5 times
+	// its value, if RandomWait
+	Wait       time.Duration
+	RandomWait bool
+	// Quota stops starting new downloads once the files downloaded by all the calls exceed it, in bytes
+	Quota int64
+	// MaxFileSize skips the files larger than it, in bytes
+	MaxFileSize int64
+	// MinFreeSpace doesn't start the downloads that would leave less free disk space than it, in bytes
+	MinFreeSpace int64
+	// Continue continues the partial files of interrupted downloads; KeepPartial keeps those of failed ones
+	Continue    bool
+	KeepPartial bool
+	// Clobber names what happens to existing files: "overwrite", "no-clobber", "backups", keeping up
+	// to Backups backups, or "unique-names"; by default, files get unique names, and mirrors overwrite them
+	Clobber string
+	Backups int
+	// ContentDisposition names the files after the Content-Disposition header of the responses, if any
+	ContentDisposition bool
+	// AdjustExtension appends .html, or .css, to the names of the HTML and CSS files lacking the extension
+	AdjustExtension bool
+	// RestrictFileNames escapes the characters of the file names unsafe in the given mode, see restrict.Parse
+	RestrictFileNames restrict.Mode
+	// SaveHeaders saves the headers of the files, as fetch.HeadersInline or fetch.HeadersSidecar
+	SaveHeaders string
+
+	// Reject and Exclude are the suffixes of the files, and the directories, not to mirror
+	Reject  []string
+	Exclude []string
+	// ConvertLinks converts the links of the mirrored pages, to make them suitable for local viewing
+	ConvertLinks bool
+	// NoDirectories, NoHostDirectories, ProtocolDirectories, and CutDirs decide the paths of the mirrored files,
+	// as --no-directories, --no-host-directories, --protocol-directories, and --cut-dirs do
+	NoDirectories       bool
+	NoHostDirectories   bool
+	ProtocolDirectories bool
+	CutDirs             int
+	// IgnoreQueryParams are the glob patterns of the query parameters dropped from the mirrored URLs
+	IgnoreQueryParams []string
+
+	// Reporter reports the progress of the downloads, e.g., progress.New(progress.Options{Out: w});
+	// nothing is reported if nil
+	Reporter progress.Reporter
+}
+
+// Client downloads files, and mirrors websites, with its Options. Its methods may be called concurrently;
+// the rate limit, the quota, and the wait between requests, apply to all the calls together
+type Client struct {
+	options Options
+	// limiter, pacer, and quota are shared by all the calls, see downloader.Options
+	limiter *limitedio.SharedLimiter
+	pacer   *pace.Pacer
+	quota   *limitedio.Quota
+}
+
+// New returns a Client with the given options
+func New(options Options) *Client {
+	return &Client{
+		options: options,
+		limiter: limitedio.NewSharedLimiter(int32(options.RateLimit), options.RateBurst),
+		pacer:   pace.New(options.Wait, options.RandomWait),
+		quota:   limitedio.NewQuota(options.Quota),
+	}
+}
+
+// Download downloads the file at the given URL to dst: a file, or a directory, if dst names an existing one,
+// or ends with a slash, where the file is named after the URL; or the current directory, if dst is empty.
+// The directories of dst are created as needed.
+//
+// Once the given context is done, the download is stopped, keeping the partial file to be continued
+// later; the returned error then wraps the context's error
+func (c *Client) Download(cx context.Context, url, dst string) (*Result, error) {
+	settings, err := c.settings(url)
+	if err != nil {
+		return nil, err
+	}
+	dir := dst
+	if info, err := os.Stat(dst); dst != "" && (err != nil || !info.IsDir()) && !strings.HasSuffix(dst, "/") {
+		dir, settings.OutputFile = filepath.Split(dst)
+		if settings.OutputFile == downloader.Stdout {
+			return nil, fmt.Errorf("invalid destination %q, use DownloadTo to write to a writer", dst)
+		}
+	}
+	if settings.SavePath, err = directory(dir); err != nil {
+		return nil, err
+	}
+	results, err := c.run(cx, settings, io.Discard)
+	return first(results, url), err
+}
+
+// DownloadTo downloads the file at the given URL, writing its contents to w, rather than to a file
+func (c *Client) DownloadTo(cx context.Context, url string, w io.Writer) (*Result, error) {
+	settings, err := c.settings(url)
+	if err != nil {
+		return nil, err
+	}
+	settings.OutputFile = downloader.Stdout
+	results, err := c.run(cx, settings, w)
+	return first(results, url), err
+}
+
+// Mirror mirrors the website at the given URL into the given directory, the current directory if empty,
+// following the links of its pages, and returns the result of each file. The directory is created as needed.
+//
+// Once the given context is done, the mirror is stopped, keeping the partial file of the active download
+// to be continued later; the returned error then wraps the context's error
+func (c *Client) Mirror(cx context.Context, url, dir string) (*MirrorResult, error) {
+	settings, err := c.settings(url)
+	if err != nil {
+		return nil, err
+	}
+	settings.Mirror = true
+	if settings.SavePath, err = directory(dir); err != nil {
+		return nil, err
+	}
+	results, err := c.run(cx, settings, io.Discard)
+
+	mirrored := &MirrorResult{URL: settings.Links[0], Dir: settings.SavePath, Files: results}
+	for _, result := range results {
+		mirrored.Bytes += result.Size
+		if !result.OK {
+			mirrored.Failed++
+		}
+	}
+	return mirrored, err
+}
+
+// settings returns the download context of the options, for downloading the given URL
+func (c *Client) settings(url string) (ctx.Context, error) {
+	link, ok, err := xurl.IsValidURL(url)
+	if !ok {
+		if err == nil {
+			err = errors.New("invalid URL")
+		}
+		return ctx.Context{}, fmt.Errorf("%q: %w", url, err)
+	}
+	o := c.options
+	return ctx.Context{
+		Links:                  []string{link},
+		Verbosity:              progress.Quiet,
+		RateLimitValue:         o.RateLimit,
+		RateBurstValue:         o.RateBurst,
+		FileRateLimitValue:     o.FileRateLimit,
+		Wait:                   o.Wait,
+		RandomWait:             o.RandomWait,
+		QuotaValue:             o.Quota,
+		MaxFileSizeValue:       o.MaxFileSize,
+		MinFreeSpaceValue:      o.MinFreeSpace,
+		Continue:               o.Continue,
+		KeepPartial:            o.KeepPartial,
+		Clobber:                o.Clobber,
+		Backups:                o.Backups,
+		ContentDisposition:     o.ContentDisposition,
+		AdjustExtension:        o.AdjustExtension,
+		RestrictFileNamesValue: o.RestrictFileNames,
+		SaveHeaders:            o.SaveHeaders,
+		Rejects:                o.Reject,
+		Exclude:                o.Exclude,
+		ConvertLinks:           o.ConvertLinks,
+		NoDirectories:          o.NoDirectories,
+		NoHostDirectories:      o.NoHostDirectories,
+		ProtocolDirectories:    o.ProtocolDirectories,
+		CutDirs:                o.CutDirs,
+		IgnoreQueryParams:      o.IgnoreQueryParams,
+	}, nil
+}
+
+// run runs the downloads of the given download context, recording their results
+func (c *Client) run(cx context.Context, settings ctx.Context, stdout io.Writer) ([]Result, error) {
+	reporter := c.options.Reporter
+	if reporter == nil {
+		reporter = progress.New(progress.Options{Verbosity: progress.Quiet})
+	}
+	r := newRecorder(reporter)
+	err := downloader.Run(cx, settings, downloader.Options{
+		Reporter: func() progress.Reporter { return r },
+		Stdout:   stdout,
+		Limiter:  c.limiter,
+		Pacer:    c.pacer,
+		Quota:    c.quota,
+	})
+	return r.results(), err
+}
+
+// directory creates the given directory, if not empty, and returns it
+func directory(dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// first returns the first of the given results, or the result of the given URL, if there is none,
+// e.g., as the context was done before the download started
+func first(results []Result, url string) *Result {
+	if len(results) == 0 {
+		return &Result{URL: url, Total: -1}
+	}
+	return &results[0]
+}
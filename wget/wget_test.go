@@ -0,0 +1,178 @@
+package wget
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"wget/progress"
+	"wget/xerr"
+)
+
+// newServer serves a small website, of an index linking to a page and a missing file
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/", "/index.html":
+					w.Header().Set("Content-Type", "text/html")
+					_, _ = fmt.Fprint(w, `<a href="/page.html">page</a> <a href="/missing.txt">missing</a>`)
+				case "/page.html":
+					w.Header().Set("Content-Type", "text/html")
+					_, _ = fmt.Fprint(w, "<p>page</p>")
+				case "/file.txt":
+					_, _ = fmt.Fprint(w, "contents")
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			},
+		),
+	)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_Download(t *testing.T) {
+	server := newServer(t)
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		dst  string
+		want string
+	}{
+		{"directory", dir, filepath.Join(dir, "file.txt")},
+		{"new directory", filepath.Join(dir, "new") + "/", filepath.Join(dir, "new", "file.txt")},
+		{"file", filepath.Join(dir, "sub", "saved.txt"), filepath.Join(dir, "sub", "saved.txt")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := New(Options{}).Download(context.Background(), server.URL+"/file.txt", tt.dst)
+			if err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			if result.Path != tt.want || !result.OK || result.Size != 8 || result.Total != 8 {
+				t.Errorf("Download() = %+v, want %s saved", result, tt.want)
+			}
+			if result.StatusCode != http.StatusOK || result.End.Before(result.Start) {
+				t.Errorf("Download() = %+v, want the status and times of the download", result)
+			}
+			if got, _ := os.ReadFile(tt.want); string(got) != "contents" {
+				t.Errorf("expected the contents to be saved, got %q", got)
+			}
+		})
+	}
+}
+
+func TestClient_Download_Errors(t *testing.T) {
+	server := newServer(t)
+	client := New(Options{})
+
+	result, err := client.Download(context.Background(), server.URL+"/missing.txt", t.TempDir())
+	if err == nil || xerr.ExitStatus(err) != xerr.ServerStatus {
+		t.Fatalf("Download() error = %v, want a server error", err)
+	}
+	if result.OK || result.StatusCode != http.StatusNotFound || result.Err == nil {
+		t.Errorf("Download() = %+v, want the failure recorded", result)
+	}
+
+	if _, err = client.Download(context.Background(), "not a URL", t.TempDir()); err == nil {
+		t.Error("Download() error = nil, want an invalid URL")
+	}
+	if _, err = client.Download(context.Background(), server.URL+"/file.txt", "dir/-"); err == nil {
+		t.Error("Download() error = nil, want an invalid destination")
+	}
+
+	cx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = client.Download(cx, server.URL+"/file.txt", t.TempDir()); err == nil {
+		t.Error("Download() error = nil, want the context's error")
+	}
+}
+
+func TestClient_DownloadTo(t *testing.T) {
+	server := newServer(t)
+	var buffer bytes.Buffer
+	result, err := New(Options{}).DownloadTo(context.Background(), server.URL+"/file.txt", &buffer)
+	if err != nil {
+		t.Fatalf("DownloadTo() error = %v", err)
+	}
+	if buffer.String() != "contents" {
+		t.Errorf("DownloadTo() wrote %q, want the contents", buffer.String())
+	}
+	if result.Path != "" || !result.OK || result.Size != 8 {
+		t.Errorf("DownloadTo() = %+v, want no file saved", result)
+	}
+}
+
+func TestClient_Mirror(t *testing.T) {
+	server := newServer(t)
+	dir := filepath.Join(t.TempDir(), "site")
+	result, err := New(Options{NoHostDirectories: true}).Mirror(context.Background(), server.URL+"/", dir)
+	if err == nil {
+		t.Error("Mirror() error = nil, want the missing file reported")
+	}
+	if result.Dir != dir || len(result.Files) != 3 || result.Failed != 1 {
+		t.Fatalf("Mirror() = %+v, want 3 files, 1 failed", result)
+	}
+
+	saved := 0
+	for _, file := range result.Files {
+		if file.OK {
+			saved++
+			if _, err := os.Stat(file.Path); err != nil || !strings.HasPrefix(file.Path, dir) {
+				t.Errorf("expected %s to be saved into %s, got %v", file.Path, dir, err)
+			}
+		}
+	}
+	if saved != 2 || result.Bytes == 0 {
+		t.Errorf("Mirror() = %+v, want the index and the page saved", result)
+	}
+}
+
+func TestClient_Reporter(t *testing.T) {
+	server := newServer(t)
+	var out bytes.Buffer
+	client := New(Options{Reporter: progress.New(progress.Options{Out: &out, Verbosity: progress.NoVerbose})})
+	if _, err := client.Download(context.Background(), server.URL+"/file.txt", t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "file.txt") {
+		t.Errorf("expected the download to be reported, got %q", out.String())
+	}
+}
+
+func TestClient_Shared(t *testing.T) {
+	server := newServer(t)
+	const wait = 300 * time.Millisecond
+	client := New(Options{Quota: 5, Wait: wait})
+	dir := t.TempDir()
+
+	// the first call exceeds the quota, but completes its download
+	if _, err := client.Download(context.Background(), server.URL+"/file.txt", dir); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	_, err := client.Download(context.Background(), server.URL+"/file.txt", dir)
+	if status := xerr.ExitStatus(err); status != xerr.QuotaExceededStatus {
+		t.Errorf("expected the quota of the client to be exceeded by the previous call, got %d: %v", status, err)
+	}
+
+	// a new client has a quota of its own, but waits between the requests of its calls to the same host
+	client = New(Options{Wait: wait})
+	started := time.Now()
+	for range 2 {
+		if _, err = client.Download(context.Background(), server.URL+"/file.txt", dir); err != nil {
+			t.Fatalf("Download() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed < wait {
+		t.Errorf("expected the calls to wait %v between the requests to the same host, took %v", wait, elapsed)
+	}
+}
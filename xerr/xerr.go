@@ -2,9 +2,11 @@
 package xerr
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"syscall"
 )
 
 // common error types are defined here with their respective messages
@@ -25,6 +27,46 @@ var (
 	ErrWrongPath = errors.New("invalid path")
 
 	ErrRelativeURL = errors.New("relative path")
+
+	// ErrQuotaExceeded is returned when the total size of the downloaded files exceeds the download quota (--quota)
+	ErrQuotaExceeded = errors.New("download quota exceeded")
+
+	// ErrInsufficientSpace is returned when there isn't enough free disk space to save a file to be downloaded
+	ErrInsufficientSpace = errors.New("insufficient free disk space")
+
+	// ErrFileTooLarge is returned when a file to be downloaded is larger than the maximum file size (--max-filesize)
+	ErrFileTooLarge = errors.New("file exceeds the maximum file size")
+)
+
+// exit statuses of failures, as documented by GNU Wget. When there are several failures, e.g., of several
+// downloads, the program exits with the status of the most severe, see ExitStatus
+const (
+	// GenericStatus is the exit status of any failure that has no more specific status
+	GenericStatus = 1
+	// ParseStatus is the exit status when the command-line options, or the configuration files, can't be parsed
+	ParseStatus = 2
+	// IOStatus is the exit status when a file can't be read, or written, e.g., the downloaded file
+	IOStatus = 3
+	// NetworkStatus is the exit status when the network failed, e.g., the host can't be resolved, or reached
+	NetworkStatus = 4
+	// TLSStatus is the exit status when the TLS handshake failed, e.g., the server certificate can't be verified
+	TLSStatus = 5
+	// AuthStatus is the exit status when the server asked for authentication, i.e., 401 or 407
+	AuthStatus = 6
+	// ProtocolStatus is the exit status when the server didn't speak HTTP as expected, e.g., too many redirects
+	ProtocolStatus = 7
+	// ServerStatus is the exit status when the server responded with an error, e.g., 404 or 503
+	ServerStatus = 8
+)
+
+// exit statuses for failures that are specific to this program
+const (
+	// QuotaExceededStatus is the exit status when the download quota (--quota) was exceeded
+	QuotaExceededStatus = 9
+	// FileTooLargeStatus is the exit status when some file was larger than the maximum file size (--max-filesize)
+	FileTooLargeStatus = 10
+	// InterruptedStatus is the exit status when the program is interrupted, e.g., by Ctrl-C; 128 + SIGINT
+	InterruptedStatus = 130
 )
 
 // WriteError takes errorMessage of any type and statusCode
@@ -39,3 +81,171 @@ func writeError(errorMessage interface{}, statusCode int, shouldExit bool, exit
 		exit(statusCode)
 	}
 }
+
+// StatusError is an error that exits the program with the given Status, see ExitStatus
+type StatusError struct {
+	Status int
+	Err    error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// WithStatus returns the given error, that exits the program with the given status, see ExitStatus;
+// nil if the error is nil
+func WithStatus(status int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &StatusError{Status: status, Err: err}
+}
+
+// SignalError is the cause of the interruption of the program by the Signal, e.g., of the context canceled
+// as the signal arrived, see context.WithCancelCause; the program then exits with the status of the signal
+type SignalError struct {
+	Signal os.Signal
+}
+
+func (e *SignalError) Error() string {
+	return fmt.Sprintf("interrupted by %v", e.Signal)
+}
+
+// Status returns the exit status of the program interrupted by the signal: 128 + the number of the signal,
+// e.g., 143 for SIGTERM; or InterruptedStatus, if the signal has no number
+func (e *SignalError) Status() int {
+	if number, ok := e.Signal.(syscall.Signal); ok {
+		return 128 + int(number)
+	}
+	return InterruptedStatus
+}
+
+// interruption is the failure of a program interrupted by a signal, see Interrupted
+type interruption struct {
+	error
+	signal *SignalError
+}
+
+func (e *interruption) Unwrap() []error {
+	return []error{e.error, e.signal}
+}
+
+// Interrupted returns the given error, wrapping the given cause of the interruption too, e.g., as returned by
+// context.Cause, if the error wraps context.Canceled, and the cause is a SignalError, such that the program
+// exits with the status of the signal, see ExitStatus; otherwise, the error is returned as is
+func Interrupted(err, cause error) error {
+	var signal *SignalError
+	if !errors.Is(err, context.Canceled) || !errors.As(cause, &signal) {
+		return err
+	}
+	return &interruption{error: err, signal: signal}
+}
+
+// failures is the error of several failures, e.g., of several downloads, see Join
+type failures struct {
+	message string
+	errs    []error
+}
+
+func (e *failures) Error() string {
+	return e.message
+}
+
+func (e *failures) Unwrap() []error {
+	return e.errs
+}
+
+// Join returns the error of the given failures, that reads as the given message, such that errors.Is, and
+// ExitStatus, consider all the failures. Returns the only failure as is, or nil if there are no failures
+func Join(message string, errs ...error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return &failures{message: message, errs: errs}
+	}
+}
+
+// statuses are the exit statuses, from the most severe to the least; GNU Wget's statuses take precedence over the
+// statuses specific to this program, except for the interruption, and the lower-numbered over the higher-numbered
+var statuses = []int{
+	InterruptedStatus,
+	ParseStatus, IOStatus, NetworkStatus, TLSStatus, AuthStatus, ProtocolStatus, ServerStatus,
+	QuotaExceededStatus, FileTooLargeStatus,
+	GenericStatus,
+}
+
+// Severer tells whether the given exit status takes precedence over the other, see ExitStatus
+func Severer(status, other int) bool {
+	return severity(status) > severity(other)
+}
+
+// severity returns the severity of the given exit status, the greater, the more severe
+func severity(status int) int {
+	for i, s := range statuses {
+		if s == status {
+			return len(statuses) - i
+		}
+	}
+	return 0
+}
+
+// ExitStatus returns the exit status of the program that failed with the given error: 0 if nil, if it wraps
+// context.Canceled, the status of the SignalError it wraps, see Interrupted, or else InterruptedStatus;
+// otherwise, the status of the failure, see Join. A failure has the status of the
+// outermost StatusError it wraps, or, of the outermost of: QuotaExceededStatus for ErrQuotaExceeded,
+// FileTooLargeStatus for ErrFileTooLarge, and IOStatus for ErrInsufficientSpace; otherwise, the GenericStatus.
+// Failures joined together have the status of the most severe of them, see Severer
+func ExitStatus(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, context.Canceled):
+		var signal *SignalError
+		if errors.As(err, &signal) {
+			return signal.Status()
+		}
+		return InterruptedStatus
+	default:
+		return status(err)
+	}
+}
+
+// status returns the exit status of the given failure, see ExitStatus
+func status(err error) int {
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch e := err.(type) {
+		case *StatusError:
+			return e.Status
+		case interface{ Unwrap() []error }:
+			joined := GenericStatus
+			for _, err := range e.Unwrap() {
+				joined = severest(joined, status(err))
+			}
+			return joined
+		}
+		switch err {
+		case ErrQuotaExceeded:
+			return QuotaExceededStatus
+		case ErrFileTooLarge:
+			return FileTooLargeStatus
+		case ErrInsufficientSpace:
+			return IOStatus
+		}
+	}
+	return GenericStatus
+}
+
+// severest returns the most severe of the given exit statuses
+func severest(status, other int) int {
+	if Severer(other, status) {
+		return other
+	}
+	return status
+}
@@ -3,7 +3,11 @@ package xerr
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"syscall"
 	"testing"
 )
 
@@ -131,3 +135,66 @@ func Test_writeError(t *testing.T) {
 		})
 	}
 }
+
+func TestExitStatus(t *testing.T) {
+	notFound := WithStatus(ServerStatus, errors.New("bad status code: 404 Not Found"))
+	refused := WithStatus(NetworkStatus, errors.New("connection refused"))
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, 0},
+		{"generic", errors.New("failed"), GenericStatus},
+		{"typed", notFound, ServerStatus},
+		{"wrapped", fmt.Errorf("https://example.com/missing: %w", notFound), ServerStatus},
+		{"quota", fmt.Errorf("%w: downloaded 5 GiB", ErrQuotaExceeded), QuotaExceededStatus},
+		{"too large", fmt.Errorf("%w: skipped 1 files", ErrFileTooLarge), FileTooLargeStatus},
+		{"no space", fmt.Errorf("%w: downloaded 5 GiB", ErrInsufficientSpace), IOStatus},
+		{"interrupted", fmt.Errorf("download interrupted: %w", context.Canceled), InterruptedStatus},
+		{"lower-numbered first", Join("2 failed", notFound, refused), NetworkStatus},
+		{"GNU statuses first", Join("2 failed", ErrQuotaExceeded, notFound), ServerStatus},
+		{"quota before too large", Join("2 failed", ErrFileTooLarge, ErrQuotaExceeded), QuotaExceededStatus},
+		{"any before generic", Join("2 failed", errors.New("failed"), ErrFileTooLarge), FileTooLargeStatus},
+		{"interrupted first", Join("2 failed", refused, context.Canceled), InterruptedStatus},
+		{
+			"terminated",
+			Interrupted(fmt.Errorf("download interrupted: %w", context.Canceled), &SignalError{syscall.SIGTERM}),
+			128 + int(syscall.SIGTERM),
+		},
+		{
+			"terminated first",
+			Interrupted(Join("2 failed", refused, context.Canceled), &SignalError{syscall.SIGTERM}),
+			128 + int(syscall.SIGTERM),
+		},
+		{"interrupted by a signal", Interrupted(context.Canceled, &SignalError{os.Interrupt}), InterruptedStatus},
+		{"not interrupted", Interrupted(notFound, &SignalError{syscall.SIGTERM}), ServerStatus},
+		{"nested", Join("2 failed", errors.New("failed"), Join("2 failed", notFound, refused)), NetworkStatus},
+		{"outermost", WithStatus(TLSStatus, refused), TLSStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitStatus(tt.err); got != tt.want {
+				t.Errorf("ExitStatus() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoin(t *testing.T) {
+	if err := Join("none failed"); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	only := errors.New("failed")
+	if err := Join("1 failed", only); err != only {
+		t.Errorf("expected the only failure as is, got %v", err)
+	}
+
+	err := Join("2 of 3 downloads failed", only, ErrQuotaExceeded)
+	if err.Error() != "2 of 3 downloads failed" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+	if !errors.Is(err, only) || !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected the error to wrap all the failures")
+	}
+}